@@ -2,30 +2,152 @@ package bill
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"fees-api/money"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
+type CreateBillInput struct {
+	BillID    string
+	AccountID string
+	Currency  money.Currency
+	CreatedAt time.Time
+}
+
+// CreateBillActivity inserts the bill row of the calling workflow. The workflow ID is the
+// idempotency key, so a retry returns the row inserted by an earlier attempt.
+func CreateBillActivity(ctx context.Context, input CreateBillInput) (*Bill, error) {
+	total, err := money.NewMoney(0, input.Currency)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError("invalid currency", "InvalidCurrency", err)
+	}
+	bill := &Bill{
+		ID:        input.BillID,
+		AccountID: input.AccountID,
+		Total:     total,
+		Status:    Open,
+		CreatedAt: input.CreatedAt,
+	}
+	return InsertBillForWorkflow(ctx, bill, activity.GetInfo(ctx).WorkflowExecution.ID)
+}
+
 func FinalizeBillActivity(ctx context.Context, billID string, closedAt time.Time) error {
-	// Only update status to CLOSED and closed_at (preserve existing total)
-	return UpdateBillStatusOnly(ctx, billID, Closed, &closedAt)
+	// Only update status to CLOSED, closed_at and the invoice number (preserve existing total)
+	return FinalizeBill(ctx, billID, closedAt, cfg.Invoice.IssuerID, cfg.Invoice.NumberFormat, cfg.Ledger.TaxBasisPoints)
+}
+
+// ChangeBillStatusActivity applies a void or reopen transition requested through a workflow signal
+func ChangeBillStatusActivity(ctx context.Context, change StatusChange) error {
+	err := TransitionBillStatus(ctx, change)
+	if errors.Is(err, ErrBillInvoiced) {
+		// The invoice number is never removed, so retrying cannot succeed
+		return temporal.NewNonRetryableApplicationError(err.Error(), billInvoicedError, err)
+	}
+	return err
 }
 
+// billInvoicedError is the application error type of a void refused because of ErrBillInvoiced
+const billInvoicedError = "BillInvoiced"
+
 type AddLineItemInput struct {
 	ItemID      string
 	BillID      string
 	Amount      money.Money
 	Description string
 	CreatedAt   time.Time
+
+	// Set for catalog-priced items
+	Quantity   int64
+	UnitAmount *money.Money
+	ProductID  string
+	PriceID    string
+
+	// Set for fee items
+	SourceItemID string
+	FeeRuleID    string
 }
 
 func AddLineItemActivity(ctx context.Context, input AddLineItemInput) error {
-	return InsertLineItemAndUpdateTotal(ctx, input.BillID, &LineItem{
-		ID:          input.ItemID,
-		BillID:      input.BillID,
-		Amount:      input.Amount,
-		Description: input.Description,
-		CreatedAt:   time.Now(),
-	})
+	return lineItemConflict(InsertLineItemAndUpdateTotal(ctx, input.BillID, input.lineItem()))
+}
+
+func (input AddLineItemInput) lineItem() *LineItem {
+	return &LineItem{
+		ID:           input.ItemID,
+		BillID:       input.BillID,
+		Amount:       input.Amount,
+		Description:  input.Description,
+		CreatedAt:    time.Now(),
+		Quantity:     input.Quantity,
+		UnitAmount:   input.UnitAmount,
+		ProductID:    input.ProductID,
+		PriceID:      input.PriceID,
+		SourceItemID: input.SourceItemID,
+		FeeRuleID:    input.FeeRuleID,
+	}
+}
+
+type AddLineItemsInput struct {
+	BillID string
+	Items  []AddLineItemInput
+
+	// Set when the items bill the usage claimed by the bill, which is then marked billed at this time
+	UsageBilledAt time.Time
+}
+
+// AddLineItemsActivity inserts a batch of line items in one transaction and returns the resulting
+// bill total. Items that already exist on the bill are skipped, so retries never add an item
+// twice; an item ID used on another bill fails the whole batch.
+func AddLineItemsActivity(ctx context.Context, input AddLineItemsInput) (money.Money, error) {
+	items := make([]*LineItem, len(input.Items))
+	for i, item := range input.Items {
+		items[i] = item.lineItem()
+	}
+	total, err := InsertLineItemsAndUpdateTotal(ctx, input.BillID, items, input.UsageBilledAt)
+	return total, lineItemConflict(err)
+}
+
+// lineItemConflict makes ErrLineItemConflict non-retryable: retrying cannot free the item ID
+func lineItemConflict(err error) error {
+	if errors.Is(err, ErrLineItemConflict) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), "LineItemConflict", err)
+	}
+	return err
+}
+
+type RemoveLineItemInput struct {
+	BillID string
+	ItemID string
+}
+
+// RemoveLineItemActivity deletes a line item and returns the resulting bill total
+func RemoveLineItemActivity(ctx context.Context, input RemoveLineItemInput) (money.Money, error) {
+	return DeleteLineItemAndUpdateTotal(ctx, input.BillID, input.ItemID)
+}
+
+// RepairBillTotalActivity resets the bill total to the sum of its line items when the ledger
+// agrees with them, and returns the resulting total
+func RepairBillTotalActivity(ctx context.Context, billID string) (TotalRepair, error) {
+	return RepairBillTotal(ctx, billID)
+}
+
+type AmendLineItemInput struct {
+	BillID      string
+	ItemID      string
+	Amount      *money.Money
+	Description *string
+}
+
+// AmendLineItemActivity updates a line item and returns the resulting bill total
+func AmendLineItemActivity(ctx context.Context, input AmendLineItemInput) (money.Money, error) {
+	total, err := UpdateLineItemAndUpdateTotal(ctx, input.BillID, input.ItemID, input.Amount, input.Description)
+	if errors.Is(err, ErrLineItemNotFound) {
+		// Retrying cannot make a missing item appear
+		return money.Money{}, temporal.NewNonRetryableApplicationError(err.Error(), "LineItemNotFound", err)
+	}
+	return total, err
 }
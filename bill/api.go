@@ -3,6 +3,7 @@ package bill
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 
 	"fees-api/money"
@@ -24,6 +25,9 @@ func validateUUID(id string) error {
 
 type CreateBillRequest struct {
 	Currency money.Currency `json:"currency"`
+
+	// AccountID optionally ties the bill to an account so metered usage is billed to it on close
+	AccountID string `json:"account_id,omitempty"`
 }
 
 type CreateBillResponse struct {
@@ -45,8 +49,12 @@ func CreateBillAPI(
 	if !req.Currency.IsValid() {
 		return nil, errs.WrapCode(errors.New("invalid currency"), errs.InvalidArgument, "invalid currency")
 	}
+	req.AccountID = strings.TrimSpace(req.AccountID)
+	if len(req.AccountID) > maxAccountIDLength {
+		return nil, errs.WrapCode(errors.New("account_id too long"), errs.InvalidArgument, "account_id max 100 chars")
+	}
 
-	b, err := Create(ctx, req.Currency)
+	b, err := Create(ctx, req.Currency, req.AccountID)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +86,8 @@ func GetBillAPI(ctx context.Context, id string) (*GetBillResponse, error) {
 }
 
 type ListBillsRequest struct {
-	Status string `query:"status"`
+	Status        string `query:"status"`
+	InvoiceNumber string `query:"invoice_number"`
 }
 
 type ListBillsResponse struct {
@@ -92,14 +101,18 @@ func ListBills(
 ) (*ListBillsResponse, error) {
 	// Validate status parameter
 	if req.Status != "" {
-		if req.Status != "OPEN" && req.Status != "CLOSED" {
-			return nil, errs.WrapCode(errors.New("status must be OPEN or CLOSED"), errs.InvalidArgument, "status must be OPEN or CLOSED")
+		if !Status(req.Status).IsValid() {
+			return nil, errs.WrapCode(errors.New("status must be OPEN, CLOSED or VOID"), errs.InvalidArgument, "status must be OPEN, CLOSED or VOID")
 		}
 	}
 
 	var bills []*Bill
 	var err error
 
+	if req.InvoiceNumber != "" {
+		return listBillsByInvoiceNumber(ctx, req)
+	}
+
 	if req.Status == "" {
 		bills, err = ListBillsAll(ctx)
 	} else {
@@ -113,6 +126,21 @@ func ListBills(
 	return &ListBillsResponse{Bills: bills}, nil
 }
 
+// listBillsByInvoiceNumber returns the bill with the requested invoice number, if it also matches any status filter
+func listBillsByInvoiceNumber(ctx context.Context, req ListBillsRequest) (*ListBillsResponse, error) {
+	b, err := GetBillByInvoiceNumber(ctx, strings.TrimSpace(req.InvoiceNumber))
+	if errors.Is(err, ErrBillNotFound) {
+		return &ListBillsResponse{}, nil
+	}
+	if err != nil {
+		return &ListBillsResponse{}, err
+	}
+	if req.Status != "" && b.Status != Status(req.Status) {
+		return &ListBillsResponse{}, nil
+	}
+	return &ListBillsResponse{Bills: []*Bill{b}}, nil
+}
+
 //encore:api public method=POST path=/bills/:id/close
 func CloseBillAPI(ctx context.Context, id string) error {
 	// Validate bill ID format
@@ -123,12 +151,69 @@ func CloseBillAPI(ctx context.Context, id string) error {
 	return Close(ctx, id)
 }
 
+type StatusChangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// validateReason checks the reason given for a void or reopen
+func validateReason(reason string) error {
+	if len(reason) == 0 || len(reason) > 500 {
+		return errs.WrapCode(errors.New("reason required and max 500 chars"), errs.InvalidArgument, "reason required and max 500 chars")
+	}
+	return nil
+}
+
+// VoidBillAPI voids an open bill that was created by mistake. Requires the admin role.
+//
+//encore:api auth method=POST path=/bills/:id/void
+func VoidBillAPI(ctx context.Context, id string, req StatusChangeRequest) error {
+	if err := requireAdmin(); err != nil {
+		return err
+	}
+
+	// Validate bill ID format
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := validateReason(req.Reason); err != nil {
+		return err
+	}
+
+	return VoidBill(ctx, id, req.Reason)
+}
+
+// ReopenBillAPI reopens a recently closed bill. Requires the admin role.
+//
+//encore:api auth method=POST path=/bills/:id/reopen
+func ReopenBillAPI(ctx context.Context, id string, req StatusChangeRequest) error {
+	if err := requireAdmin(); err != nil {
+		return err
+	}
+
+	// Validate bill ID format
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := validateReason(req.Reason); err != nil {
+		return err
+	}
+
+	return ReopenBill(ctx, id, req.Reason)
+}
+
 // MaxAmountCents represents the maximum allowed amount for a line item ($1M in cents)
 const MaxAmountCents = 1_000_000_00
 
+// AddItemRequest adds either a raw amount with a description, or quantity units of a catalog price
 type AddItemRequest struct {
-	Amount      int64  `json:"amount"`
-	Description string `json:"description"`
+	Amount      int64  `json:"amount,omitempty"`
+	Description string `json:"description,omitempty"`
+	PriceID     string `json:"price_id,omitempty"`
+	Quantity    int64  `json:"quantity,omitempty"`
 }
 
 //encore:api public method=POST path=/bills/:id/items
@@ -139,14 +224,188 @@ func AddItem(ctx context.Context, id string, req AddItemRequest) error {
 	}
 
 	// Sanitize and validate inputs
+	if err := validateAddItemRequest(&req); err != nil {
+		return errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	if req.PriceID != "" {
+		return AddCatalogLineItem(ctx, id, req.PriceID, req.Quantity, req.Description)
+	}
+	return AddLineItem(ctx, id, req.Amount, req.Description)
+}
+
+// BatchItemRequest is one item of a batch. ItemID is optional; a client that sets it can retry
+// the batch and each item is still added only once.
+type BatchItemRequest struct {
+	ItemID      string `json:"item_id,omitempty"`
+	Amount      int64  `json:"amount,omitempty"`
+	Description string `json:"description,omitempty"`
+	PriceID     string `json:"price_id,omitempty"`
+	Quantity    int64  `json:"quantity,omitempty"`
+}
+
+type AddItemsRequest struct {
+	Items []BatchItemRequest `json:"items"`
+}
+
+type AddItemsResponse struct {
+	// ItemIDs are the IDs of the items in request order
+	ItemIDs []string `json:"item_ids"`
+}
+
+// AddItems adds up to 1000 line items at once. They are validated like POST /bills/:id/items and
+// inserted, together with their fees, in a single transaction.
+//
+//encore:api public method=POST path=/bills/:id/items/batch
+func AddItems(ctx context.Context, id string, req AddItemsRequest) (*AddItemsResponse, error) {
+	// Validate bill ID format
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxBatchItems {
+		return nil, errs.WrapCode(errors.New("items must contain 1 to 1000 entries"), errs.InvalidArgument, "items must contain 1 to 1000 entries")
+	}
+
+	bill, err := getOpenBill(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := newPriceChecker(ctx, bill.Total.Currency)
+	signals := make([]AddItemSignal, len(req.Items))
+	for i, item := range req.Items {
+		signal, err := batchItemSignal(item, prices)
+		if err != nil {
+			msg := fmt.Sprintf("items[%d]: %v", i, err)
+			return nil, errs.WrapCode(errors.New(msg), errs.InvalidArgument, msg)
+		}
+		signals[i] = signal
+	}
+	if err := validateAddItemsSignal(AddItemsSignal{Items: signals}); err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	if err := AddLineItems(ctx, bill.ID, signals); err != nil {
+		return nil, err
+	}
+
+	resp := &AddItemsResponse{ItemIDs: make([]string, len(signals))}
+	for i, s := range signals {
+		resp.ItemIDs[i] = s.ItemID
+	}
+	return resp, nil
+}
+
+// batchItemSignal validates one batch item and assigns it an ID if the client did not
+func batchItemSignal(item BatchItemRequest, prices *priceChecker) (AddItemSignal, error) {
+	req := AddItemRequest{
+		Amount:      item.Amount,
+		Description: item.Description,
+		PriceID:     item.PriceID,
+		Quantity:    item.Quantity,
+	}
+	if err := validateAddItemRequest(&req); err != nil {
+		return AddItemSignal{}, err
+	}
+	if req.PriceID != "" {
+		if err := prices.check(req.PriceID); err != nil {
+			return AddItemSignal{}, err
+		}
+	}
+
+	itemID := strings.TrimSpace(item.ItemID)
+	if itemID == "" {
+		itemID = uuid.NewString()
+	}
+	signal := AddItemSignal{
+		ItemID:      itemID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		PriceID:     req.PriceID,
+		Quantity:    req.Quantity,
+	}
+	if err := validateAddItemSignal(signal); err != nil {
+		return AddItemSignal{}, err
+	}
+	return signal, nil
+}
+
+// validateAddItemRequest trims the description and checks either the raw amount or, for catalog
+// items, the price ID and quantity; the amount of a catalog item is computed by the workflow
+func validateAddItemRequest(req *AddItemRequest) error {
 	req.Description = strings.TrimSpace(req.Description)
 
+	if req.PriceID != "" {
+		if _, err := uuid.Parse(req.PriceID); err != nil {
+			return errors.New("price_id must be a valid UUID")
+		}
+		if req.Amount != 0 {
+			return errors.New("amount cannot be combined with price_id")
+		}
+		if req.Quantity <= 0 || req.Quantity > maxItemQuantity {
+			return errors.New("quantity must be positive and reasonable")
+		}
+		if len(req.Description) > 500 {
+			return errors.New("description max 500 chars")
+		}
+		return nil
+	}
+
+	if req.Quantity != 0 {
+		return errors.New("quantity requires price_id")
+	}
 	if req.Amount <= 0 || req.Amount > MaxAmountCents {
-		return errs.WrapCode(errors.New("amount must be positive and reasonable"), errs.InvalidArgument, "amount must be positive and reasonable")
+		return errors.New("amount must be positive and reasonable")
 	}
 	if len(req.Description) == 0 || len(req.Description) > 500 {
-		return errs.WrapCode(errors.New("description required and max 500 chars"), errs.InvalidArgument, "description required and max 500 chars")
+		return errors.New("description required and max 500 chars")
 	}
+	return nil
+}
 
-	return AddLineItem(ctx, id, req.Amount, req.Description)
+type AmendItemRequest struct {
+	Amount      *int64  `json:"amount,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+//encore:api public method=DELETE path=/bills/:id/items/:itemId
+func RemoveItem(ctx context.Context, id string, itemId string) error {
+	// Validate bill and item ID format
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+	if err := validateUUID(itemId); err != nil {
+		return err
+	}
+
+	return RemoveLineItem(ctx, id, itemId)
+}
+
+//encore:api public method=PATCH path=/bills/:id/items/:itemId
+func AmendItem(ctx context.Context, id string, itemId string, req AmendItemRequest) error {
+	// Validate bill and item ID format
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+	if err := validateUUID(itemId); err != nil {
+		return err
+	}
+
+	if req.Amount == nil && req.Description == nil {
+		return errs.WrapCode(errors.New("amount or description is required"), errs.InvalidArgument, "amount or description is required")
+	}
+
+	// Sanitize and validate inputs
+	if req.Amount != nil && (*req.Amount <= 0 || *req.Amount > MaxAmountCents) {
+		return errs.WrapCode(errors.New("amount must be positive and reasonable"), errs.InvalidArgument, "amount must be positive and reasonable")
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if len(description) == 0 || len(description) > 500 {
+			return errs.WrapCode(errors.New("description required and max 500 chars"), errs.InvalidArgument, "description required and max 500 chars")
+		}
+		req.Description = &description
+	}
+
+	return AmendLineItem(ctx, id, itemId, req.Amount, req.Description)
 }
@@ -0,0 +1,45 @@
+package bill
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+)
+
+type Role string
+
+const (
+	RoleAdmin Role = "admin"
+)
+
+// AuthData is attached to every authenticated request
+type AuthData struct {
+	Role Role
+}
+
+var secrets struct {
+	// AdminToken is the bearer token granting the admin role
+	AdminToken string
+}
+
+// AuthHandler authenticates bearer tokens for endpoints that require a caller identity.
+//
+//encore:authhandler
+func AuthHandler(ctx context.Context, token string) (auth.UID, *AuthData, error) {
+	if secrets.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secrets.AdminToken)) == 1 {
+		return "admin", &AuthData{Role: RoleAdmin}, nil
+	}
+	return "", nil, errs.WrapCode(errors.New("invalid token"), errs.Unauthenticated, "invalid token")
+}
+
+// requireAdmin returns PermissionDenied unless the current request was authenticated as an admin
+func requireAdmin() error {
+	data, _ := auth.Data().(*AuthData)
+	if data == nil || data.Role != RoleAdmin {
+		return errs.WrapCode(errors.New("admin role required"), errs.PermissionDenied, "admin role required")
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package bill
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 func TestEnsureOpen(t *testing.T) {
 	openBill := &Bill{Status: Open}
 	closedBill := &Bill{Status: Closed}
+	voidBill := &Bill{Status: Void}
 
 	tests := []struct {
 		name    string
@@ -18,6 +20,7 @@ func TestEnsureOpen(t *testing.T) {
 	}{
 		{"open bill", openBill, false},
 		{"closed bill", closedBill, true},
+		{"void bill", voidBill, true},
 	}
 
 	for _, tt := range tests {
@@ -51,6 +54,7 @@ func TestStatusString(t *testing.T) {
 	}{
 		{"open status", Open, "OPEN"},
 		{"closed status", Closed, "CLOSED"},
+		{"void status", Void, "VOID"},
 	}
 
 	for _, tt := range tests {
@@ -84,3 +88,57 @@ func TestBillValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		status Status
+		want   bool
+	}{
+		{"open", Open, true},
+		{"closed", Closed, true},
+		{"void", Void, true},
+		{"lowercase", Status("open"), false},
+		{"unknown", Status("PAID"), false},
+		{"empty", Status(""), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.IsValid(); got != tt.want {
+				t.Errorf("Status.IsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    Status
+		to      Status
+		wantErr bool
+	}{
+		{"open to closed", Open, Closed, false},
+		{"open to void", Open, Void, false},
+		{"closed to open (reopen)", Closed, Open, false},
+		{"open to open", Open, Open, true},
+		{"closed to closed", Closed, Closed, true},
+		{"closed to void", Closed, Void, true},
+		{"void to open", Void, Open, true},
+		{"void to closed", Void, Closed, true},
+		{"unknown status", Status("PAID"), Closed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTransition(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTransition(%s, %s) error = %v, wantErr %v", tt.from, tt.to, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("validateTransition() error = %v, want ErrInvalidTransition", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fees-api/money"
+	"fees-api/pricing"
+
+	"encore.dev/beta/errs"
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+)
+
+// maxItemQuantity bounds the quantity of a single catalog-priced line item
+const maxItemQuantity = 1_000_000_000
+
+type Product struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Price struct {
+	ID          string         `json:"id"`
+	ProductID   string         `json:"product_id"`
+	ProductName string         `json:"product_name"`
+	Currency    money.Currency `json:"currency"`
+	Model       pricing.Model  `json:"model"`
+	UnitAmount  int64          `json:"unit_amount,omitempty"`
+	PackageSize int64          `json:"package_size,omitempty"`
+	Tiers       []pricing.Tier `json:"tiers,omitempty"`
+	Active      bool           `json:"active"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// definition returns the pricing model of a catalog price
+func (p *Price) definition() pricing.Price {
+	return pricing.Price{
+		Model:       p.Model,
+		Currency:    p.Currency,
+		UnitAmount:  p.UnitAmount,
+		PackageSize: p.PackageSize,
+		Tiers:       p.Tiers,
+	}
+}
+
+type CreateProductRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type ListProductsResponse struct {
+	Products []*Product `json:"products"`
+}
+
+type CreatePriceRequest struct {
+	ProductID   string         `json:"product_id"`
+	Currency    money.Currency `json:"currency"`
+	Model       pricing.Model  `json:"model"`
+	UnitAmount  int64          `json:"unit_amount,omitempty"`
+	PackageSize int64          `json:"package_size,omitempty"`
+	Tiers       []pricing.Tier `json:"tiers,omitempty"`
+}
+
+type ListPricesRequest struct {
+	ProductID string `query:"product_id"`
+}
+
+type ListPricesResponse struct {
+	Prices []*Price `json:"prices"`
+}
+
+// CreateProductAPI adds a product to the catalog. Requires the admin role.
+//
+//encore:api auth method=POST path=/catalog/products
+func CreateProductAPI(ctx context.Context, req CreateProductRequest) (*Product, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if len(req.Name) == 0 || len(req.Name) > 200 {
+		return nil, errs.WrapCode(errors.New("name required and max 200 chars"), errs.InvalidArgument, "name required and max 200 chars")
+	}
+	if len(req.Description) > 500 {
+		return nil, errs.WrapCode(errors.New("description max 500 chars"), errs.InvalidArgument, "description max 500 chars")
+	}
+
+	p := &Product{
+		ID:          uuid.NewString(),
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+	}
+	if err := CreateProduct(ctx, p); err != nil {
+		return nil, errs.Wrap(err, "failed to create product")
+	}
+	return p, nil
+}
+
+//encore:api public method=GET path=/catalog/products
+func ListProductsAPI(ctx context.Context) (*ListProductsResponse, error) {
+	products, err := ListProducts(ctx)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list products")
+	}
+	return &ListProductsResponse{Products: products}, nil
+}
+
+// CreatePriceAPI adds a price for a product in one currency. Requires the admin role.
+//
+//encore:api auth method=POST path=/catalog/prices
+func CreatePriceAPI(ctx context.Context, req CreatePriceRequest) (*Price, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	if err := validateUUID(req.ProductID); err != nil {
+		return nil, err
+	}
+	product, err := GetProduct(ctx, req.ProductID)
+	if err != nil {
+		return nil, errs.WrapCode(err, errs.NotFound, "product not found")
+	}
+
+	p := &Price{
+		ID:          uuid.NewString(),
+		ProductID:   product.ID,
+		ProductName: product.Name,
+		Currency:    req.Currency,
+		Model:       req.Model,
+		UnitAmount:  req.UnitAmount,
+		PackageSize: req.PackageSize,
+		Tiers:       req.Tiers,
+		Active:      true,
+		CreatedAt:   time.Now(),
+	}
+	if err := p.definition().Validate(); err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	if err := CreatePrice(ctx, p); err != nil {
+		return nil, errs.Wrap(err, "failed to create price")
+	}
+	return p, nil
+}
+
+//encore:api public method=GET path=/catalog/prices
+func ListPricesAPI(ctx context.Context, req ListPricesRequest) (*ListPricesResponse, error) {
+	if req.ProductID != "" {
+		if err := validateUUID(req.ProductID); err != nil {
+			return nil, err
+		}
+	}
+
+	prices, err := ListPrices(ctx, req.ProductID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list prices")
+	}
+	return &ListPricesResponse{Prices: prices}, nil
+}
+
+// ArchivePriceAPI stops a price from being used for new line items. Requires the admin role.
+//
+//encore:api auth method=POST path=/catalog/prices/:id/archive
+func ArchivePriceAPI(ctx context.Context, id string) error {
+	if err := requireAdmin(); err != nil {
+		return err
+	}
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+
+	if err := ArchivePrice(ctx, id); err != nil {
+		if errors.Is(err, ErrPriceNotFound) {
+			return errs.WrapCode(err, errs.NotFound, "price not found")
+		}
+		return errs.Wrap(err, "failed to archive price")
+	}
+	return nil
+}
+
+// GetPriceActivity loads a catalog price so the workflow can compute a line item amount
+func GetPriceActivity(ctx context.Context, priceID string) (*Price, error) {
+	p, err := GetPrice(ctx, priceID)
+	if errors.Is(err, ErrPriceNotFound) {
+		// Retrying cannot make a missing price appear
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "PriceNotFound", err)
+	}
+	return p, err
+}
+
+// priceChecker applies the catalog checks of AddCatalogLineItem to many items, looking each price up once
+type priceChecker struct {
+	ctx      context.Context
+	currency money.Currency
+	prices   map[string]*Price
+}
+
+func newPriceChecker(ctx context.Context, currency money.Currency) *priceChecker {
+	return &priceChecker{ctx: ctx, currency: currency, prices: make(map[string]*Price)}
+}
+
+// check returns an error if the price does not exist, is archived or is in another currency
+func (c *priceChecker) check(priceID string) error {
+	price, ok := c.prices[priceID]
+	if !ok {
+		var err error
+		price, err = GetPrice(c.ctx, priceID)
+		if err != nil && !errors.Is(err, ErrPriceNotFound) {
+			return fmt.Errorf("failed to look up price: %w", err)
+		}
+		c.prices[priceID] = price
+	}
+
+	switch {
+	case price == nil:
+		return errors.New("price not found")
+	case !price.Active:
+		return errors.New("price is archived")
+	case price.Currency != c.currency:
+		return errors.New("price currency does not match bill currency")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package bill
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"fees-api/money"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrPriceNotFound   = errors.New("price not found")
+)
+
+func CreateProduct(ctx context.Context, p *Product) error {
+	ctx, span := startDBSpan(ctx, "CreateProduct", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO products (id, name, description, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, p.ID, p.Name, p.Description, p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create product %s: %w", p.ID, err)
+	}
+	return nil
+}
+
+func GetProduct(ctx context.Context, productID string) (*Product, error) {
+	ctx, span := startDBSpan(ctx, "GetProduct", "")
+	defer span.End()
+
+	var p Product
+	err := db.QueryRow(ctx, `
+		SELECT id, name, COALESCE(description, ''), created_at
+		FROM products
+		WHERE id = $1
+	`, productID).Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("product not found for id %s: %w", productID, ErrProductNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan product for id %s: %w", productID, err)
+	}
+	return &p, nil
+}
+
+func ListProducts(ctx context.Context) ([]*Product, error) {
+	ctx, span := startDBSpan(ctx, "ListProducts", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT id, name, COALESCE(description, ''), created_at
+		FROM products
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []*Product
+	for rows.Next() {
+		var p Product
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		products = append(products, &p)
+	}
+	return products, rows.Err()
+}
+
+func CreatePrice(ctx context.Context, p *Price) error {
+	ctx, span := startDBSpan(ctx, "CreatePrice", "")
+	defer span.End()
+
+	var tiers []byte
+	if len(p.Tiers) > 0 {
+		var err error
+		if tiers, err = json.Marshal(p.Tiers); err != nil {
+			return fmt.Errorf("failed to encode tiers for price %s: %w", p.ID, err)
+		}
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO prices (id, product_id, currency, model, unit_amount, package_size, tiers, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, p.ID, p.ProductID, p.Currency, p.Model, p.UnitAmount, p.PackageSize, tiers, p.Active, p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create price %s: %w", p.ID, err)
+	}
+	return nil
+}
+
+func GetPrice(ctx context.Context, priceID string) (*Price, error) {
+	ctx, span := startDBSpan(ctx, "GetPrice", "")
+	defer span.End()
+
+	row := db.QueryRow(ctx, `
+		SELECT pr.id, pr.product_id, p.name, pr.currency, pr.model,
+		       COALESCE(pr.unit_amount, 0), COALESCE(pr.package_size, 0), pr.tiers, pr.active, pr.created_at
+		FROM prices pr
+		JOIN products p ON p.id = pr.product_id
+		WHERE pr.id = $1
+	`, priceID)
+
+	p, err := scanPrice(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("price not found for id %s: %w", priceID, ErrPriceNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan price for id %s: %w", priceID, err)
+	}
+	return p, nil
+}
+
+// ListPrices returns all prices, or only those of one product when productID is set
+func ListPrices(ctx context.Context, productID string) ([]*Price, error) {
+	ctx, span := startDBSpan(ctx, "ListPrices", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT pr.id, pr.product_id, p.name, pr.currency, pr.model,
+		       COALESCE(pr.unit_amount, 0), COALESCE(pr.package_size, 0), pr.tiers, pr.active, pr.created_at
+		FROM prices pr
+		JOIN products p ON p.id = pr.product_id
+		WHERE $1 = '' OR pr.product_id = $1
+		ORDER BY p.name, pr.currency, pr.created_at
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []*Price
+	for rows.Next() {
+		p, err := scanPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+func ArchivePrice(ctx context.Context, priceID string) error {
+	ctx, span := startDBSpan(ctx, "ArchivePrice", "")
+	defer span.End()
+
+	result, err := db.Exec(ctx, `
+		UPDATE prices SET active = FALSE WHERE id = $1
+	`, priceID)
+	if err != nil {
+		return fmt.Errorf("failed to archive price %s: %w", priceID, err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("price not found for id %s: %w", priceID, ErrPriceNotFound)
+	}
+	return nil
+}
+
+func scanPrice(row interface{ Scan(...interface{}) error }) (*Price, error) {
+	var (
+		p           Price
+		currencyStr string
+		tiers       []byte
+	)
+	if err := row.Scan(
+		&p.ID,
+		&p.ProductID,
+		&p.ProductName,
+		&currencyStr,
+		&p.Model,
+		&p.UnitAmount,
+		&p.PackageSize,
+		&tiers,
+		&p.Active,
+		&p.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	p.Currency = money.Currency(currencyStr)
+	if len(tiers) > 0 {
+		if err := json.Unmarshal(tiers, &p.Tiers); err != nil {
+			return nil, fmt.Errorf("failed to decode tiers for price %s: %w", p.ID, err)
+		}
+	}
+	return &p, nil
+}
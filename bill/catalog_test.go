@@ -0,0 +1,73 @@
+package bill
+
+import (
+	"testing"
+
+	"fees-api/money"
+	"fees-api/pricing"
+)
+
+func TestPriceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   Price
+		wantErr bool
+	}{
+		{"per unit", Price{Currency: money.USD, Model: pricing.PerUnit, UnitAmount: 250}, false},
+		{"free per unit", Price{Currency: money.GEL, Model: pricing.PerUnit}, false},
+		{"volume", Price{Currency: money.USD, Model: pricing.Volume, Tiers: []pricing.Tier{{UpTo: 100, UnitAmount: 50}, {UpTo: 0, UnitAmount: 40}}}, false},
+		{"invalid currency", Price{Currency: "EUR", Model: pricing.PerUnit, UnitAmount: 1}, true},
+		{"unknown model", Price{Currency: money.USD, Model: "stairstep"}, true},
+		{"negative unit amount", Price{Currency: money.USD, Model: pricing.PerUnit, UnitAmount: -1}, true},
+		{"per unit with tiers", Price{Currency: money.USD, Model: pricing.PerUnit, Tiers: []pricing.Tier{{UpTo: 0, UnitAmount: 1}}}, true},
+		{"volume without tiers", Price{Currency: money.USD, Model: pricing.Volume}, true},
+		{"volume last tier bounded", Price{Currency: money.USD, Model: pricing.Volume, Tiers: []pricing.Tier{{UpTo: 100, UnitAmount: 1}}}, true},
+		{"volume tiers not increasing", Price{Currency: money.USD, Model: pricing.Volume, Tiers: []pricing.Tier{{UpTo: 100, UnitAmount: 2}, {UpTo: 50, UnitAmount: 1}, {UpTo: 0, UnitAmount: 1}}}, true},
+		{"volume negative tier amount", Price{Currency: money.USD, Model: pricing.Volume, Tiers: []pricing.Tier{{UpTo: 0, UnitAmount: -5}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.price.definition().Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Price.definition().Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPriceCharge(t *testing.T) {
+	perUnit := Price{Currency: money.USD, Model: pricing.PerUnit, UnitAmount: 250}
+	volume := Price{Currency: money.GEL, Model: pricing.Volume, Tiers: []pricing.Tier{
+		{UpTo: 1000, UnitAmount: 10},
+		{UpTo: 10000, UnitAmount: 8},
+		{UpTo: 0, UnitAmount: 5},
+	}}
+	packaged := Price{Currency: money.USD, Model: pricing.Package, UnitAmount: 900, PackageSize: 10}
+
+	tests := []struct {
+		name     string
+		price    Price
+		quantity int64
+		want     money.Money
+	}{
+		{"per unit", perUnit, 3, money.Money{Amount: 750, Currency: money.USD}},
+		{"volume first tier", volume, 1, money.Money{Amount: 10, Currency: money.GEL}},
+		{"volume tier boundary", volume, 1000, money.Money{Amount: 10000, Currency: money.GEL}},
+		{"volume second tier", volume, 1001, money.Money{Amount: 8008, Currency: money.GEL}},
+		{"volume unbounded tier", volume, 50000, money.Money{Amount: 250000, Currency: money.GEL}},
+		{"package", packaged, 11, money.Money{Amount: 1800, Currency: money.USD}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pricing.Calculate(tt.price.definition(), tt.quantity)
+			if err != nil {
+				t.Fatalf("pricing.Calculate() error = %v", err)
+			}
+			if got.Total != tt.want {
+				t.Errorf("pricing.Calculate() total = %v, want %v", got.Total, tt.want)
+			}
+		})
+	}
+}
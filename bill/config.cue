@@ -1,3 +1,94 @@
 package bill
 
-TemporalServer: "localhost:7233"
+TemporalServer: *"localhost:7233" | string
+
+Temporal: {
+	Namespace: *"default" | string
+	TaskQueue: *"BILLING_TASK_QUEUE" | string
+	TLS: {
+		Enabled:    *false | bool
+		CertFile:   *"" | string
+		KeyFile:    *"" | string
+		CAFile:     *"" | string
+		ServerName: *"" | string
+	}
+}
+
+// 0 keeps the Temporal SDK default
+Worker: {
+	MaxConcurrentActivities:    *0 | int
+	MaxConcurrentWorkflowTasks: *0 | int
+	ActivityPollers:            *0 | int
+	WorkflowTaskPollers:        *0 | int
+	StopTimeoutSeconds:         *20 | int // within Encore's graceful shutdown window
+}
+
+Invoice: {
+	IssuerID:      *"default" | string
+	NumberFormat:  *"INV-{YYYY}-{SEQ:6}" | string
+	IssuerName:    *"Fees API" | string
+	IssuerAddress: *"1 Billing Street\nTbilisi, Georgia" | string
+	LogoPath:      *"" | string
+}
+
+Workflow: {
+	ContinueAsNewEvents: *10000 | int
+	ContinueAsNewBytes:  *10485760 | int // 10 MiB
+	ActivityRetry: {
+		InitialIntervalSeconds: *1 | int
+		BackoffCoefficient:     *2.0 | float
+		MaximumIntervalSeconds: *60 | int
+		MaximumAttempts:        *5 | int
+	}
+	WorkflowRetry: {
+		InitialIntervalSeconds: *1 | int
+		BackoffCoefficient:     *2.0 | float
+		MaximumIntervalSeconds: *60 | int
+		MaximumAttempts:        *3 | int
+	}
+}
+
+Reconciliation: {
+	Repair:    *false | bool
+	BatchSize: *500 | int
+}
+
+// Production runs in its own namespace with a larger worker, and retries activities for longer
+// so a database failover does not fail bills
+if #Meta.Environment.Type == "production" {
+	Temporal: Namespace: "fees-production"
+	Worker: {
+		MaxConcurrentActivities: 200
+		ActivityPollers:         8
+	}
+	Workflow: ActivityRetry: {
+		MaximumIntervalSeconds: 120
+		MaximumAttempts:        10
+	}
+}
+
+Ledger: {
+	TaxBasisPoints: *0 | int
+}
+
+Outbox: {
+	Enabled: *false | bool
+}
+
+Tracing: {
+	Enabled:     *false | bool
+	Endpoint:    *"localhost:4317" | string
+	Insecure:    *true | bool
+	SampleRatio: *1.0 | float
+}
+
+Metrics: {
+	Enabled:         *false | bool
+	Endpoint:        *"localhost:4317" | string
+	Insecure:        *true | bool
+	IntervalSeconds: *60 | int
+}
+
+Logging: {
+	RedactDescriptions: *false | bool
+}
@@ -1,5 +1,139 @@
 package bill
 
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
 type Config struct {
 	TemporalServer string
+	Temporal       TemporalConfig
+	Worker         WorkerConfig
+	Invoice        InvoiceConfig
+	Workflow       WorkflowConfig
+	Reconciliation ReconciliationConfig
+	Ledger         LedgerConfig
+	Outbox         OutboxConfig
+	Tracing        TracingConfig
+	Metrics        MetricsConfig
+	Logging        LoggingConfig
+}
+
+// TemporalConfig selects the namespace and task queue on the server at TemporalServer, and how to
+// connect to it
+type TemporalConfig struct {
+	Namespace string
+	TaskQueue string
+	TLS       TLSConfig
+}
+
+// TLSConfig enables TLS to the Temporal server, and mTLS when a client certificate is set
+type TLSConfig struct {
+	Enabled    bool
+	CertFile   string // PEM client certificate for mTLS, set together with KeyFile
+	KeyFile    string
+	CAFile     string // PEM CA bundle for the server certificate, system roots when empty
+	ServerName string // overrides the name checked against the server certificate
+}
+
+// WorkerConfig limits the work one worker takes on; 0 keeps the Temporal SDK default
+type WorkerConfig struct {
+	MaxConcurrentActivities    int
+	MaxConcurrentWorkflowTasks int
+	ActivityPollers            int
+	WorkflowTaskPollers        int
+	StopTimeoutSeconds         int // how long a stopping worker lets running activities finish
+}
+
+// WorkflowConfig bounds the event history of a single bill workflow run (see HistoryLimits) and
+// sets the retry policies of workflows and their activities
+type WorkflowConfig struct {
+	ContinueAsNewEvents int // history events after which a run continues as new, 0 to disable
+	ContinueAsNewBytes  int // history size in bytes after which a run continues as new, 0 to disable
+	ActivityRetry       RetryConfig
+	WorkflowRetry       RetryConfig // retries a failed bill workflow from the start
+}
+
+// RetryConfig is a Temporal retry policy
+type RetryConfig struct {
+	InitialIntervalSeconds int
+	BackoffCoefficient     float64
+	MaximumIntervalSeconds int
+	MaximumAttempts        int // 0 retries without limit
+}
+
+// ReconciliationConfig controls the nightly check of bill totals, see ReconciliationWorkflow
+type ReconciliationConfig struct {
+	Repair    bool // reset stored totals that disagree with the line items
+	BatchSize int  // bills checked per activity
+}
+
+// LedgerConfig controls the entries posted to the bill ledger, see package ledger
+type LedgerConfig struct {
+	TaxBasisPoints int64 // tax charged on a bill's revenue when it closes, 0 for none
+}
+
+// OutboxConfig controls queueing of bill signals while Temporal is unreachable, see signalBill
+type OutboxConfig struct {
+	Enabled bool // queue signals in Postgres instead of failing with Unavailable
+}
+
+// TracingConfig exports OpenTelemetry traces over OTLP/gRPC, see setupTracing
+type TracingConfig struct {
+	Enabled     bool
+	Endpoint    string  // OTLP collector, e.g. "localhost:4317"
+	Insecure    bool    // plain gRPC, for a local collector
+	SampleRatio float64 // share of new traces recorded; traces started upstream follow the caller
+}
+
+// MetricsConfig exports the Temporal SDK and workflow metrics over OTLP/gRPC, see setupMetrics.
+// Encore metrics are exported by Encore.
+type MetricsConfig struct {
+	Enabled         bool
+	Endpoint        string // OTLP collector, e.g. "localhost:4317"
+	Insecure        bool   // plain gRPC, for a local collector
+	IntervalSeconds int    // between exports
+}
+
+// LoggingConfig controls what the structured logs may contain, see newLogger
+type LoggingConfig struct {
+	RedactDescriptions bool // replace line item descriptions, which may hold customer data
+}
+
+func (c WorkflowConfig) historyLimits() HistoryLimits {
+	return HistoryLimits{MaxEvents: c.ContinueAsNewEvents, MaxBytes: c.ContinueAsNewBytes}
+}
+
+func (r RetryConfig) policy() *temporal.RetryPolicy {
+	return &temporal.RetryPolicy{
+		InitialInterval:    time.Duration(r.InitialIntervalSeconds) * time.Second,
+		BackoffCoefficient: r.BackoffCoefficient,
+		MaximumInterval:    time.Duration(r.MaximumIntervalSeconds) * time.Second,
+		MaximumAttempts:    int32(r.MaximumAttempts),
+	}
+}
+
+func (r RetryConfig) validate(name string) error {
+	switch {
+	case r.InitialIntervalSeconds <= 0:
+		return fmt.Errorf("%s retry: initial interval must be positive, got %d seconds", name, r.InitialIntervalSeconds)
+	case r.BackoffCoefficient < 1:
+		return fmt.Errorf("%s retry: backoff coefficient must be at least 1, got %g", name, r.BackoffCoefficient)
+	case r.MaximumIntervalSeconds < r.InitialIntervalSeconds:
+		return fmt.Errorf("%s retry: maximum interval of %d seconds is below the initial interval", name, r.MaximumIntervalSeconds)
+	case r.MaximumAttempts < 0:
+		return fmt.Errorf("%s retry: maximum attempts cannot be negative, got %d", name, r.MaximumAttempts)
+	}
+	return nil
+}
+
+// InvoiceConfig holds invoice numbering and the branding printed on rendered invoices
+type InvoiceConfig struct {
+	IssuerID      string // selects the invoice number sequence
+	NumberFormat  string // e.g. "INV-{YYYY}-{SEQ:6}", see formatInvoiceNumber
+	IssuerName    string
+	IssuerAddress string // may contain newlines
+	LogoPath      string // PNG file, optional
 }
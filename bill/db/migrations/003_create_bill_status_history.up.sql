@@ -0,0 +1,12 @@
+CREATE TABLE bill_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    bill_id TEXT NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT,
+    actor TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_bill_status_history_bill_id ON bill_status_history(bill_id);
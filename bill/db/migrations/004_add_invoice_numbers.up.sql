@@ -0,0 +1,11 @@
+CREATE TABLE invoice_sequences (
+    issuer TEXT NOT NULL,
+    year INT NOT NULL,
+    last_value BIGINT NOT NULL,
+    PRIMARY KEY (issuer, year)
+);
+
+ALTER TABLE bills ADD COLUMN invoice_number TEXT;
+
+-- Invoice numbers are unique and searchable
+CREATE UNIQUE INDEX idx_bills_invoice_number ON bills(invoice_number);
@@ -0,0 +1,29 @@
+ALTER TABLE bills ADD COLUMN account_id TEXT;
+
+CREATE INDEX idx_bills_account_id ON bills(account_id);
+
+CREATE TABLE meters (
+    key TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    description TEXT NOT NULL,
+    unit_amount BIGINT NOT NULL CHECK (unit_amount >= 0),
+    PRIMARY KEY (key, currency)
+);
+
+-- Usage events are deduplicated per account by the caller-supplied idempotency key.
+-- bill_id and billed_at are set when the events are claimed by a closing bill.
+CREATE TABLE usage_events (
+    account_id TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    meter TEXT NOT NULL,
+    quantity BIGINT NOT NULL CHECK (quantity > 0),
+    occurred_at TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    bill_id TEXT,
+    billed_at TIMESTAMP,
+    PRIMARY KEY (account_id, idempotency_key)
+);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_usage_events_unbilled ON usage_events(account_id, occurred_at) WHERE bill_id IS NULL;
+CREATE INDEX idx_usage_events_bill_id ON usage_events(bill_id, billed_at);
@@ -0,0 +1,28 @@
+CREATE TABLE products (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE prices (
+    id TEXT PRIMARY KEY,
+    product_id TEXT NOT NULL REFERENCES products(id),
+    currency TEXT NOT NULL,
+    model TEXT NOT NULL,
+    unit_amount BIGINT,
+    tiers JSONB,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Catalog-priced line items record quantity, unit price and their catalog references
+ALTER TABLE line_items
+    ADD COLUMN quantity BIGINT NOT NULL DEFAULT 1,
+    ADD COLUMN unit_amount BIGINT,
+    ADD COLUMN product_id TEXT,
+    ADD COLUMN price_id TEXT;
+
+-- Add indexes for better query performance
+CREATE INDEX idx_prices_product_id ON prices(product_id);
+CREATE INDEX idx_line_items_price_id ON line_items(price_id);
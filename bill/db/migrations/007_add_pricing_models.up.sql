@@ -0,0 +1,8 @@
+-- Package prices charge unit_amount per started package of package_size units
+ALTER TABLE prices ADD COLUMN package_size BIGINT;
+
+-- Meters can use any pricing model; existing meters keep charging per unit
+ALTER TABLE meters
+    ADD COLUMN model TEXT NOT NULL DEFAULT 'per_unit',
+    ADD COLUMN package_size BIGINT,
+    ADD COLUMN tiers JSONB;
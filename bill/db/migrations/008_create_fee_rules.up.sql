@@ -0,0 +1,21 @@
+-- Fee rules without an account_id apply to every account
+CREATE TABLE fee_rules (
+    id TEXT PRIMARY KEY,
+    account_id TEXT,
+    name TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    percent_basis_points BIGINT NOT NULL DEFAULT 0 CHECK (percent_basis_points >= 0),
+    fixed_amount BIGINT NOT NULL DEFAULT 0 CHECK (fixed_amount >= 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Fee line items link back to the rule that charged them and, for per-item fees, the item they were charged on
+ALTER TABLE line_items
+    ADD COLUMN source_item_id TEXT,
+    ADD COLUMN fee_rule_id TEXT REFERENCES fee_rules(id);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_fee_rules_account_id ON fee_rules(account_id) WHERE active;
+CREATE INDEX idx_line_items_source_item_id ON line_items(bill_id, source_item_id) WHERE fee_rule_id IS NOT NULL;
@@ -0,0 +1,11 @@
+-- One unit of from_currency is worth rate units of to_currency from effective_date onwards
+CREATE TABLE fx_rates (
+    from_currency TEXT NOT NULL,
+    to_currency TEXT NOT NULL,
+    effective_date DATE NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (from_currency, to_currency, effective_date)
+);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_bills_closed_at ON bills(closed_at) WHERE closed_at IS NOT NULL;
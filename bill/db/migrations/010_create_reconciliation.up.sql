@@ -0,0 +1,29 @@
+-- One row per reconciliation workflow run; id is the Temporal run ID
+CREATE TABLE reconciliation_runs (
+    id TEXT PRIMARY KEY,
+    repair BOOLEAN NOT NULL,
+    bills_checked INT NOT NULL DEFAULT 0,
+    mismatches INT NOT NULL DEFAULT 0,
+    repaired INT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP
+);
+
+-- Every mismatch found by a run. recorded_amount is bills.total_amount when it was checked;
+-- expected_amount is the sum of the line items or the total held by the bill workflow.
+CREATE TABLE reconciliation_findings (
+    id BIGSERIAL PRIMARY KEY,
+    run_id TEXT NOT NULL REFERENCES reconciliation_runs(id),
+    bill_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('line_items', 'workflow')),
+    currency TEXT NOT NULL,
+    recorded_amount BIGINT NOT NULL,
+    expected_amount BIGINT NOT NULL,
+    repaired BOOLEAN NOT NULL DEFAULT FALSE,
+    found_at TIMESTAMP NOT NULL,
+    UNIQUE (run_id, bill_id, kind)
+);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_reconciliation_runs_started_at ON reconciliation_runs(started_at DESC);
+CREATE INDEX idx_reconciliation_findings_bill_id ON reconciliation_findings(bill_id);
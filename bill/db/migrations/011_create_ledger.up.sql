@@ -0,0 +1,92 @@
+-- Journal entries behind bill balances; see package ledger. Entries are append-only: a mistake is
+-- corrected by posting a reversing entry, never by editing one.
+CREATE TABLE ledger_entries (
+    id TEXT PRIMARY KEY,
+    bill_id TEXT NOT NULL REFERENCES bills(id),
+    kind TEXT NOT NULL CHECK (kind IN ('line_item', 'close', 'reopen', 'void', 'payment', 'refund')),
+    currency TEXT NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    memo TEXT NOT NULL DEFAULT '',
+    posted_at TIMESTAMP NOT NULL
+);
+
+-- Debits are positive and credits negative
+CREATE TABLE ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id TEXT NOT NULL REFERENCES ledger_entries(id),
+    account TEXT NOT NULL CHECK (account IN ('accounts_receivable', 'revenue', 'tax_payable', 'cash')),
+    amount BIGINT NOT NULL CHECK (amount <> 0)
+);
+
+-- A payment or refund reference is recorded once per bill, so a retried request cannot post twice
+CREATE UNIQUE INDEX idx_ledger_entries_reference ON ledger_entries(bill_id, kind, reference)
+    WHERE kind IN ('payment', 'refund');
+
+-- Add indexes for better query performance
+CREATE INDEX idx_ledger_entries_bill_id ON ledger_entries(bill_id, posted_at);
+CREATE INDEX idx_ledger_postings_entry_id ON ledger_postings(entry_id);
+
+-- Every entry must have at least two postings that sum to zero. The check is deferred to commit,
+-- when all postings of the entry have been inserted.
+CREATE FUNCTION check_ledger_entry_balanced() RETURNS TRIGGER AS $$
+DECLARE
+    postings INT;
+    total BIGINT;
+BEGIN
+    SELECT COUNT(*), COALESCE(SUM(amount), 0) INTO postings, total
+    FROM ledger_postings WHERE entry_id = NEW.id;
+    IF postings < 2 OR total <> 0 THEN
+        RAISE EXCEPTION 'ledger entry % is unbalanced: % postings summing to %', NEW.id, postings, total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entry_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_ledger_entry_balanced();
+
+CREATE FUNCTION reject_ledger_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME USING ERRCODE = 'check_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_change();
+
+CREATE TRIGGER ledger_postings_append_only
+    BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_change();
+
+-- Backfill: one charge per existing line item, and the reversal of the charges on voided bills
+INSERT INTO ledger_entries (id, bill_id, kind, currency, reference, memo, posted_at)
+SELECT 'backfill-' || li.id, li.bill_id, 'line_item', li.currency, li.id, 'backfill', li.created_at
+FROM line_items li
+WHERE li.amount <> 0;
+
+INSERT INTO ledger_postings (entry_id, account, amount)
+SELECT 'backfill-' || li.id, p.account, p.sign * li.amount
+FROM line_items li
+CROSS JOIN (VALUES ('accounts_receivable', 1), ('revenue', -1)) AS p(account, sign)
+WHERE li.amount <> 0;
+
+INSERT INTO ledger_entries (id, bill_id, kind, currency, reference, memo, posted_at)
+SELECT 'backfill-void-' || b.id, b.id, 'void', b.currency, '', 'backfill', NOW()
+FROM bills b
+JOIN line_items li ON li.bill_id = b.id
+WHERE b.status = 'VOID'
+GROUP BY b.id
+HAVING SUM(li.amount) <> 0;
+
+INSERT INTO ledger_postings (entry_id, account, amount)
+SELECT 'backfill-void-' || b.id, p.account, p.sign * SUM(li.amount)
+FROM bills b
+JOIN line_items li ON li.bill_id = b.id
+CROSS JOIN (VALUES ('revenue', 1), ('accounts_receivable', -1)) AS p(account, sign)
+WHERE b.status = 'VOID'
+GROUP BY b.id, p.account, p.sign
+HAVING SUM(li.amount) <> 0;
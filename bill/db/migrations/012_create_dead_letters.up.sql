@@ -0,0 +1,18 @@
+-- Add item signals the bill workflow could not apply. payload is the AddItemSignal as received;
+-- error is the rejection or the last activity error.
+CREATE TABLE dead_letters (
+    id TEXT PRIMARY KEY,
+    bill_id TEXT NOT NULL REFERENCES bills(id),
+    item_id TEXT NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('invalid', 'not_open', 'failed')),
+    error TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'retried', 'discarded')),
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT
+);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_dead_letters_status ON dead_letters(status, created_at);
+CREATE INDEX idx_dead_letters_bill_id ON dead_letters(bill_id);
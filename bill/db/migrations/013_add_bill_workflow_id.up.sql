@@ -0,0 +1,3 @@
+-- The workflow that owns the bill. Rows are inserted by BillWorkflow itself, keyed on its workflow ID,
+-- so a retried insert is a no-op. Rows created before this column are linked by the startup sweep.
+ALTER TABLE bills ADD COLUMN workflow_id TEXT UNIQUE;
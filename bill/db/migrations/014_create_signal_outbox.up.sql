@@ -0,0 +1,16 @@
+-- Signals accepted while Temporal was unreachable, delivered in id order once it recovers
+CREATE TABLE signal_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    workflow_id TEXT NOT NULL,
+    signal_name TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP
+);
+
+-- Add indexes for better query performance
+CREATE INDEX idx_signal_outbox_pending ON signal_outbox(id) WHERE status = 'pending';
+CREATE INDEX idx_signal_outbox_workflow_id ON signal_outbox(workflow_id) WHERE status = 'pending';
@@ -0,0 +1,22 @@
+-- The check on ledger_entries only runs for new entries, so a posting added to an existing entry
+-- in a later transaction would unbalance it unnoticed. Re-check the entry of every new posting,
+-- also deferred to commit.
+CREATE FUNCTION check_ledger_posting_entry_balanced() RETURNS TRIGGER AS $$
+DECLARE
+    postings INT;
+    total BIGINT;
+BEGIN
+    SELECT COUNT(*), COALESCE(SUM(amount), 0) INTO postings, total
+    FROM ledger_postings WHERE entry_id = NEW.entry_id;
+    IF postings < 2 OR total <> 0 THEN
+        RAISE EXCEPTION 'ledger entry % is unbalanced: % postings summing to %', NEW.entry_id, postings, total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_posting_entry_balanced
+    AFTER INSERT ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_ledger_posting_entry_balanced();
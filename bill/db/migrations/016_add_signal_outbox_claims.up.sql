@@ -0,0 +1,3 @@
+-- Signals are claimed for delivery in a short transaction and delivered after it commits. A claim
+-- expires, so signals claimed by an instance that stopped part way are delivered by another.
+ALTER TABLE signal_outbox ADD COLUMN claimed_until TIMESTAMP;
@@ -0,0 +1,5 @@
+-- A retry claims the letter before it signals the bill, so a discard cannot race with it.
+-- resolved_at and resolved_by record the claim until the retry completes.
+ALTER TABLE dead_letters DROP CONSTRAINT dead_letters_status_check;
+ALTER TABLE dead_letters ADD CONSTRAINT dead_letters_status_check
+    CHECK (status IN ('pending', 'retrying', 'retried', 'discarded'));
@@ -0,0 +1,280 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/metrics"
+	"github.com/google/uuid"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// DeadLetterReason says why the workflow could not apply an add item signal
+type DeadLetterReason string
+
+const (
+	// ReasonInvalid: the signal failed validation
+	ReasonInvalid DeadLetterReason = "invalid"
+	// ReasonNotOpen: the bill was closed or voided when the signal was handled
+	ReasonNotOpen DeadLetterReason = "not_open"
+	// ReasonFailed: the item could not be priced or inserted, after retries
+	ReasonFailed DeadLetterReason = "failed"
+)
+
+type DeadLetterStatus string
+
+const (
+	DeadLetterPending DeadLetterStatus = "pending"
+	// DeadLetterRetrying: a retry claimed the letter and is signalling the bill
+	DeadLetterRetrying  DeadLetterStatus = "retrying"
+	DeadLetterRetried   DeadLetterStatus = "retried"
+	DeadLetterDiscarded DeadLetterStatus = "discarded"
+)
+
+// deadLetterClaimTimeout is how long a retry holds its claim before another retry may take over,
+// for a retry that stopped between claiming the letter and resolving it
+const deadLetterClaimTimeout = time.Minute
+
+// DeadLetter is an add item signal the bill workflow rejected, kept so the charge is not lost
+type DeadLetter struct {
+	ID         string           `json:"id"`
+	BillID     string           `json:"bill_id"`
+	Reason     DeadLetterReason `json:"reason"`
+	Error      string           `json:"error"`
+	Signal     AddItemSignal    `json:"signal"`
+	Status     DeadLetterStatus `json:"status"`
+	CreatedAt  time.Time        `json:"created_at"`
+	ResolvedAt *time.Time       `json:"resolved_at,omitempty"`
+	ResolvedBy string           `json:"resolved_by,omitempty"`
+}
+
+type DeadLetterLabels struct {
+	Reason string
+}
+
+// deadLetters counts rejected add item signals by reason
+var deadLetters = metrics.NewCounterGroup[DeadLetterLabels, uint64]("bill_dead_letters", metrics.CounterConfig{})
+
+// deadLetterNamespace derives dead letter IDs from the activity that records them
+var deadLetterNamespace = uuid.MustParse("5f0c2b7e-9a41-4d83-b6e2-8c1f3a7d9e54")
+
+// deadLetterVersion gates the DeadLetterActivity call, so histories of bills that rejected
+// signals before dead letters existed still replay
+const deadLetterVersion = "dead-letter-rejected-items"
+
+type DeadLetterInput struct {
+	BillID  string
+	Reason  DeadLetterReason
+	Error   string
+	Signals []AddItemSignal
+	At      time.Time
+}
+
+// DeadLetterActivity records rejected signals, one dead letter each. IDs are derived from the
+// activity, so a retry records the same letters again and they are skipped.
+func DeadLetterActivity(ctx context.Context, input DeadLetterInput) error {
+	info := activity.GetInfo(ctx)
+	letters := make([]*DeadLetter, len(input.Signals))
+	for i, s := range input.Signals {
+		name := fmt.Sprintf("%s/%s/%d", info.WorkflowExecution.RunID, info.ActivityID, i)
+		letters[i] = &DeadLetter{
+			ID:        uuid.NewSHA1(deadLetterNamespace, []byte(name)).String(),
+			BillID:    input.BillID,
+			Reason:    input.Reason,
+			Error:     input.Error,
+			Signal:    s,
+			CreatedAt: input.At,
+		}
+	}
+
+	inserted, err := InsertDeadLetters(ctx, letters)
+	if err != nil {
+		return err
+	}
+	deadLetters.With(DeadLetterLabels{Reason: string(input.Reason)}).Add(uint64(inserted))
+	return nil
+}
+
+// deadLetter records add item signals the workflow could not apply. A failure to record them is
+// logged, as the signals have nowhere else to go.
+func deadLetter(ctx workflow.Context, state *BillState, reason DeadLetterReason, cause error, signals ...AddItemSignal) {
+	billLogger(ctx, state).Error("rejected add item signal",
+		"reason", reason, "error", cause, "items", len(signals))
+	recordSignalRejected(ctx, "add-item", string(reason), len(signals))
+	if workflow.GetVersion(ctx, deadLetterVersion, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return
+	}
+
+	err := workflow.ExecuteActivity(ctx, DeadLetterActivity, DeadLetterInput{
+		BillID:  state.BillID,
+		Reason:  reason,
+		Error:   cause.Error(),
+		Signals: signals,
+		At:      workflow.Now(ctx),
+	}).Get(ctx, nil)
+	if err != nil {
+		billLogger(ctx, state).Error("failed to record dead letters", "error", err)
+	}
+}
+
+type ListDeadLettersRequest struct {
+	Status string `query:"status"` // defaults to pending; "all" lists every status
+	BillID string `query:"bill_id"`
+	Limit  int    `query:"limit"` // defaults to 100, at most 1000
+}
+
+type ListDeadLettersResponse struct {
+	DeadLetters []*DeadLetter `json:"dead_letters"`
+}
+
+// ListDeadLettersAPI lists rejected add item signals, oldest first. Requires the admin role.
+//
+//encore:api auth method=GET path=/admin/dead-letters
+func ListDeadLettersAPI(ctx context.Context, req ListDeadLettersRequest) (*ListDeadLettersResponse, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	status := DeadLetterStatus(strings.TrimSpace(req.Status))
+	switch status {
+	case "":
+		status = DeadLetterPending
+	case "all":
+		status = ""
+	case DeadLetterPending, DeadLetterRetrying, DeadLetterRetried, DeadLetterDiscarded:
+	default:
+		return nil, errs.WrapCode(errors.New("invalid status"), errs.InvalidArgument, "status must be pending, retrying, retried, discarded or all")
+	}
+	if req.BillID != "" {
+		if err := validateUUID(req.BillID); err != nil {
+			return nil, err
+		}
+	}
+	if req.Limit < 0 || req.Limit > 1000 {
+		return nil, errs.WrapCode(errors.New("invalid limit"), errs.InvalidArgument, "limit must be between 1 and 1000")
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	letters, err := ListDeadLetters(ctx, status, req.BillID, req.Limit)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list dead letters")
+	}
+	return &ListDeadLettersResponse{DeadLetters: letters}, nil
+}
+
+// RetryDeadLetterAPI signals a dead letter's item to its bill again. The bill must be open; an item
+// rejected again is recorded as a new dead letter. The letter is claimed before the signal is sent,
+// so it cannot be discarded or retried twice meanwhile, and released if the signal fails.
+// Requires the admin role.
+//
+//encore:api auth method=POST path=/admin/dead-letters/:id/retry
+func RetryDeadLetterAPI(ctx context.Context, id string) (*DeadLetter, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	letter, err := getDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if letter.Status != DeadLetterPending && letter.Status != DeadLetterRetrying {
+		return nil, errs.WrapCode(ErrDeadLetterResolved, errs.FailedPrecondition, "dead letter was already "+string(letter.Status))
+	}
+	if letter.Reason == ReasonInvalid {
+		return nil, errs.WrapCode(errors.New("invalid signal"), errs.FailedPrecondition, "an invalid signal would be rejected again, discard it instead")
+	}
+
+	if GetTemporalClient() == nil {
+		return nil, errs.WrapCode(nil, errs.Unavailable,
+			"bill operations unavailable - Temporal workflow service is down")
+	}
+	bill, err := GetByID(ctx, letter.BillID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.Status != Open {
+		return nil, errs.WrapCode(errors.New("bill is not open"), errs.FailedPrecondition, "bill is not open")
+	}
+
+	actor, _ := auth.UserID()
+	err = ClaimDeadLetter(ctx, letter.ID, string(actor), time.Now(), deadLetterClaimTimeout)
+	if errors.Is(err, ErrDeadLetterResolved) {
+		return nil, errs.WrapCode(err, errs.FailedPrecondition, "dead letter is being retried or was already resolved")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to claim dead letter")
+	}
+
+	err = GetTemporalClient().SignalWorkflow(ctx, "bill-"+bill.ID, "", "add-item", letter.Signal)
+	if err != nil {
+		if releaseErr := ReleaseDeadLetter(ctx, letter.ID); releaseErr != nil {
+			logger.ErrorContext(ctx, "Failed to release dead letter", "deadLetterID", letter.ID, "error", releaseErr)
+		}
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return nil, errs.WrapCode(err, errs.FailedPrecondition, "bill workflow is no longer running")
+		}
+		return nil, errs.Wrap(err, "failed to signal workflow")
+	}
+
+	return resolveDeadLetter(ctx, letter, DeadLetterRetrying, DeadLetterRetried)
+}
+
+// DiscardDeadLetterAPI marks a dead letter as handled without charging it. Requires the admin role.
+//
+//encore:api auth method=POST path=/admin/dead-letters/:id/discard
+func DiscardDeadLetterAPI(ctx context.Context, id string) (*DeadLetter, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	letter, err := getDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if letter.Status != DeadLetterPending {
+		return nil, errs.WrapCode(ErrDeadLetterResolved, errs.FailedPrecondition, "dead letter was already "+string(letter.Status))
+	}
+	return resolveDeadLetter(ctx, letter, DeadLetterPending, DeadLetterDiscarded)
+}
+
+func getDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+
+	letter, err := GetDeadLetter(ctx, id)
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		return nil, errs.WrapCode(err, errs.NotFound, "dead letter not found")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to get dead letter")
+	}
+	return letter, nil
+}
+
+func resolveDeadLetter(ctx context.Context, letter *DeadLetter, from, status DeadLetterStatus) (*DeadLetter, error) {
+	actor, _ := auth.UserID()
+	now := time.Now()
+	err := ResolveDeadLetter(ctx, letter.ID, from, status, string(actor), now)
+	if errors.Is(err, ErrDeadLetterResolved) {
+		return nil, errs.WrapCode(err, errs.FailedPrecondition, "dead letter was already resolved")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to resolve dead letter")
+	}
+
+	letter.Status = status
+	letter.ResolvedAt = &now
+	letter.ResolvedBy = string(actor)
+	return letter, nil
+}
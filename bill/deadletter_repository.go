@@ -0,0 +1,185 @@
+package bill
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrDeadLetterResolved is returned when a dead letter was already retried or discarded
+	ErrDeadLetterResolved = errors.New("dead letter already resolved")
+)
+
+// InsertDeadLetters records rejected signals and returns how many were new. Letters whose ID is
+// already recorded are skipped, so a retried activity records each rejection once.
+func InsertDeadLetters(ctx context.Context, letters []*DeadLetter) (int, error) {
+	ctx, span := startDBSpan(ctx, "InsertDeadLetters", "")
+	defer span.End()
+
+	var (
+		ids        = make([]string, len(letters))
+		billIDs    = make([]string, len(letters))
+		itemIDs    = make([]string, len(letters))
+		reasons    = make([]string, len(letters))
+		messages   = make([]string, len(letters))
+		payloads   = make([]string, len(letters))
+		createdAts = make([]time.Time, len(letters))
+	)
+	for i, l := range letters {
+		payload, err := json.Marshal(l.Signal)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode dead letter %s: %w", l.ID, err)
+		}
+		ids[i] = l.ID
+		billIDs[i] = l.BillID
+		itemIDs[i] = l.Signal.ItemID
+		reasons[i] = string(l.Reason)
+		messages[i] = l.Error
+		payloads[i] = string(payload)
+		createdAts[i] = l.CreatedAt
+	}
+
+	result, err := db.Exec(ctx, `
+		INSERT INTO dead_letters (id, bill_id, item_id, reason, error, payload, created_at)
+		SELECT id, bill_id, item_id, reason, error, payload::jsonb, created_at
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::timestamp[])
+			AS l(id, bill_id, item_id, reason, error, payload, created_at)
+		ON CONFLICT (id) DO NOTHING
+	`, ids, billIDs, itemIDs, reasons, messages, payloads, createdAts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert %d dead letters: %w", len(letters), err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	ctx, span := startDBSpan(ctx, "GetDeadLetter", "")
+	defer span.End()
+
+	row := db.QueryRow(ctx, `
+		SELECT id, bill_id, reason, error, payload, status, created_at, resolved_at, resolved_by
+		FROM dead_letters
+		WHERE id = $1
+	`, id)
+	l, err := scanDeadLetter(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("dead letter %s: %w", id, ErrDeadLetterNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter %s: %w", id, err)
+	}
+	return l, nil
+}
+
+// ListDeadLetters returns dead letters oldest first, filtered by status and bill when those are set
+func ListDeadLetters(ctx context.Context, status DeadLetterStatus, billID string, limit int) ([]*DeadLetter, error) {
+	ctx, span := startDBSpan(ctx, "ListDeadLetters", billID)
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT id, bill_id, reason, error, payload, status, created_at, resolved_at, resolved_by
+		FROM dead_letters
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR bill_id = $2)
+		ORDER BY created_at, id
+		LIMIT $3
+	`, status, billID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []*DeadLetter
+	for rows.Next() {
+		l, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, l)
+	}
+	return letters, rows.Err()
+}
+
+// ClaimDeadLetter moves a pending dead letter to retrying, recording who claimed it and when. A
+// letter left retrying for longer than timeout may be claimed again. It returns
+// ErrDeadLetterResolved when the letter is resolved or another retry holds it.
+func ClaimDeadLetter(ctx context.Context, id, actor string, at time.Time, timeout time.Duration) error {
+	ctx, span := startDBSpan(ctx, "ClaimDeadLetter", "")
+	defer span.End()
+
+	result, err := db.Exec(ctx, `
+		UPDATE dead_letters SET status = 'retrying', resolved_at = $2, resolved_by = NULLIF($3, '')
+		WHERE id = $1 AND (status = 'pending' OR (status = 'retrying' AND resolved_at < $4))
+	`, id, at, actor, at.Add(-timeout))
+	if err != nil {
+		return fmt.Errorf("failed to claim dead letter %s: %w", id, err)
+	}
+	if result.RowsAffected() == 0 {
+		if _, err := GetDeadLetter(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("dead letter %s: %w", id, ErrDeadLetterResolved)
+	}
+	return nil
+}
+
+// ReleaseDeadLetter returns a dead letter claimed by a retry that failed to pending
+func ReleaseDeadLetter(ctx context.Context, id string) error {
+	ctx, span := startDBSpan(ctx, "ReleaseDeadLetter", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		UPDATE dead_letters SET status = 'pending', resolved_at = NULL, resolved_by = NULL
+		WHERE id = $1 AND status = 'retrying'
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to release dead letter %s: %w", id, err)
+	}
+	return nil
+}
+
+// ResolveDeadLetter moves a dead letter from status from to status. The letter is only changed
+// while it is still in from, so two admins cannot both resolve it.
+func ResolveDeadLetter(ctx context.Context, id string, from, status DeadLetterStatus, actor string, at time.Time) error {
+	ctx, span := startDBSpan(ctx, "ResolveDeadLetter", "")
+	defer span.End()
+
+	result, err := db.Exec(ctx, `
+		UPDATE dead_letters SET status = $3, resolved_at = $4, resolved_by = NULLIF($5, '')
+		WHERE id = $1 AND status = $2
+	`, id, from, status, at, actor)
+	if err != nil {
+		return fmt.Errorf("failed to resolve dead letter %s: %w", id, err)
+	}
+	if result.RowsAffected() == 0 {
+		if _, err := GetDeadLetter(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("dead letter %s: %w", id, ErrDeadLetterResolved)
+	}
+	return nil
+}
+
+func scanDeadLetter(row interface{ Scan(...interface{}) error }) (*DeadLetter, error) {
+	var (
+		l          DeadLetter
+		payload    []byte
+		resolvedAt sql.NullTime
+		resolvedBy sql.NullString
+	)
+	if err := row.Scan(&l.ID, &l.BillID, &l.Reason, &l.Error, &payload, &l.Status, &l.CreatedAt, &resolvedAt, &resolvedBy); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &l.Signal); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letter %s: %w", l.ID, err)
+	}
+	if resolvedAt.Valid {
+		l.ResolvedAt = &resolvedAt.Time
+	}
+	l.ResolvedBy = resolvedBy.String
+	return &l, nil
+}
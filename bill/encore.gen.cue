@@ -29,5 +29,12 @@ package bill
 // as fields at the package level.
 #Config: {
 	TemporalServer: string
+	Invoice: {
+		IssuerID:      string
+		NumberFormat:  string
+		IssuerName:    string
+		IssuerAddress: string
+		LogoPath:      string
+	}
 }
 #Config
\ No newline at end of file
@@ -0,0 +1,314 @@
+package bill
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fees-api/money"
+
+	"encore.dev/beta/errs"
+)
+
+// exportFlushEvery is how many rows are written between flushes to the client
+const exportFlushEvery = 500
+
+// exportFilter selects the rows of an export; From and To apply to created_at and To is exclusive
+type exportFilter struct {
+	From, To  *time.Time
+	Status    Status
+	Currency  money.Currency
+	AccountID string
+	BillID    string
+}
+
+// exportRow is one row of an export. Columns and record must list fields in the same order,
+// which is also the order of the JSON fields.
+type exportRow interface {
+	record() []string
+}
+
+var billExportColumns = []string{
+	"id", "account_id", "status", "currency", "total_minor", "total",
+	"invoice_number", "created_at", "closed_at",
+}
+
+type billExportRow struct {
+	ID            string         `json:"id"`
+	AccountID     string         `json:"account_id"`
+	Status        Status         `json:"status"`
+	Currency      money.Currency `json:"currency"`
+	TotalMinor    int64          `json:"total_minor"`
+	Total         string         `json:"total"`
+	InvoiceNumber string         `json:"invoice_number"`
+	CreatedAt     string         `json:"created_at"`
+	ClosedAt      string         `json:"closed_at"`
+}
+
+func newBillExportRow(b *Bill) billExportRow {
+	row := billExportRow{
+		ID:            b.ID,
+		AccountID:     b.AccountID,
+		Status:        b.Status,
+		Currency:      b.Total.Currency,
+		TotalMinor:    b.Total.Amount,
+		Total:         b.Total.Decimal(),
+		InvoiceNumber: b.InvoiceNumber,
+		CreatedAt:     exportTime(b.CreatedAt),
+	}
+	if b.ClosedAt != nil {
+		row.ClosedAt = exportTime(*b.ClosedAt)
+	}
+	return row
+}
+
+func (r billExportRow) record() []string {
+	return []string{
+		r.ID, r.AccountID, string(r.Status), string(r.Currency), strconv.FormatInt(r.TotalMinor, 10), r.Total,
+		r.InvoiceNumber, r.CreatedAt, r.ClosedAt,
+	}
+}
+
+var lineItemExportColumns = []string{
+	"id", "bill_id", "currency", "amount_minor", "amount", "quantity", "unit_amount_minor", "unit_amount",
+	"description", "product_id", "price_id", "source_item_id", "fee_rule_id", "created_at",
+}
+
+type lineItemExportRow struct {
+	ID              string         `json:"id"`
+	BillID          string         `json:"bill_id"`
+	Currency        money.Currency `json:"currency"`
+	AmountMinor     int64          `json:"amount_minor"`
+	Amount          string         `json:"amount"`
+	Quantity        int64          `json:"quantity"`
+	UnitAmountMinor *int64         `json:"unit_amount_minor"`
+	UnitAmount      string         `json:"unit_amount"`
+	Description     string         `json:"description"`
+	ProductID       string         `json:"product_id"`
+	PriceID         string         `json:"price_id"`
+	SourceItemID    string         `json:"source_item_id"`
+	FeeRuleID       string         `json:"fee_rule_id"`
+	CreatedAt       string         `json:"created_at"`
+}
+
+func newLineItemExportRow(li *LineItem) lineItemExportRow {
+	row := lineItemExportRow{
+		ID:           li.ID,
+		BillID:       li.BillID,
+		Currency:     li.Amount.Currency,
+		AmountMinor:  li.Amount.Amount,
+		Amount:       li.Amount.Decimal(),
+		Quantity:     li.quantity(),
+		Description:  li.Description,
+		ProductID:    li.ProductID,
+		PriceID:      li.PriceID,
+		SourceItemID: li.SourceItemID,
+		FeeRuleID:    li.FeeRuleID,
+		CreatedAt:    exportTime(li.CreatedAt),
+	}
+	if li.UnitAmount != nil {
+		row.UnitAmountMinor = &li.UnitAmount.Amount
+		row.UnitAmount = li.UnitAmount.Decimal()
+	}
+	return row
+}
+
+func (r lineItemExportRow) record() []string {
+	var unitMinor string
+	if r.UnitAmountMinor != nil {
+		unitMinor = strconv.FormatInt(*r.UnitAmountMinor, 10)
+	}
+	return []string{
+		r.ID, r.BillID, string(r.Currency), strconv.FormatInt(r.AmountMinor, 10), r.Amount,
+		strconv.FormatInt(r.Quantity, 10), unitMinor, r.UnitAmount,
+		r.Description, r.ProductID, r.PriceID, r.SourceItemID, r.FeeRuleID, r.CreatedAt,
+	}
+}
+
+// exportTime renders timestamps as RFC 3339 in UTC
+func exportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ExportBillsAPI streams the bills created in a date range as CSV (default) or NDJSON.
+// Query parameters: format, from, to, status, currency, account_id. Requires the admin role.
+//
+//encore:api auth raw method=GET path=/exports/bills
+func ExportBillsAPI(w http.ResponseWriter, req *http.Request) {
+	runExport(w, req, "bills", billExportColumns, func(enc *exportEncoder, f exportFilter) error {
+		return StreamBills(req.Context(), f, func(b *Bill) error {
+			return enc.write(newBillExportRow(b))
+		})
+	})
+}
+
+// ExportLineItemsAPI streams the line items created in a date range as CSV (default) or NDJSON.
+// Query parameters: format, from, to, status, currency and account_id of the bill, bill_id.
+// Requires the admin role.
+//
+//encore:api auth raw method=GET path=/exports/line-items
+func ExportLineItemsAPI(w http.ResponseWriter, req *http.Request) {
+	runExport(w, req, "line-items", lineItemExportColumns, func(enc *exportEncoder, f exportFilter) error {
+		return StreamLineItems(req.Context(), f, func(li *LineItem) error {
+			return enc.write(newLineItemExportRow(li))
+		})
+	})
+}
+
+// runExport validates the request, writes the response headers and streams rows through stream.
+// Once the first byte is written the status can no longer change, so a later failure is logged and
+// aborts the response, which the client sees as a broken download rather than a complete file.
+func runExport(w http.ResponseWriter, req *http.Request, name string, columns []string, stream func(*exportEncoder, exportFilter) error) {
+	if err := requireAdmin(); err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	query := req.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	filter, err := parseExportFilter(query)
+	if err != nil {
+		errs.HTTPError(w, errs.WrapCode(err, errs.InvalidArgument, err.Error()))
+		return
+	}
+
+	enc, err := newExportEncoder(w, format, columns)
+	if err != nil {
+		errs.HTTPError(w, errs.WrapCode(err, errs.InvalidArgument, err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", enc.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, enc.extension))
+	writeExport(req.Context(), enc, name, func() error { return stream(enc, filter) })
+}
+
+// writeExport writes the export through enc. A failure part way panics with http.ErrAbortHandler,
+// so the server drops the connection instead of ending a truncated response as if it were complete.
+func writeExport(ctx context.Context, enc *exportEncoder, name string, stream func() error) {
+	err := enc.start()
+	if err == nil {
+		err = stream()
+	}
+	if err == nil {
+		err = enc.flush()
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Export stopped early", "export", name, "rows", enc.rows, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// parseExportFilter validates the filter query parameters shared by all exports
+func parseExportFilter(query url.Values) (exportFilter, error) {
+	f := exportFilter{
+		Status:    Status(strings.TrimSpace(query.Get("status"))),
+		Currency:  money.Currency(strings.TrimSpace(query.Get("currency"))),
+		AccountID: strings.TrimSpace(query.Get("account_id")),
+		BillID:    strings.TrimSpace(query.Get("bill_id")),
+	}
+
+	if f.Status != "" && !f.Status.IsValid() {
+		return exportFilter{}, errors.New("status must be OPEN, CLOSED or VOID")
+	}
+	if f.Currency != "" && !f.Currency.IsValid() {
+		return exportFilter{}, fmt.Errorf("invalid currency: %s", f.Currency)
+	}
+	if len(f.AccountID) > maxAccountIDLength {
+		return exportFilter{}, errors.New("account_id max 100 chars")
+	}
+	if f.BillID != "" {
+		if err := validateItemID(f.BillID); err != nil {
+			return exportFilter{}, errors.New("bill_id must be a valid UUID")
+		}
+	}
+
+	from, to, err := parseDateRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		return exportFilter{}, err
+	}
+	f.From, f.To = from, to
+	return f, nil
+}
+
+// exportEncoder writes rows as CSV with a header line, or as one JSON object per line
+type exportEncoder struct {
+	contentType string
+	extension   string
+	columns     []string
+	rows        int
+
+	out     io.Writer
+	flusher http.Flusher
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+func newExportEncoder(w http.ResponseWriter, format string, columns []string) (*exportEncoder, error) {
+	enc := &exportEncoder{columns: columns, out: w}
+	enc.flusher, _ = w.(http.Flusher)
+
+	switch format {
+	case "csv":
+		enc.contentType = "text/csv; charset=utf-8"
+		enc.extension = "csv"
+		enc.csv = csv.NewWriter(w)
+	case "ndjson":
+		enc.contentType = "application/x-ndjson"
+		enc.extension = "ndjson"
+		enc.json = json.NewEncoder(w)
+	default:
+		return nil, errors.New("format must be csv or ndjson")
+	}
+	return enc, nil
+}
+
+// start writes the CSV header; NDJSON has none
+func (e *exportEncoder) start() error {
+	if e.csv == nil {
+		return nil
+	}
+	return e.csv.Write(e.columns)
+}
+
+func (e *exportEncoder) write(row exportRow) error {
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(row.record())
+	} else {
+		err = e.json.Encode(row)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%exportFlushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
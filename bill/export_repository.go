@@ -0,0 +1,96 @@
+package bill
+
+import (
+	"context"
+	"fmt"
+)
+
+// StreamBills calls fn for every bill matching the filter, oldest first, reading rows from the
+// database as they are consumed. It stops at the first error returned by fn.
+func StreamBills(ctx context.Context, f exportFilter, fn func(*Bill) error) error {
+	ctx, span := startDBSpan(ctx, "StreamBills", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+        SELECT
+            id,
+            account_id,
+            currency,
+            status,
+            total_amount,
+            created_at,
+            closed_at,
+            invoice_number
+        FROM bills
+        WHERE ($1::timestamp IS NULL OR created_at >= $1)
+          AND ($2::timestamp IS NULL OR created_at < $2)
+          AND ($3 = '' OR status = $3)
+          AND ($4 = '' OR currency = $4)
+          AND ($5 = '' OR account_id = $5)
+          AND ($6 = '' OR id = $6)
+        ORDER BY created_at, id
+    `, f.From, f.To, f.Status, f.Currency, f.AccountID, f.BillID)
+	if err != nil {
+		return fmt.Errorf("failed to query bills for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bill, err := scanBill(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(bill); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamLineItems calls fn for every line item created in the filter's range whose bill matches
+// the remaining filters, oldest first, reading rows from the database as they are consumed.
+func StreamLineItems(ctx context.Context, f exportFilter, fn func(*LineItem) error) error {
+	ctx, span := startDBSpan(ctx, "StreamLineItems", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+        SELECT li.bill_id, li.id, li.amount, li.currency, li.description, li.created_at,
+               li.quantity, li.unit_amount, li.product_id, li.price_id, li.source_item_id, li.fee_rule_id
+        FROM line_items li
+        JOIN bills b ON b.id = li.bill_id
+        WHERE ($1::timestamp IS NULL OR li.created_at >= $1)
+          AND ($2::timestamp IS NULL OR li.created_at < $2)
+          AND ($3 = '' OR b.status = $3)
+          AND ($4 = '' OR b.currency = $4)
+          AND ($5 = '' OR b.account_id = $5)
+          AND ($6 = '' OR li.bill_id = $6)
+        ORDER BY li.created_at, li.id
+    `, f.From, f.To, f.Status, f.Currency, f.AccountID, f.BillID)
+	if err != nil {
+		return fmt.Errorf("failed to query line items for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var billID string
+		li, err := scanLineItem(billIDScanner{row: rows, billID: &billID}, "")
+		if err != nil {
+			return err
+		}
+		li.BillID = billID
+		if err := fn(li); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// billIDScanner reads a leading bill_id column before handing the rest of the row to scanLineItem
+type billIDScanner struct {
+	row    interface{ Scan(...interface{}) error }
+	billID *string
+}
+
+func (s billIDScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append([]interface{}{s.billID}, dest...)...)
+}
@@ -0,0 +1,180 @@
+package bill
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fees-api/money"
+)
+
+func TestExportSchemas(t *testing.T) {
+	closedAt := time.Date(2026, 5, 31, 18, 0, 0, 0, time.UTC)
+	unit := money.Money{Amount: 250, Currency: money.USD}
+
+	tests := []struct {
+		name    string
+		columns []string
+		row     exportRow
+	}{
+		{"bills", billExportColumns, newBillExportRow(&Bill{
+			ID:            "1b4e28ba-2fa1-41d2-883f-0016d3cca427",
+			AccountID:     "acct_123",
+			Status:        Closed,
+			Total:         money.Money{Amount: 123456, Currency: money.USD},
+			CreatedAt:     closedAt.Add(-time.Hour),
+			ClosedAt:      &closedAt,
+			InvoiceNumber: "INV-2026-000001",
+		})},
+		{"line items", lineItemExportColumns, newLineItemExportRow(&LineItem{
+			ID:          "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			BillID:      "1b4e28ba-2fa1-41d2-883f-0016d3cca427",
+			Amount:      money.Money{Amount: 750, Currency: money.USD},
+			Description: "Seats, \"annual\"",
+			CreatedAt:   closedAt,
+			Quantity:    3,
+			UnitAmount:  &unit,
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(tt.row.record()); got != len(tt.columns) {
+				t.Fatalf("record() has %d fields, want %d columns", got, len(tt.columns))
+			}
+
+			// The JSON field names must match the CSV columns in the same order
+			typ := reflect.TypeOf(tt.row)
+			for i, column := range tt.columns {
+				if tag := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]; tag != column {
+					t.Errorf("field %d is %q in JSON but %q in CSV", i, tag, column)
+				}
+			}
+		})
+	}
+}
+
+func TestExportEncoder(t *testing.T) {
+	row := newLineItemExportRow(&LineItem{
+		ID:          "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		BillID:      "1b4e28ba-2fa1-41d2-883f-0016d3cca427",
+		Amount:      money.Money{Amount: 105, Currency: money.GEL},
+		Description: "Setup, one-off",
+		CreatedAt:   time.Date(2026, 5, 1, 9, 30, 0, 0, time.FixedZone("GET", 4*60*60)),
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		enc, err := newExportEncoder(w, "csv", lineItemExportColumns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.start(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.write(row); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("output is not valid CSV: %v", err)
+		}
+		if len(records) != 2 || !reflect.DeepEqual(records[0], lineItemExportColumns) {
+			t.Fatalf("got records %q, want a header and one row", records)
+		}
+		got := records[1]
+		if got[3] != "105" || got[4] != "1.05" || got[5] != "1" || got[6] != "" || got[8] != "Setup, one-off" {
+			t.Errorf("unexpected row %q", got)
+		}
+		if got[13] != "2026-05-01T05:30:00Z" {
+			t.Errorf("created_at = %q, want UTC RFC 3339", got[13])
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		enc, err := newExportEncoder(w, "ndjson", lineItemExportColumns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.start(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := enc.write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+		if len(lines) != 2 {
+			t.Fatalf("got %d lines, want 2", len(lines))
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(lines[0], &decoded); err != nil {
+			t.Fatalf("line is not valid JSON: %v", err)
+		}
+		if decoded["amount_minor"] != float64(105) || decoded["amount"] != "1.05" || decoded["unit_amount_minor"] != nil {
+			t.Errorf("unexpected object %v", decoded)
+		}
+	})
+
+	if _, err := newExportEncoder(httptest.NewRecorder(), "xlsx", lineItemExportColumns); err == nil {
+		t.Error("newExportEncoder() expected an error for an unknown format")
+	}
+}
+
+func TestWriteExportAbortsOnFailure(t *testing.T) {
+	w := httptest.NewRecorder()
+	enc, err := newExportEncoder(w, "csv", lineItemExportColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Fatalf("got panic %v, want http.ErrAbortHandler", recovered)
+		}
+	}()
+	writeExport(context.Background(), enc, "line-items", func() error {
+		return errors.New("connection reset")
+	})
+}
+
+func TestParseExportFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"no filters", "", false},
+		{"all filters", "from=2026-05-01&to=2026-05-31&status=CLOSED&currency=USD&account_id=acct_123&bill_id=1b4e28ba-2fa1-41d2-883f-0016d3cca427", false},
+		{"bad status", "status=PAID", true},
+		{"bad currency", "currency=EUR", true},
+		{"bad bill id", "bill_id=123", true},
+		{"bad range", "from=2026-06-01&to=2026-05-01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			_, err := parseExportFilter(query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseExportFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fees-api/money"
+
+	"encore.dev/beta/errs"
+	"github.com/google/uuid"
+)
+
+// maxFeeBasisPoints caps percentage fees at 100%
+const maxFeeBasisPoints = 10000
+
+// feeItemNamespace derives stable line item IDs for fees so re-evaluating a rule replaces its fee
+var feeItemNamespace = uuid.MustParse("0b6d8a52-7c1e-4d0f-a3b9-5e2c4f7a1d86")
+
+type FeeScope string
+
+const (
+	// PerItem rules charge a fee on every line item added to a bill
+	PerItem FeeScope = "item"
+	// PerBill rules charge a fee once, on the bill total, when the bill closes
+	PerBill FeeScope = "bill"
+)
+
+// FeeRule charges PercentBasisPoints hundredths of a percent of the base amount plus FixedAmount.
+// Rules without an AccountID apply to every account.
+type FeeRule struct {
+	ID                 string         `json:"id"`
+	AccountID          string         `json:"account_id,omitempty"`
+	Name               string         `json:"name"`
+	Scope              FeeScope       `json:"scope"`
+	Currency           money.Currency `json:"currency"`
+	PercentBasisPoints int64          `json:"percent_basis_points"`
+	FixedAmount        int64          `json:"fixed_amount"`
+	Active             bool           `json:"active"`
+	CreatedAt          time.Time      `json:"created_at"`
+}
+
+// validate checks that the rule charges something and is within bounds
+func (r *FeeRule) validate() error {
+	if !r.Currency.IsValid() {
+		return fmt.Errorf("invalid currency: %s", r.Currency)
+	}
+	if r.Scope != PerItem && r.Scope != PerBill {
+		return fmt.Errorf("scope must be %s or %s", PerItem, PerBill)
+	}
+	if r.PercentBasisPoints < 0 || r.PercentBasisPoints > maxFeeBasisPoints {
+		return errors.New("percent_basis_points must be between 0 and 10000")
+	}
+	if r.FixedAmount < 0 || r.FixedAmount > MaxAmountCents {
+		return errors.New("fixed_amount must be between 0 and the maximum item amount")
+	}
+	if r.PercentBasisPoints == 0 && r.FixedAmount == 0 {
+		return errors.New("a rule needs percent_basis_points or fixed_amount")
+	}
+	return nil
+}
+
+// feeFor returns the fee the rule charges on base
+func (r *FeeRule) feeFor(base money.Money) (money.Money, error) {
+	if base.Currency != r.Currency {
+		return money.Money{}, fmt.Errorf("fee rule %s is in %s but the amount is in %s", r.ID, r.Currency, base.Currency)
+	}
+	percentage, err := base.Percent(r.PercentBasisPoints)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return percentage.Add(money.Money{Amount: r.FixedAmount, Currency: r.Currency})
+}
+
+// computeFees returns the fee line items that rules of the given scope derive from base.
+// sourceItemID links per-item fees to the item they were charged on; it is empty for per-bill fees.
+// Rules that charge nothing on base, e.g. a percentage of zero, add no item.
+func computeFees(rules []FeeRule, scope FeeScope, billID, sourceItemID string, base money.Money, at time.Time) ([]AddLineItemInput, error) {
+	var fees []AddLineItemInput
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Scope != scope {
+			continue
+		}
+
+		amount, err := rule.feeFor(base)
+		if err != nil {
+			return nil, err
+		}
+		if amount.Amount <= 0 {
+			continue
+		}
+
+		fees = append(fees, AddLineItemInput{
+			ItemID:       feeItemID(billID, sourceItemID, rule.ID),
+			BillID:       billID,
+			Amount:       amount,
+			Description:  rule.Name,
+			CreatedAt:    at,
+			SourceItemID: sourceItemID,
+			FeeRuleID:    rule.ID,
+		})
+	}
+	return fees, nil
+}
+
+// feeItemID is stable for a bill, source item and rule
+func feeItemID(billID, sourceItemID, ruleID string) string {
+	name := fmt.Sprintf("%s/%s/%s", billID, sourceItemID, ruleID)
+	return uuid.NewSHA1(feeItemNamespace, []byte(name)).String()
+}
+
+type CreateFeeRuleRequest struct {
+	AccountID          string         `json:"account_id,omitempty"`
+	Name               string         `json:"name"`
+	Scope              FeeScope       `json:"scope"`
+	Currency           money.Currency `json:"currency"`
+	PercentBasisPoints int64          `json:"percent_basis_points,omitempty"`
+	FixedAmount        int64          `json:"fixed_amount,omitempty"`
+}
+
+type ListFeeRulesRequest struct {
+	AccountID string `query:"account_id"`
+}
+
+type ListFeeRulesResponse struct {
+	Rules []*FeeRule `json:"rules"`
+}
+
+// CreateFeeRuleAPI adds a fee rule for one account, or for every account when account_id is empty.
+// Requires the admin role.
+//
+//encore:api auth method=POST path=/fee-rules
+func CreateFeeRuleAPI(ctx context.Context, req CreateFeeRuleRequest) (*FeeRule, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	req.AccountID = strings.TrimSpace(req.AccountID)
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.AccountID) > maxAccountIDLength {
+		return nil, errs.WrapCode(errors.New("account_id max 100 chars"), errs.InvalidArgument, "account_id max 100 chars")
+	}
+	if err := validateItemDescription(req.Name); err != nil {
+		msg := "name: " + err.Error()
+		return nil, errs.WrapCode(errors.New(msg), errs.InvalidArgument, msg)
+	}
+
+	r := &FeeRule{
+		ID:                 uuid.NewString(),
+		AccountID:          req.AccountID,
+		Name:               req.Name,
+		Scope:              req.Scope,
+		Currency:           req.Currency,
+		PercentBasisPoints: req.PercentBasisPoints,
+		FixedAmount:        req.FixedAmount,
+		Active:             true,
+		CreatedAt:          time.Now(),
+	}
+	if err := r.validate(); err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	if err := CreateFeeRule(ctx, r); err != nil {
+		return nil, errs.Wrap(err, "failed to create fee rule")
+	}
+	return r, nil
+}
+
+// ListFeeRulesAPI lists fee rules, or only those of one account when account_id is set
+//
+//encore:api public method=GET path=/fee-rules
+func ListFeeRulesAPI(ctx context.Context, req ListFeeRulesRequest) (*ListFeeRulesResponse, error) {
+	rules, err := ListFeeRules(ctx, strings.TrimSpace(req.AccountID))
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list fee rules")
+	}
+	return &ListFeeRulesResponse{Rules: rules}, nil
+}
+
+// ArchiveFeeRuleAPI stops a rule from charging fees on bills. Fees it already charged are kept.
+// Requires the admin role.
+//
+//encore:api auth method=POST path=/fee-rules/:id/archive
+func ArchiveFeeRuleAPI(ctx context.Context, id string) error {
+	if err := requireAdmin(); err != nil {
+		return err
+	}
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+
+	if err := ArchiveFeeRule(ctx, id); err != nil {
+		if errors.Is(err, ErrFeeRuleNotFound) {
+			return errs.WrapCode(err, errs.NotFound, "fee rule not found")
+		}
+		return errs.Wrap(err, "failed to archive fee rule")
+	}
+	return nil
+}
+
+type ListFeeRulesInput struct {
+	AccountID string
+	Currency  money.Currency
+}
+
+// ListFeeRulesActivity loads the active global and account rules the workflow evaluates
+func ListFeeRulesActivity(ctx context.Context, input ListFeeRulesInput) ([]FeeRule, error) {
+	return ActiveFeeRules(ctx, input.AccountID, input.Currency)
+}
+
+type ReplaceFeeItemsInput struct {
+	BillID string
+	// SourceItemID selects the fees of one item; empty selects the per-bill fees
+	SourceItemID string
+	Fees         []AddLineItemInput
+}
+
+// ReplaceFeeItemsActivity swaps the existing fee items of a source for input.Fees and returns the
+// resulting bill total. It is idempotent, so the workflow can re-evaluate fees at any time.
+func ReplaceFeeItemsActivity(ctx context.Context, input ReplaceFeeItemsInput) (money.Money, error) {
+	fees := make([]*LineItem, len(input.Fees))
+	for i, f := range input.Fees {
+		fees[i] = f.lineItem()
+	}
+	return ReplaceFeeItemsAndUpdateTotal(ctx, input.BillID, input.SourceItemID, fees)
+}
@@ -0,0 +1,209 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fees-api/ledger"
+	"fees-api/money"
+)
+
+var ErrFeeRuleNotFound = errors.New("fee rule not found")
+
+func CreateFeeRule(ctx context.Context, r *FeeRule) error {
+	ctx, span := startDBSpan(ctx, "CreateFeeRule", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO fee_rules (id, account_id, name, scope, currency, percent_basis_points, fixed_amount, active, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9)
+	`, r.ID, r.AccountID, r.Name, r.Scope, r.Currency, r.PercentBasisPoints, r.FixedAmount, r.Active, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create fee rule %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// ListFeeRules returns all rules, or only the rules of one account when accountID is set
+func ListFeeRules(ctx context.Context, accountID string) ([]*FeeRule, error) {
+	ctx, span := startDBSpan(ctx, "ListFeeRules", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT id, COALESCE(account_id, ''), name, scope, currency,
+		       percent_basis_points, fixed_amount, active, created_at
+		FROM fee_rules
+		WHERE $1 = '' OR account_id = $1
+		ORDER BY created_at, id
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*FeeRule
+	for rows.Next() {
+		r, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// ActiveFeeRules returns the active global rules and, when accountID is set, the account's own
+// active rules in the given currency, oldest first
+func ActiveFeeRules(ctx context.Context, accountID string, currency money.Currency) ([]FeeRule, error) {
+	ctx, span := startDBSpan(ctx, "ActiveFeeRules", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT id, COALESCE(account_id, ''), name, scope, currency,
+		       percent_basis_points, fixed_amount, active, created_at
+		FROM fee_rules
+		WHERE active AND currency = $2 AND (account_id IS NULL OR account_id = NULLIF($1, ''))
+		ORDER BY created_at, id
+	`, accountID, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fee rules for account %q: %w", accountID, err)
+	}
+	defer rows.Close()
+
+	var rules []FeeRule
+	for rows.Next() {
+		r, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+func ArchiveFeeRule(ctx context.Context, ruleID string) error {
+	ctx, span := startDBSpan(ctx, "ArchiveFeeRule", "")
+	defer span.End()
+
+	result, err := db.Exec(ctx, `
+		UPDATE fee_rules SET active = FALSE WHERE id = $1
+	`, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to archive fee rule %s: %w", ruleID, err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("fee rule not found for id %s: %w", ruleID, ErrFeeRuleNotFound)
+	}
+	return nil
+}
+
+// ReplaceFeeItemsAndUpdateTotal deletes the fee items derived from sourceItemID (or the per-bill
+// fee items when it is empty), inserts fees in their place and adjusts the bill total, all in one
+// transaction. Fees are not charged on items that no longer exist or that are fees themselves.
+func ReplaceFeeItemsAndUpdateTotal(ctx context.Context, billID, sourceItemID string, fees []*LineItem) (money.Money, error) {
+	ctx, span := startDBSpan(ctx, "ReplaceFeeItemsAndUpdateTotal", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	currentTotal, err := getBillTotalTx(ctx, tx, billID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	if sourceItemID != "" {
+		source, err := getLineItemByIDTx(ctx, tx, billID, sourceItemID)
+		if err != nil {
+			return money.Money{}, err
+		}
+		if source == nil || source.FeeRuleID != "" {
+			fees = nil
+		}
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM line_items
+		WHERE bill_id = $1 AND fee_rule_id IS NOT NULL
+		  AND source_item_id IS NOT DISTINCT FROM NULLIF($2, '')
+		RETURNING id, amount
+	`, billID, sourceItemID)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to delete fee items for bill %s: %w", billID, err)
+	}
+	var (
+		removed int64
+		entries []*ledger.Entry
+		now     = time.Now()
+	)
+	for rows.Next() {
+		var (
+			id     string
+			amount int64
+		)
+		if err := rows.Scan(&id, &amount); err != nil {
+			rows.Close()
+			return money.Money{}, err
+		}
+		removed += amount
+		entries = append(entries, ledger.Charge(billID, id, money.Money{Amount: -amount, Currency: currentTotal.Currency}, now))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return money.Money{}, err
+	}
+
+	newTotal, err := currentTotal.Sub(money.Money{Amount: removed, Currency: currentTotal.Currency})
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to calculate new total for bill %s: %w", billID, err)
+	}
+	for _, fee := range fees {
+		if err := InsertLineItemTx(ctx, tx, fee); err != nil {
+			return money.Money{}, err
+		}
+		if newTotal, err = newTotal.Add(fee.Amount); err != nil {
+			return money.Money{}, fmt.Errorf("failed to calculate new total for bill %s: %w", billID, err)
+		}
+		entries = append(entries, ledger.Charge(billID, fee.ID, fee.Amount, fee.CreatedAt))
+	}
+
+	if err := postEntriesTx(ctx, tx, entries...); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := updateBillTotalTx(ctx, tx, billID, newTotal.Amount); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return money.Money{}, err
+	}
+	return newTotal, nil
+}
+
+func scanFeeRule(row interface{ Scan(...interface{}) error }) (*FeeRule, error) {
+	var (
+		r           FeeRule
+		currencyStr string
+	)
+	if err := row.Scan(
+		&r.ID,
+		&r.AccountID,
+		&r.Name,
+		&r.Scope,
+		&currencyStr,
+		&r.PercentBasisPoints,
+		&r.FixedAmount,
+		&r.Active,
+		&r.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	r.Currency = money.Currency(currencyStr)
+	return &r, nil
+}
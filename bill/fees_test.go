@@ -0,0 +1,84 @@
+package bill
+
+import (
+	"testing"
+	"time"
+
+	"fees-api/money"
+)
+
+func TestFeeRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    FeeRule
+		wantErr bool
+	}{
+		{"percentage plus fixed", FeeRule{Scope: PerItem, Currency: money.USD, PercentBasisPoints: 290, FixedAmount: 30}, false},
+		{"fixed platform fee", FeeRule{Scope: PerBill, Currency: money.GEL, FixedAmount: 2000}, false},
+		{"invalid currency", FeeRule{Scope: PerItem, Currency: "EUR", FixedAmount: 30}, true},
+		{"unknown scope", FeeRule{Scope: "month", Currency: money.USD, FixedAmount: 30}, true},
+		{"charges nothing", FeeRule{Scope: PerItem, Currency: money.USD}, true},
+		{"negative percentage", FeeRule{Scope: PerItem, Currency: money.USD, PercentBasisPoints: -1}, true},
+		{"over 100 percent", FeeRule{Scope: PerItem, Currency: money.USD, PercentBasisPoints: 10001}, true},
+		{"negative fixed amount", FeeRule{Scope: PerBill, Currency: money.USD, FixedAmount: -30}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FeeRule.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestComputeFees(t *testing.T) {
+	const billID = "1b4e28ba-2fa1-41d2-883f-0016d3cca427"
+	const itemID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	at := time.Date(2026, 5, 31, 12, 0, 0, 0, time.UTC)
+
+	rules := []FeeRule{
+		{ID: "card", Name: "Card processing fee", Scope: PerItem, Currency: money.USD, PercentBasisPoints: 290, FixedAmount: 30},
+		{ID: "platform", Name: "Monthly platform fee", Scope: PerBill, Currency: money.USD, FixedAmount: 2000},
+		{ID: "tiny", Name: "Tiny fee", Scope: PerItem, Currency: money.USD, PercentBasisPoints: 1},
+	}
+
+	fees, err := computeFees(rules, PerItem, billID, itemID, money.Money{Amount: 1000, Currency: money.USD}, at)
+	if err != nil {
+		t.Fatalf("computeFees() error = %v", err)
+	}
+	// The tiny fee rounds to zero on $10.00 and is skipped
+	if len(fees) != 1 {
+		t.Fatalf("computeFees() returned %d fees, want 1: %+v", len(fees), fees)
+	}
+
+	fee := fees[0]
+	if want := (money.Money{Amount: 59, Currency: money.USD}); fee.Amount != want {
+		t.Errorf("fee amount = %v, want %v", fee.Amount, want)
+	}
+	if fee.SourceItemID != itemID || fee.FeeRuleID != "card" || fee.BillID != billID {
+		t.Errorf("fee links = source %q rule %q bill %q", fee.SourceItemID, fee.FeeRuleID, fee.BillID)
+	}
+	if fee.Description != "Card processing fee" || !fee.CreatedAt.Equal(at) {
+		t.Errorf("fee description %q created %v", fee.Description, fee.CreatedAt)
+	}
+	if err := validateItemID(fee.ItemID); err != nil {
+		t.Errorf("fee item ID %q is not a valid item ID: %v", fee.ItemID, err)
+	}
+	if fee.ItemID != feeItemID(billID, itemID, "card") {
+		t.Error("fee item ID is not stable for the same bill, item and rule")
+	}
+
+	billFees, err := computeFees(rules, PerBill, billID, "", money.Money{Amount: 0, Currency: money.USD}, at)
+	if err != nil {
+		t.Fatalf("computeFees() error = %v", err)
+	}
+	if len(billFees) != 1 || billFees[0].Amount.Amount != 2000 || billFees[0].SourceItemID != "" {
+		t.Errorf("computeFees() per-bill fees = %+v, want one 2000 fee without a source", billFees)
+	}
+
+	if _, err := computeFees(rules, PerItem, billID, itemID, money.Money{Amount: 1000, Currency: money.GEL}, at); err == nil {
+		t.Error("computeFees() expected an error for a currency mismatch")
+	}
+}
@@ -0,0 +1,258 @@
+package bill
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+)
+
+// healthCheckTimeout bounds each dependency check, so a probe answers even when one hangs
+const healthCheckTimeout = 2 * time.Second
+
+//go:embed db/migrations/*.up.sql
+var migrationFiles embed.FS
+
+type HealthStatus string
+
+const (
+	HealthOK HealthStatus = "ok"
+	// HealthDegraded: a dependency the service can run without is down
+	HealthDegraded HealthStatus = "degraded"
+	// HealthUnavailable: a dependency the service cannot serve without is down
+	HealthUnavailable HealthStatus = "unavailable"
+)
+
+// DependencyStatus is the result of checking one dependency
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Up        bool    `json:"up"`
+	Required  bool    `json:"required"` // the service is not ready while a required dependency is down
+	LatencyMs float64 `json:"latency_ms"`
+	Detail    string  `json:"detail,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport is the readiness of the service and of each dependency it checked
+type HealthReport struct {
+	Status       HealthStatus       `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// healthCheck checks one dependency and returns a detail for the report
+type healthCheck struct {
+	Name     string
+	Required bool
+	Check    func(ctx context.Context) (string, error)
+}
+
+// Liveness answers as long as the process is serving requests. It checks no dependencies, as
+// restarting the service would not bring them back.
+//
+//encore:api public raw method=GET path=/healthz
+func Liveness(w http.ResponseWriter, req *http.Request) {
+	writeHealth(w, http.StatusOK, &HealthReport{Status: HealthOK, Dependencies: []DependencyStatus{}})
+}
+
+// Readiness checks every dependency and answers 503 while a required one is down, so that
+// orchestrators route no traffic to the instance
+//
+//encore:api public raw method=GET path=/readyz
+func Readiness(w http.ResponseWriter, req *http.Request) {
+	report := runHealthChecks(req.Context(), healthChecks())
+	code := http.StatusOK
+	if report.Status == HealthUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, code, report)
+}
+
+// HealthCheck returns the same report as the readiness probe
+//
+//encore:api private
+func (s *Service) HealthCheck(ctx context.Context) (*HealthReport, error) {
+	return runHealthChecks(ctx, healthChecks()), nil
+}
+
+func writeHealth(w http.ResponseWriter, code int, report *HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// healthChecks lists the dependencies of this instance. With the outbox enabled, bill changes are
+// accepted while Temporal is down, so Temporal and the worker only degrade the service.
+func healthChecks() []healthCheck {
+	temporalRequired := !cfg.Outbox.Enabled
+	checks := []healthCheck{
+		{Name: "database", Required: true, Check: checkDatabase},
+		{Name: "migrations", Required: true, Check: checkMigrations},
+		{Name: "temporal", Required: temporalRequired, Check: checkTemporal},
+		{Name: "worker", Required: temporalRequired, Check: checkWorker},
+	}
+	if cfg.Outbox.Enabled {
+		checks = append(checks, healthCheck{Name: "outbox", Check: checkOutbox})
+	}
+	return checks
+}
+
+// runHealthChecks runs the checks concurrently, each under healthCheckTimeout, and reports them
+// in the order given
+func runHealthChecks(ctx context.Context, checks []healthCheck) *HealthReport {
+	report := &HealthReport{Status: HealthOK, Dependencies: make([]DependencyStatus, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			detail, err := runHealthCheck(ctx, c.Check)
+			status := DependencyStatus{
+				Name:      c.Name,
+				Up:        err == nil,
+				Required:  c.Required,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Detail:    detail,
+			}
+			if err != nil {
+				status.Error = err.Error()
+			}
+			report.Dependencies[i] = status
+		}()
+	}
+	wg.Wait()
+
+	for _, d := range report.Dependencies {
+		switch {
+		case d.Up:
+		case d.Required:
+			report.Status = HealthUnavailable
+		case report.Status == HealthOK:
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+// runHealthCheck returns once the check finishes or ctx is done, whichever comes first, for
+// checks that do not honour ctx
+func runHealthCheck(ctx context.Context, check func(context.Context) (string, error)) (string, error) {
+	type result struct {
+		detail string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		detail, err := check(ctx)
+		done <- result{detail, err}
+	}()
+	select {
+	case r := <-done:
+		return r.detail, r.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
+
+func checkDatabase(ctx context.Context) (string, error) {
+	var one int
+	if err := db.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+		return "", fmt.Errorf("failed to query database: %w", err)
+	}
+	return "", nil
+}
+
+// checkMigrations fails until the database has every migration this build ships with
+func checkMigrations(ctx context.Context) (string, error) {
+	want, err := latestMigration(migrationFiles)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		version int
+		dirty   bool
+	)
+	if err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations").Scan(&version, &dirty); err != nil {
+		return "", fmt.Errorf("failed to read migration version: %w", err)
+	}
+	detail := fmt.Sprintf("version %d of %d", version, want)
+	switch {
+	case dirty:
+		return detail, fmt.Errorf("migration %d failed part way", version)
+	case version < want:
+		return detail, fmt.Errorf("database is at migration %d, this build needs %d", version, want)
+	}
+	return detail, nil
+}
+
+// latestMigration returns the highest migration number in the migrations directory
+func latestMigration(files fs.FS) (int, error) {
+	names, err := fs.Glob(files, "db/migrations/*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+	latest := 0
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(strings.TrimPrefix(name, "db/migrations/"), "_")
+		n, err := strconv.Atoi(prefix)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name %s", name)
+		}
+		latest = max(latest, n)
+	}
+	if latest == 0 {
+		return 0, errors.New("no migrations found")
+	}
+	return latest, nil
+}
+
+func checkTemporal(ctx context.Context) (string, error) {
+	status := temporalManager.Check(ctx)
+	if !status.Connected {
+		return status.Server, fmt.Errorf("unreachable since %s (%d failed attempts): %s",
+			status.Since.Format(time.RFC3339), status.Attempts, status.LastError)
+	}
+	return status.Server, nil
+}
+
+// checkWorker fails while no worker, on this instance or another, polls the task queue
+func checkWorker(ctx context.Context) (string, error) {
+	c := GetTemporalClient()
+	if c == nil {
+		return "", errors.New("Temporal workflow service is down")
+	}
+
+	resp, err := c.DescribeTaskQueue(ctx, cfg.Temporal.TaskQueue, enumspb.TASK_QUEUE_TYPE_WORKFLOW)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe task queue %s: %w", cfg.Temporal.TaskQueue, err)
+	}
+	pollers := len(resp.GetPollers())
+	detail := fmt.Sprintf("%d pollers on %s", pollers, cfg.Temporal.TaskQueue)
+	if pollers == 0 {
+		return detail, fmt.Errorf("no worker is polling %s", cfg.Temporal.TaskQueue)
+	}
+	return detail, nil
+}
+
+func checkOutbox(ctx context.Context) (string, error) {
+	queued, err := CountPendingSignals(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d signals queued", queued), nil
+}
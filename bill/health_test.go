@@ -0,0 +1,93 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRunHealthChecks(t *testing.T) {
+	up := func(context.Context) (string, error) { return "fine", nil }
+	down := func(context.Context) (string, error) { return "", errors.New("connection refused") }
+
+	tests := []struct {
+		name   string
+		checks []healthCheck
+		want   HealthStatus
+	}{
+		{"all up", []healthCheck{{"a", true, up}, {"b", false, up}}, HealthOK},
+		{"optional down", []healthCheck{{"a", true, up}, {"b", false, down}}, HealthDegraded},
+		{"required down", []healthCheck{{"a", true, down}, {"b", false, down}}, HealthUnavailable},
+		{"nothing to check", nil, HealthOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := runHealthChecks(context.Background(), tt.checks)
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s", report.Status, tt.want)
+			}
+			if len(report.Dependencies) != len(tt.checks) {
+				t.Fatalf("got %d dependencies, want %d", len(report.Dependencies), len(tt.checks))
+			}
+			for i, d := range report.Dependencies {
+				if d.Name != tt.checks[i].Name || d.Required != tt.checks[i].Required {
+					t.Errorf("dependency %d = %+v, want %s in check order", i, d, tt.checks[i].Name)
+				}
+				if d.Up != (d.Error == "") {
+					t.Errorf("dependency %s: up = %v with error %q", d.Name, d.Up, d.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestRunHealthChecksTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	hangs := func(context.Context) (string, error) {
+		<-release // ignores ctx
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	report := runHealthChecks(ctx, []healthCheck{{Name: "stuck", Required: true, Check: hangs}})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("report took %v, want it bounded by the context", elapsed)
+	}
+	if report.Status != HealthUnavailable || report.Dependencies[0].Up {
+		t.Errorf("report = %+v, want the stuck dependency down", report)
+	}
+	if report.Dependencies[0].LatencyMs < 40 {
+		t.Errorf("latency = %vms, want about the timeout", report.Dependencies[0].LatencyMs)
+	}
+}
+
+func TestLatestMigration(t *testing.T) {
+	files := fstest.MapFS{
+		"db/migrations/001_create_bills.up.sql":          {},
+		"db/migrations/010_create_reconciliation.up.sql": {},
+		"db/migrations/002_create_line_items.up.sql":     {},
+	}
+	got, err := latestMigration(files)
+	if err != nil || got != 10 {
+		t.Errorf("latestMigration() = %d, %v, want 10", got, err)
+	}
+
+	if _, err := latestMigration(fstest.MapFS{}); err == nil {
+		t.Error("latestMigration() of no files succeeded")
+	}
+	if _, err := latestMigration(fstest.MapFS{"db/migrations/next_thing.up.sql": {}}); err == nil {
+		t.Error("latestMigration() accepted a file without a number")
+	}
+
+	// The embedded migrations are the ones in the tree
+	if got, err := latestMigration(migrationFiles); err != nil || got < 17 {
+		t.Errorf("latestMigration(embedded) = %d, %v, want at least 17", got, err)
+	}
+}
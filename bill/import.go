@@ -0,0 +1,257 @@
+package bill
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"encore.dev"
+	"encore.dev/beta/errs"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxImportBytes bounds the size of an uploaded CSV
+	maxImportBytes = 1 << 20
+	// maxImportRows bounds the number of items in one import; it matches maxBatchItems
+	maxImportRows = maxBatchItems
+)
+
+// importItemNamespace derives stable item IDs from the file content, so re-uploading the same file is a no-op
+var importItemNamespace = uuid.MustParse("c3a4e0d2-51f7-4b8e-9d6a-8f2e7b1c5a94")
+
+type ImportMode string
+
+const (
+	// AllOrNothing imports nothing if any row is invalid
+	AllOrNothing ImportMode = "all_or_nothing"
+	// Partial imports the valid rows and reports the rest
+	Partial ImportMode = "partial"
+)
+
+// importColumns are the recognized CSV columns; amount and quantity are integers
+var importColumns = map[string]bool{"amount": true, "description": true, "price_id": true, "quantity": true}
+
+// ImportRowError reports why one CSV row was rejected; Line is the row's line number in the file
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type ImportItemsResponse struct {
+	Mode     ImportMode       `json:"mode"`
+	Rows     int              `json:"rows"`
+	Accepted int              `json:"accepted"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// ErrDetails lets a rejected import report its row errors in the error response
+func (r *ImportItemsResponse) ErrDetails() {}
+
+// importRow is a CSV row with the line it started on
+type importRow struct {
+	line   int
+	fields map[string]string
+}
+
+// ImportItemsAPI adds line items from a CSV with a header row naming any of the columns amount,
+// description, price_id and quantity. Every row is validated like POST /bills/:id/items.
+// Query parameters: ?mode=all_or_nothing (default) or ?mode=partial
+//
+//encore:api public raw method=POST path=/bills/:id/items/import
+func ImportItemsAPI(w http.ResponseWriter, req *http.Request) {
+	id := encore.CurrentRequest().PathParams.Get("id")
+
+	// Validate bill ID format
+	if err := validateUUID(id); err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	mode := ImportMode(req.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = AllOrNothing
+	}
+	if mode != AllOrNothing && mode != Partial {
+		errs.HTTPError(w, errs.WrapCode(errors.New("mode must be all_or_nothing or partial"), errs.InvalidArgument, "mode must be all_or_nothing or partial"))
+		return
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxImportBytes))
+	if err != nil {
+		errs.HTTPError(w, errs.WrapCode(err, errs.InvalidArgument, "CSV must be at most 1 MiB"))
+		return
+	}
+
+	resp, err := ImportItems(req.Context(), id, content, mode)
+	if err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ImportItems validates every row of a CSV and delivers the valid rows to the bill workflow as a
+// single batch. In all-or-nothing mode any invalid row rejects the whole file, with the row
+// errors as the error details.
+func ImportItems(ctx context.Context, billID string, content []byte, mode ImportMode) (*ImportItemsResponse, error) {
+	rows, err := parseImportCSV(content)
+	if err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	bill, err := getOpenBill(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := importValidator{
+		bill:     bill,
+		fileHash: sha256.Sum256(content),
+		prices:   newPriceChecker(ctx, bill.Total.Currency),
+	}
+	resp := &ImportItemsResponse{Mode: mode, Rows: len(rows)}
+	var items []AddItemSignal
+	for _, row := range rows {
+		item, err := v.item(row)
+		if err != nil {
+			resp.Errors = append(resp.Errors, ImportRowError{Line: row.line, Error: err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if len(resp.Errors) > 0 && mode == AllOrNothing {
+		return nil, errs.B().Code(errs.InvalidArgument).
+			Msgf("%d of %d rows are invalid; nothing was imported", len(resp.Errors), len(rows)).
+			Details(resp).Err()
+	}
+
+	if len(items) > 0 {
+		if err := AddLineItems(ctx, bill.ID, items); err != nil {
+			return nil, err
+		}
+	}
+	resp.Accepted = len(items)
+	return resp, nil
+}
+
+// parseImportCSV reads the header and data rows, rejecting unknown or repeated columns
+func parseImportCSV(content []byte) ([]importRow, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !importColumns[name] {
+			return nil, fmt.Errorf("unknown column %q; columns are amount, description, price_id and quantity", name)
+		}
+		for _, earlier := range header[:i] {
+			if earlier == name {
+				return nil, fmt.Errorf("column %q appears twice", name)
+			}
+		}
+		header[i] = name
+	}
+
+	var rows []importRow
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("CSV must contain at most %d rows", maxImportRows)
+		}
+		row := importRow{line: line, fields: make(map[string]string, len(header))}
+		for i, name := range header {
+			row.fields[name] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("CSV has no rows")
+	}
+	return rows, nil
+}
+
+// importValidator turns rows into add item signals for one bill
+type importValidator struct {
+	bill     *Bill
+	fileHash [sha256.Size]byte
+	prices   *priceChecker
+}
+
+// item validates a row with the rules of AddItem and the workflow's signal validation
+func (v *importValidator) item(row importRow) (AddItemSignal, error) {
+	req := AddItemRequest{
+		Description: row.fields["description"],
+		PriceID:     row.fields["price_id"],
+	}
+	var err error
+	if req.Amount, err = parseImportInt(row.fields["amount"]); err != nil {
+		return AddItemSignal{}, errors.New("amount must be an integer number of minor units")
+	}
+	if req.Quantity, err = parseImportInt(row.fields["quantity"]); err != nil {
+		return AddItemSignal{}, errors.New("quantity must be an integer")
+	}
+
+	if err := validateAddItemRequest(&req); err != nil {
+		return AddItemSignal{}, err
+	}
+	if req.PriceID != "" {
+		if err := v.prices.check(req.PriceID); err != nil {
+			return AddItemSignal{}, err
+		}
+	}
+
+	signal := AddItemSignal{
+		ItemID:      v.itemID(row.line),
+		Amount:      req.Amount,
+		Description: req.Description,
+		PriceID:     req.PriceID,
+		Quantity:    req.Quantity,
+	}
+	if err := validateAddItemSignal(signal); err != nil {
+		return AddItemSignal{}, err
+	}
+	return signal, nil
+}
+
+// itemID is stable for a bill, file content and line
+func (v *importValidator) itemID(line int) string {
+	name := fmt.Sprintf("%s/%s/%d", v.bill.ID, hex.EncodeToString(v.fileHash[:]), line)
+	return uuid.NewSHA1(importItemNamespace, []byte(name)).String()
+}
+
+// parseImportInt parses an optional integer cell; an empty cell is zero
+func parseImportInt(cell string) (int64, error) {
+	if cell == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(cell, 10, 64)
+}
@@ -0,0 +1,122 @@
+package bill
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"fees-api/money"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantRows  int
+		wantLines []int
+		wantErr   bool
+	}{
+		{"amount rows", "amount,description\n1500,Setup fee\n250,Support\n", 2, []int{2, 3}, false},
+		{"header case and order", "Description, AMOUNT\nSetup fee,1500\n", 1, []int{2}, false},
+		{"byte order mark", "\ufeffamount,description\n1500,Setup fee\n", 1, []int{2}, false},
+		{"quoted multiline description", "amount,description\n1500,\"Setup\nfee\"\n250,Support\n", 2, []int{2, 4}, false},
+		{"catalog rows", "price_id,quantity\n6ba7b810-9dad-11d1-80b4-00c04fd430c8,3\n", 1, []int{2}, false},
+		{"empty", "", 0, nil, true},
+		{"header only", "amount,description\n", 0, nil, true},
+		{"unknown column", "amount,memo\n1500,Setup fee\n", 0, nil, true},
+		{"repeated column", "amount,Amount\n1,2\n", 0, nil, true},
+		{"ragged row", "amount,description\n1500\n", 0, nil, true},
+		{"too many rows", "amount,description\n" + strings.Repeat("1,x\n", maxImportRows+1), 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseImportCSV([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(rows) != tt.wantRows {
+				t.Fatalf("parseImportCSV() returned %d rows, want %d", len(rows), tt.wantRows)
+			}
+			for i, row := range rows {
+				if row.line != tt.wantLines[i] {
+					t.Errorf("row %d line = %d, want %d", i, row.line, tt.wantLines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImportValidatorItem(t *testing.T) {
+	v := importValidator{
+		bill:     &Bill{ID: "1b4e28ba-2fa1-41d2-883f-0016d3cca427", Total: money.Money{Currency: money.USD}},
+		fileHash: sha256.Sum256([]byte("amount,description\n")),
+		prices:   newPriceChecker(context.Background(), money.USD),
+	}
+
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		wantErr string
+	}{
+		{"valid amount row", map[string]string{"amount": "1500", "description": " Setup fee "}, ""},
+		{"non-integer amount", map[string]string{"amount": "15.00", "description": "Setup fee"}, "amount must be an integer"},
+		{"zero amount", map[string]string{"amount": "0", "description": "Setup fee"}, "amount must be positive"},
+		{"amount too large", map[string]string{"amount": "100000001", "description": "Setup fee"}, "amount must be positive"},
+		{"missing description", map[string]string{"amount": "1500"}, "description required"},
+		{"quantity without price", map[string]string{"amount": "1500", "description": "Setup fee", "quantity": "2"}, "quantity requires price_id"},
+		{"amount with price", map[string]string{"amount": "1500", "price_id": "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "quantity": "1"}, "amount cannot be combined"},
+		{"invalid price id", map[string]string{"price_id": "seats", "quantity": "1"}, "valid UUID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := v.item(importRow{line: 2, fields: tt.fields})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("item() error = %v", err)
+				}
+				if item.Description != "Setup fee" || item.Amount != 1500 {
+					t.Errorf("item() = %+v", item)
+				}
+				if item.ItemID != v.itemID(2) || item.ItemID == v.itemID(3) {
+					t.Error("item IDs must be stable per line and differ between lines")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("item() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAddItemsSignal(t *testing.T) {
+	item := func(id string) AddItemSignal {
+		return AddItemSignal{ItemID: id, Amount: 100, Description: "Setup fee"}
+	}
+	first := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	second := "1b4e28ba-2fa1-41d2-883f-0016d3cca427"
+
+	tests := []struct {
+		name    string
+		signal  AddItemsSignal
+		wantErr bool
+	}{
+		{"valid batch", AddItemsSignal{Items: []AddItemSignal{item(first), item(second)}}, false},
+		{"empty batch", AddItemsSignal{}, true},
+		{"invalid item", AddItemsSignal{Items: []AddItemSignal{item(first), item("not-a-uuid")}}, true},
+		{"duplicate item ID", AddItemsSignal{Items: []AddItemSignal{item(first), item(first)}}, true},
+		{"too many items", AddItemsSignal{Items: make([]AddItemSignal, maxBatchItems+1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAddItemsSignal(tt.signal)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAddItemsSignal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
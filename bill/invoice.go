@@ -0,0 +1,203 @@
+package bill
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"fees-api/invoice"
+	"fees-api/money"
+
+	"encore.dev"
+	"encore.dev/beta/errs"
+	"encore.dev/storage/objects"
+)
+
+// invoices stores every rendered invoice so that re-downloading returns byte-identical output
+var invoices = objects.NewBucket("invoices", objects.BucketConfig{})
+
+type invoiceFormat struct {
+	Extension   string
+	ContentType string
+	Render      func(invoice.Document) ([]byte, error)
+}
+
+var invoiceFormats = map[string]invoiceFormat{
+	"pdf":  {Extension: "pdf", ContentType: "application/pdf", Render: invoice.RenderPDF},
+	"html": {Extension: "html", ContentType: "text/html; charset=utf-8", Render: invoice.RenderHTML},
+}
+
+var (
+	invoiceLogo     []byte
+	invoiceLogoOnce sync.Once
+)
+
+// GetInvoiceAPI downloads the invoice for a closed bill as PDF (default) or HTML.
+// Query parameters: ?format=pdf or ?format=html
+//
+//encore:api public raw method=GET path=/bills/:id/invoice
+func GetInvoiceAPI(w http.ResponseWriter, req *http.Request) {
+	id := encore.CurrentRequest().PathParams.Get("id")
+
+	// Validate bill ID format
+	if err := validateUUID(id); err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	name := req.URL.Query().Get("format")
+	if name == "" {
+		name = "pdf"
+	}
+	format, ok := invoiceFormats[name]
+	if !ok {
+		errs.HTTPError(w, errs.WrapCode(errors.New("format must be pdf or html"), errs.InvalidArgument, "format must be pdf or html"))
+		return
+	}
+
+	content, err := GetInvoice(req.Context(), id, format)
+	if err != nil {
+		errs.HTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%s.%s"`, id, format.Extension))
+	_, _ = w.Write(content)
+}
+
+// GetInvoice returns the stored invoice for a closed bill, rendering and storing it on first request.
+// Invoices are keyed by the bill's close time, so a reopened and re-closed bill gets a fresh invoice.
+func GetInvoice(ctx context.Context, billID string, format invoiceFormat) ([]byte, error) {
+	bill, err := GetByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.Status != Closed || bill.ClosedAt == nil {
+		return nil, errs.WrapCode(errors.New("bill is not closed"), errs.FailedPrecondition, "invoices are only available for closed bills")
+	}
+
+	key := fmt.Sprintf("%s/%d.%s", bill.ID, bill.ClosedAt.Unix(), format.Extension)
+
+	content, err := readInvoice(ctx, key)
+	if err == nil {
+		return content, nil
+	}
+	if !errors.Is(err, objects.ErrObjectNotFound) {
+		return nil, errs.Wrap(err, "failed to read stored invoice")
+	}
+
+	items, err := GetLineItems(ctx, bill.ID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to load line items")
+	}
+
+	statement, err := GetBillStatement(ctx, bill.ID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to load bill statement")
+	}
+
+	doc, err := buildInvoiceDocument(bill, items, statement.Tax, cfg.Ledger.TaxBasisPoints)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to calculate invoice total")
+	}
+	content, err = format.Render(doc)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to render invoice")
+	}
+
+	err = storeInvoice(ctx, key, format.ContentType, content)
+	if errors.Is(err, objects.ErrPreconditionFailed) {
+		// A concurrent request stored it first - serve that copy so every download is identical
+		return readInvoice(ctx, key)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to store invoice")
+	}
+
+	return content, nil
+}
+
+// buildInvoiceDocument maps a bill and its line items onto the invoice layout. The tax is what
+// closing the bill posted to the ledger; the rate is printed only when taxBasisPoints, the rate
+// configured now, accounts for it.
+func buildInvoiceDocument(b *Bill, items []*LineItem, tax money.Money, taxBasisPoints int64) (invoice.Document, error) {
+	doc := invoice.Document{
+		Issuer: invoice.Issuer{
+			Name:    cfg.Invoice.IssuerName,
+			Address: cfg.Invoice.IssuerAddress,
+			Logo:    loadInvoiceLogo(),
+		},
+		Number:   b.InvoiceNumber,
+		BillID:   b.ID,
+		IssuedAt: *b.ClosedAt,
+		Subtotal: b.Total,
+		Total:    b.Total,
+	}
+	for _, item := range items {
+		doc.Lines = append(doc.Lines, invoice.Line{
+			Description: item.Description,
+			Amount:      item.Amount,
+		})
+	}
+	if tax.Amount == 0 {
+		return doc, nil
+	}
+
+	var err error
+	if doc.Total, err = b.Total.Add(tax); err != nil {
+		return invoice.Document{}, err
+	}
+	doc.Tax = &invoice.Tax{Amount: tax}
+	if atRate, err := taxOn(b.Total, taxBasisPoints); err == nil && atRate == tax {
+		doc.Tax.BasisPoints = taxBasisPoints
+	}
+	return doc, nil
+}
+
+// loadInvoiceLogo reads the configured logo once; invoices render without a logo if it is missing
+func loadInvoiceLogo() []byte {
+	invoiceLogoOnce.Do(func() {
+		if cfg.Invoice.LogoPath == "" {
+			return
+		}
+		logo, err := os.ReadFile(cfg.Invoice.LogoPath)
+		if err != nil {
+			logger.Warn("Invoice logo unavailable", "path", cfg.Invoice.LogoPath, "error", err)
+			return
+		}
+		invoiceLogo = logo
+	})
+	return invoiceLogo
+}
+
+func readInvoice(ctx context.Context, key string) ([]byte, error) {
+	r := invoices.Download(ctx, key)
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func storeInvoice(ctx context.Context, key, contentType string, content []byte) error {
+	w := invoices.Upload(ctx, key,
+		objects.WithPreconditions(objects.Preconditions{NotExists: true}),
+		objects.WithUploadAttrs(objects.UploadAttrs{ContentType: contentType}),
+	)
+	if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
+		w.Abort(err)
+		return err
+	}
+	return w.Close()
+}
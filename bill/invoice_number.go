@@ -0,0 +1,36 @@
+package bill
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// seqToken matches {SEQ} or {SEQ:n}, where n is the zero-padded width of the sequence number
+var seqToken = regexp.MustCompile(`\{SEQ(?::(\d+))?\}`)
+
+// validateInvoiceNumberFormat checks that a format contains exactly one sequence token
+func validateInvoiceNumberFormat(format string) error {
+	if n := len(seqToken.FindAllString(format, -1)); n != 1 {
+		return errors.New("invoice number format must contain exactly one {SEQ} or {SEQ:n} token")
+	}
+	return nil
+}
+
+// formatInvoiceNumber renders an invoice number from a format such as "INV-{YYYY}-{SEQ:6}".
+// Supported tokens are {YYYY} (four digit year), {YY} (two digit year) and {SEQ}/{SEQ:n}.
+func formatInvoiceNumber(format string, year int, seq int64) string {
+	number := seqToken.ReplaceAllStringFunc(format, func(token string) string {
+		width := 0
+		if m := seqToken.FindStringSubmatch(token); m[1] != "" {
+			width, _ = strconv.Atoi(m[1])
+		}
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+	return strings.NewReplacer(
+		"{YYYY}", fmt.Sprintf("%04d", year),
+		"{YY}", fmt.Sprintf("%02d", year%100),
+	).Replace(number)
+}
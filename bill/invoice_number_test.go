@@ -0,0 +1,50 @@
+package bill
+
+import "testing"
+
+func TestFormatInvoiceNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		year   int
+		seq    int64
+		want   string
+	}{
+		{"default format", "INV-{YYYY}-{SEQ:6}", 2026, 123, "INV-2026-000123"},
+		{"two digit year", "{YY}/{SEQ:4}", 2026, 7, "26/0007"},
+		{"unpadded sequence", "F{YYYY}{SEQ}", 2026, 42, "F202642"},
+		{"sequence wider than padding", "INV-{SEQ:2}", 2026, 12345, "INV-12345"},
+		{"literal text kept", "ACME-INV-{YYYY}-{SEQ:3}", 2030, 1, "ACME-INV-2030-001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatInvoiceNumber(tt.format, tt.year, tt.seq); got != tt.want {
+				t.Errorf("formatInvoiceNumber() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateInvoiceNumberFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+	}{
+		{"padded sequence", "INV-{YYYY}-{SEQ:6}", false},
+		{"plain sequence", "{SEQ}", false},
+		{"no sequence", "INV-{YYYY}", true},
+		{"two sequences", "{SEQ}-{SEQ:2}", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInvoiceNumberFormat(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateInvoiceNumberFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package bill
+
+import (
+	"testing"
+	"time"
+
+	"fees-api/money"
+)
+
+func TestBuildInvoiceDocumentTax(t *testing.T) {
+	closedAt := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+	b := &Bill{ID: testBillID, Status: Closed, Total: money.Money{Amount: 12345, Currency: money.USD}, ClosedAt: &closedAt}
+	items := []*LineItem{{ID: testItemID, Description: "Wire transfer fee", Amount: b.Total}}
+
+	tests := []struct {
+		name        string
+		tax         int64 // posted when the bill closed
+		basisPoints int64 // configured now
+		wantTax     int64 // -1 for no tax line
+		wantRate    int64
+		wantTotal   int64
+	}{
+		{"no tax", 0, 0, -1, 0, 12345},
+		{"no tax posted at the current rate", 0, 1000, -1, 0, 12345},
+		{"rounded down", 1018, 825, 1018, 825, 13363},      // 1018.4625
+		{"rounded half up", 1235, 1000, 1235, 1000, 13580}, // 1234.5
+		{"rate changed since closing", 1235, 1800, 1235, 0, 13580},
+		{"tax posted, none configured now", 1235, 0, 1235, 0, 13580},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tax := money.Money{Amount: tt.tax, Currency: money.USD}
+			doc, err := buildInvoiceDocument(b, items, tax, tt.basisPoints)
+			if err != nil {
+				t.Fatalf("buildInvoiceDocument() error = %v", err)
+			}
+			if doc.Subtotal != b.Total {
+				t.Errorf("subtotal = %v, want %v", doc.Subtotal, b.Total)
+			}
+			if doc.Total != (money.Money{Amount: tt.wantTotal, Currency: money.USD}) {
+				t.Errorf("total = %v, want %d", doc.Total, tt.wantTotal)
+			}
+			if tt.wantTax < 0 {
+				if doc.Tax != nil {
+					t.Errorf("tax = %+v, want none", doc.Tax)
+				}
+				return
+			}
+			if doc.Tax == nil || doc.Tax.Amount.Amount != tt.wantTax || doc.Tax.BasisPoints != tt.wantRate {
+				t.Fatalf("tax = %+v, want %d at %d basis points", doc.Tax, tt.wantTax, tt.wantRate)
+			}
+		})
+	}
+}
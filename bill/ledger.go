@@ -0,0 +1,113 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fees-api/ledger"
+	"fees-api/money"
+
+	"encore.dev/beta/errs"
+)
+
+// GetBalanceAPI returns a bill's balance as derived from its ledger entries.
+//
+//encore:api public method=GET path=/bills/:id/balance
+func GetBalanceAPI(ctx context.Context, id string) (*ledger.Statement, error) {
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+
+	statement, err := GetBillStatement(ctx, id)
+	if errors.Is(err, ErrBillNotFound) {
+		return nil, errs.WrapCode(err, errs.NotFound, "bill not found")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to get bill balance")
+	}
+	return statement, nil
+}
+
+type LedgerResponse struct {
+	Entries []*ledger.Entry `json:"entries"`
+}
+
+// GetLedgerAPI returns the journal entries posted on a bill. Requires the admin role.
+//
+//encore:api auth method=GET path=/bills/:id/ledger
+func GetLedgerAPI(ctx context.Context, id string) (*LedgerResponse, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+
+	if _, err := GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	entries, err := ListLedgerEntries(ctx, id)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list ledger entries")
+	}
+	return &LedgerResponse{Entries: entries}, nil
+}
+
+// PaymentRequest records money received or returned. Reference identifies the payment with the
+// payment provider; repeating a request with the same reference records it once.
+type PaymentRequest struct {
+	Amount    int64  `json:"amount"`
+	Reference string `json:"reference"`
+}
+
+// RecordPaymentAPI records a payment on a closed bill, up to the amount due. Requires the admin role.
+//
+//encore:api auth method=POST path=/bills/:id/payments
+func RecordPaymentAPI(ctx context.Context, id string, req PaymentRequest) (*ledger.Statement, error) {
+	return recordPayment(ctx, id, ledger.Payment, req)
+}
+
+// RecordRefundAPI returns part or all of what was paid on a bill. Requires the admin role.
+//
+//encore:api auth method=POST path=/bills/:id/refunds
+func RecordRefundAPI(ctx context.Context, id string, req PaymentRequest) (*ledger.Statement, error) {
+	return recordPayment(ctx, id, ledger.Refund, req)
+}
+
+func recordPayment(ctx context.Context, id string, kind ledger.Kind, req PaymentRequest) (*ledger.Statement, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+
+	req.Reference = strings.TrimSpace(req.Reference)
+	if len(req.Reference) == 0 || len(req.Reference) > 200 {
+		return nil, errs.WrapCode(errors.New("reference required and max 200 chars"), errs.InvalidArgument, "reference required and max 200 chars")
+	}
+	if req.Amount <= 0 || req.Amount > MaxAmountCents {
+		return nil, errs.WrapCode(errors.New("amount must be positive and at most 1,000,000.00"), errs.InvalidArgument, "invalid amount")
+	}
+
+	b, err := GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := money.Money{Amount: req.Amount, Currency: b.Total.Currency}
+	statement, err := RecordPayment(ctx, id, kind, req.Reference, amount, time.Now())
+	switch {
+	case errors.Is(err, ErrBillNotFound):
+		return nil, errs.WrapCode(err, errs.NotFound, "bill not found")
+	case errors.Is(err, ErrPaymentRejected):
+		return nil, errs.WrapCode(err, errs.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrDuplicateReference):
+		return nil, errs.WrapCode(err, errs.AlreadyExists, err.Error())
+	case err != nil:
+		return nil, errs.Wrap(err, "failed to record "+string(kind))
+	}
+	return statement, nil
+}
@@ -0,0 +1,295 @@
+package bill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fees-api/ledger"
+	"fees-api/money"
+
+	"encore.dev/storage/sqldb"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrPaymentRejected is returned for a payment or refund the bill's balance does not allow
+	ErrPaymentRejected = errors.New("payment rejected")
+	// ErrDuplicateReference is returned when a payment or refund reference was already used
+	// for a different amount
+	ErrDuplicateReference = errors.New("duplicate payment reference")
+)
+
+// querier is implemented by both the database and a transaction
+type querier interface {
+	Query(ctx context.Context, query string, args ...interface{}) (*sqldb.Rows, error)
+}
+
+// postEntriesTx validates and inserts ledger entries within a transaction, skipping nil entries.
+// The database checks again at commit that every entry balances.
+func postEntriesTx(ctx context.Context, tx *sqldb.Tx, entries ...*ledger.Entry) error {
+	var (
+		ids, billIDs, kinds, currencies, references, memos []string
+		postedAts                                          []time.Time
+		entryIDs, accounts                                 []string
+		amounts                                            []int64
+	)
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("invalid %s entry for bill %s: %w", e.Kind, e.BillID, err)
+		}
+		if e.ID == "" {
+			e.ID = uuid.NewString()
+		}
+		ids = append(ids, e.ID)
+		billIDs = append(billIDs, e.BillID)
+		kinds = append(kinds, string(e.Kind))
+		currencies = append(currencies, string(e.Currency))
+		references = append(references, e.Reference)
+		memos = append(memos, e.Memo)
+		postedAts = append(postedAts, e.PostedAt)
+		for _, p := range e.Postings {
+			entryIDs = append(entryIDs, e.ID)
+			accounts = append(accounts, string(p.Account))
+			amounts = append(amounts, p.Amount)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO ledger_entries (id, bill_id, kind, currency, reference, memo, posted_at)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::timestamp[])
+	`, ids, billIDs, kinds, currencies, references, memos, postedAts); err != nil {
+		return fmt.Errorf("failed to insert %d ledger entries: %w", len(ids), err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO ledger_postings (entry_id, account, amount)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::bigint[])
+	`, entryIDs, accounts, amounts); err != nil {
+		return fmt.Errorf("failed to insert %d ledger postings: %w", len(entryIDs), err)
+	}
+	return nil
+}
+
+// ledgerBalances sums the postings of a bill per account
+func ledgerBalances(ctx context.Context, q querier, billID string) (ledger.Balances, error) {
+	rows, err := q.Query(ctx, `
+		SELECT p.account, SUM(p.amount)
+		FROM ledger_entries e
+		JOIN ledger_postings p ON p.entry_id = e.id
+		WHERE e.bill_id = $1
+		GROUP BY p.account
+	`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger balances of bill %s: %w", billID, err)
+	}
+	defer rows.Close()
+
+	balances := ledger.Balances{}
+	for rows.Next() {
+		var (
+			account string
+			amount  int64
+		)
+		if err := rows.Scan(&account, &amount); err != nil {
+			return nil, err
+		}
+		balances[ledger.Account(account)] = amount
+	}
+	return balances, rows.Err()
+}
+
+// GetBillStatement derives a bill's balance from its ledger entries
+func GetBillStatement(ctx context.Context, billID string) (*ledger.Statement, error) {
+	ctx, span := startDBSpan(ctx, "GetBillStatement", billID)
+	defer span.End()
+
+	var currency string
+	err := db.QueryRow(ctx, `SELECT currency FROM bills WHERE id = $1`, billID).Scan(&currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("bill not found for id %s: %w", billID, ErrBillNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bill %s: %w", billID, err)
+	}
+
+	balances, err := ledgerBalances(ctx, db, billID)
+	if err != nil {
+		return nil, err
+	}
+	statement := balances.Statement(money.Currency(currency))
+	return &statement, nil
+}
+
+// ListLedgerEntries returns a bill's entries with their postings in the order they were posted
+func ListLedgerEntries(ctx context.Context, billID string) ([]*ledger.Entry, error) {
+	ctx, span := startDBSpan(ctx, "ListLedgerEntries", billID)
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT e.id, e.kind, e.currency, e.reference, e.memo, e.posted_at, p.account, p.amount
+		FROM ledger_entries e
+		JOIN ledger_postings p ON p.entry_id = e.id
+		WHERE e.bill_id = $1
+		ORDER BY e.posted_at, e.id, p.id
+	`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ledger entries of bill %s: %w", billID, err)
+	}
+	defer rows.Close()
+
+	var entries []*ledger.Entry
+	for rows.Next() {
+		var (
+			e        ledger.Entry
+			currency string
+			p        ledger.Posting
+		)
+		if err := rows.Scan(&e.ID, &e.Kind, &currency, &e.Reference, &e.Memo, &e.PostedAt, &p.Account, &p.Amount); err != nil {
+			return nil, err
+		}
+		if n := len(entries); n > 0 && entries[n-1].ID == e.ID {
+			entries[n-1].Postings = append(entries[n-1].Postings, p)
+			continue
+		}
+		e.BillID = billID
+		e.Currency = money.Currency(currency)
+		e.Postings = []ledger.Posting{p}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// RecordPayment posts a payment or refund on a bill and returns the resulting statement.
+// Payments are accepted on closed bills up to the amount due; refunds up to the amount paid.
+// The bill row is locked while the balance is checked. Recording the same reference again with
+// the same amount is a no-op.
+func RecordPayment(ctx context.Context, billID string, kind ledger.Kind, reference string, amount money.Money, at time.Time) (*ledger.Statement, error) {
+	ctx, span := startDBSpan(ctx, "RecordPayment", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		status   Status
+		currency string
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT status, currency FROM bills WHERE id = $1 FOR UPDATE
+	`, billID).Scan(&status, &currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("bill not found for id %s: %w", billID, ErrBillNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock bill %s: %w", billID, err)
+	}
+
+	balances, err := ledgerBalances(ctx, tx, billID)
+	if err != nil {
+		return nil, err
+	}
+	statement := balances.Statement(money.Currency(currency))
+
+	var existing int64
+	err = tx.QueryRow(ctx, `
+		SELECT ABS(p.amount)
+		FROM ledger_entries e
+		JOIN ledger_postings p ON p.entry_id = e.id AND p.account = 'cash'
+		WHERE e.bill_id = $1 AND e.kind = $2 AND e.reference = $3
+	`, billID, kind, reference).Scan(&existing)
+	switch {
+	case err == nil && existing == amount.Amount:
+		return &statement, nil // Idempotent - already recorded
+	case err == nil:
+		return nil, fmt.Errorf("%w: %s %q on bill %s was for %d", ErrDuplicateReference, kind, reference, billID, existing)
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("failed to look up %s %q on bill %s: %w", kind, reference, billID, err)
+	}
+
+	if amount.Currency != money.Currency(currency) {
+		return nil, fmt.Errorf("%w: %s does not match bill currency %s", ErrPaymentRejected, amount.Currency, currency)
+	}
+	var entry *ledger.Entry
+	switch kind {
+	case ledger.Payment:
+		if status != Closed {
+			return nil, fmt.Errorf("%w: bill %s is %s, only closed bills can be paid", ErrPaymentRejected, billID, status)
+		}
+		if amount.Amount > statement.Due.Amount {
+			return nil, fmt.Errorf("%w: %s exceeds the %s due", ErrPaymentRejected, amount, statement.Due)
+		}
+		entry = ledger.Pay(billID, reference, amount, at)
+	case ledger.Refund:
+		if amount.Amount > statement.Paid.Amount {
+			return nil, fmt.Errorf("%w: %s exceeds the %s paid", ErrPaymentRejected, amount, statement.Paid)
+		}
+		entry = ledger.RefundPayment(billID, reference, amount, at)
+	default:
+		return nil, fmt.Errorf("unsupported payment kind %q", kind)
+	}
+
+	if err := postEntriesTx(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit %s on bill %s: %w", kind, billID, err)
+	}
+	balances.Add(entry)
+	statement = balances.Statement(money.Currency(currency))
+	return &statement, nil
+}
+
+// settleTaxTx brings the tax charged on a bill in line with its revenue at the given rate, posting
+// only the difference so a retried close does not charge tax twice
+func settleTaxTx(ctx context.Context, tx *sqldb.Tx, billID string, currency money.Currency, basisPoints int64, at time.Time) error {
+	balances, err := ledgerBalances(ctx, tx, billID)
+	if err != nil {
+		return err
+	}
+	due, err := taxOn(money.Money{Amount: -balances[ledger.Revenue], Currency: currency}, basisPoints)
+	if err != nil {
+		return fmt.Errorf("failed to calculate tax for bill %s: %w", billID, err)
+	}
+	outstanding := -balances[ledger.TaxPayable]
+	return postEntriesTx(ctx, tx, ledger.Tax(billID, money.Money{Amount: due.Amount - outstanding, Currency: currency}, at))
+}
+
+// taxOn returns the tax charged on revenue at basisPoints. Closing a bill charges it; the invoice
+// prints what was charged and checks the rate against it here.
+func taxOn(revenue money.Money, basisPoints int64) (money.Money, error) {
+	return revenue.Percent(basisPoints)
+}
+
+// reverseStatusEntriesTx posts the entries a status change implies: reopening reverses the tax
+// charged at close, voiding reverses the bill's revenue
+func reverseStatusEntriesTx(ctx context.Context, tx *sqldb.Tx, change StatusChange, currency money.Currency) error {
+	if change.To != Open && change.To != Void {
+		return nil
+	}
+	balances, err := ledgerBalances(ctx, tx, change.BillID)
+	if err != nil {
+		return err
+	}
+
+	var entry *ledger.Entry
+	if change.To == Open {
+		entry = ledger.TaxReversal(change.BillID, money.Money{Amount: -balances[ledger.TaxPayable], Currency: currency}, change.At)
+	} else {
+		entry = ledger.VoidCharges(change.BillID, money.Money{Amount: -balances[ledger.Revenue], Currency: currency}, change.At)
+	}
+	if entry != nil {
+		entry.Memo = change.Reason
+	}
+	return postEntriesTx(ctx, tx, entry)
+}
@@ -0,0 +1,43 @@
+package bill
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTransition is returned when a bill cannot move between two statuses
+var ErrInvalidTransition = errors.New("invalid bill status transition")
+
+// ErrBillInvoiced is returned when voiding a bill that holds an invoice number. The invoice was
+// issued when the bill first closed, so it has to be corrected with a credit note instead.
+var ErrBillInvoiced = errors.New("bill has been invoiced")
+
+// transitions lists, for each status, the statuses a bill may move to next.
+// VOID is terminal; CLOSED may only go back to OPEN through an admin reopen.
+var transitions = map[Status][]Status{
+	Open:   {Closed, Void},
+	Closed: {Open},
+}
+
+// IsValid reports whether s is a known bill status
+func (s Status) IsValid() bool {
+	return s == Open || s == Closed || s == Void
+}
+
+// CanTransitionTo reports whether a bill in status s may move to next
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTransition returns ErrInvalidTransition if from cannot move to to
+func validateTransition(from, to Status) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package bill
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"encore.dev"
+	"encore.dev/middleware"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	tlog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Keys of the fields every log line carries where they are known
+const (
+	logBillID     = "billID"
+	logAccountID  = "accountID" // the tenant a bill belongs to
+	logWorkflowID = "workflowID"
+	logRunID      = "runID"
+	logRequestID  = "requestID"
+)
+
+// redacted replaces descriptions in logs when Logging.RedactDescriptions is set
+const redacted = "[redacted]"
+
+// requestIDHeader carries the request ID to workflows and activities, see requestIDPropagator
+const requestIDHeader = "request-id"
+
+// logger writes JSON lines to stderr. Logged with a context, a line carries the request, bill,
+// tenant and workflow it belongs to, see logFields.
+var logger = newLogger(os.Stderr, cfg.Logging)
+
+func newLogger(w io.Writer, c LoggingConfig) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if c.RedactDescriptions && a.Key == "description" {
+				return slog.String(a.Key, redacted)
+			}
+			// The Temporal SDK tags its lines WorkflowID, RunID, Error and so on
+			if len(groups) == 0 {
+				a.Key = lowerFirst(a.Key)
+			}
+			return a
+		},
+	})})
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	if !unicode.IsUpper(r) {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// temporalLogger is the logger of the Temporal client, its workers and the workflows they run
+func temporalLogger() tlog.Logger {
+	return tlog.NewStructuredLogger(logger)
+}
+
+// logFields are the fields a context adds to the lines logged with it
+type logFields struct {
+	RequestID string
+	BillID    string
+	AccountID string
+}
+
+type logFieldsKey struct{}
+
+func logFieldsFrom(ctx context.Context) logFields {
+	f, _ := ctx.Value(logFieldsKey{}).(logFields)
+	return f
+}
+
+// withRequestID returns ctx with the ID of the request it serves
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	f := logFieldsFrom(ctx)
+	f.RequestID = requestID
+	return context.WithValue(ctx, logFieldsKey{}, f)
+}
+
+// withBill returns ctx with the bill it works on and the bill's account, which may be empty
+func withBill(ctx context.Context, billID, accountID string) context.Context {
+	f := logFieldsFrom(ctx)
+	f.BillID = billID
+	f.AccountID = accountID
+	return context.WithValue(ctx, logFieldsKey{}, f)
+}
+
+// contextHandler adds the fields of the context a line is logged with, and the workflow, run and
+// bill of an activity
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	f := logFieldsFrom(ctx)
+	if f.RequestID != "" {
+		r.AddAttrs(slog.String(logRequestID, f.RequestID))
+	}
+	if f.BillID != "" {
+		r.AddAttrs(slog.String(logBillID, f.BillID))
+	}
+	if f.AccountID != "" {
+		r.AddAttrs(slog.String(logAccountID, f.AccountID))
+	}
+	if activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		r.AddAttrs(slog.String(logWorkflowID, info.WorkflowExecution.ID), slog.String(logRunID, info.WorkflowExecution.RunID))
+		// A bill workflow is named after its bill
+		if billID, ok := strings.CutPrefix(info.WorkflowExecution.ID, "bill-"); ok && f.BillID == "" && info.WorkflowType.Name == "BillWorkflow" {
+			r.AddAttrs(slog.String(logBillID, billID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// billLogger returns the workflow logger with the bill's fields and the request that started the
+// workflow. The SDK adds the workflow and run IDs.
+func billLogger(ctx workflow.Context, state *BillState) tlog.Logger {
+	keyvals := []interface{}{logBillID, state.BillID}
+	if state.AccountID != "" {
+		keyvals = append(keyvals, logAccountID, state.AccountID)
+	}
+	if f, _ := ctx.Value(logFieldsKey{}).(logFields); f.RequestID != "" {
+		keyvals = append(keyvals, logRequestID, f.RequestID)
+	}
+	return tlog.With(workflow.GetLogger(ctx), keyvals...)
+}
+
+// LoggingMiddleware tags the context of every API call with its request ID: the caller's
+// X-Request-ID or correlation ID, or else the Encore trace ID. Calls on a bill are tagged with it.
+//
+//encore:middleware target=all
+func LoggingMiddleware(req middleware.Request, next middleware.Next) middleware.Response {
+	data := req.Data()
+	requestID := data.Headers.Get("X-Request-ID")
+	if requestID == "" && data.Trace != nil {
+		requestID = data.Trace.ExtCorrelationID
+		if requestID == "" {
+			requestID = data.Trace.TraceID
+		}
+	}
+	ctx := withRequestID(req.Context(), requestID)
+	if id := billPathID(data); id != "" {
+		ctx = withBill(ctx, id, "")
+	}
+	return next(req.WithContext(ctx))
+}
+
+// billPathID returns the bill ID of a call on /bills/:id, or "" for any other call
+func billPathID(data *encore.Request) string {
+	if !strings.HasPrefix(data.Path, "/bills/") {
+		return ""
+	}
+	return data.PathParams.Get("id")
+}
+
+// requestIDPropagator carries the request ID in Temporal headers, from the request that starts
+// or signals a workflow into the workflow and from the workflow into its activities
+type requestIDPropagator struct{}
+
+func (requestIDPropagator) Inject(ctx context.Context, w workflow.HeaderWriter) error {
+	return injectRequestID(logFieldsFrom(ctx).RequestID, w)
+}
+
+func (requestIDPropagator) Extract(ctx context.Context, r workflow.HeaderReader) (context.Context, error) {
+	requestID, err := extractRequestID(r)
+	if err != nil || requestID == "" {
+		return ctx, err
+	}
+	return withRequestID(ctx, requestID), nil
+}
+
+func (requestIDPropagator) InjectFromWorkflow(ctx workflow.Context, w workflow.HeaderWriter) error {
+	f, _ := ctx.Value(logFieldsKey{}).(logFields)
+	return injectRequestID(f.RequestID, w)
+}
+
+func (requestIDPropagator) ExtractToWorkflow(ctx workflow.Context, r workflow.HeaderReader) (workflow.Context, error) {
+	requestID, err := extractRequestID(r)
+	if err != nil || requestID == "" {
+		return ctx, err
+	}
+	return workflow.WithValue(ctx, logFieldsKey{}, logFields{RequestID: requestID}), nil
+}
+
+func injectRequestID(requestID string, w workflow.HeaderWriter) error {
+	if requestID == "" {
+		return nil
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(requestID)
+	if err != nil {
+		return err
+	}
+	w.Set(requestIDHeader, payload)
+	return nil
+}
+
+func extractRequestID(r workflow.HeaderReader) (string, error) {
+	payload, ok := r.Get(requestIDHeader)
+	if !ok {
+		return "", nil
+	}
+	var requestID string
+	err := converter.GetDefaultDataConverter().FromPayload(payload, &requestID)
+	return requestID, err
+}
+
+// LogValue logs the signal with its description subject to redaction
+func (s AddItemSignal) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("itemID", s.ItemID),
+		slog.Int64("amount", s.Amount),
+		slog.String("description", s.Description),
+		slog.String("priceID", s.PriceID),
+		slog.Int64("quantity", s.Quantity),
+	)
+}
+
+// LogValue logs the signal with its description subject to redaction
+func (s AmendItemSignal) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("itemID", s.ItemID)}
+	if s.Amount != nil {
+		attrs = append(attrs, slog.Int64("amount", *s.Amount))
+	}
+	if s.Description != nil {
+		attrs = append(attrs, slog.String("description", *s.Description))
+	}
+	return slog.GroupValue(attrs...)
+}
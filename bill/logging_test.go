@@ -0,0 +1,160 @@
+package bill
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	tlog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+const testRequestID = "req-7f3a9c"
+
+// logLines decodes the JSON lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+// findLine returns the first line with msg
+func findLine(t *testing.T, lines []map[string]interface{}, msg string) map[string]interface{} {
+	t.Helper()
+	for _, line := range lines {
+		if line["msg"] == msg {
+			return line
+		}
+	}
+	t.Fatalf("no %q line among %v", msg, lines)
+	return nil
+}
+
+func TestLoggerAddsContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, LoggingConfig{})
+
+	ctx := withBill(withRequestID(context.Background(), testRequestID), testBillID, "acct_42")
+	l.ErrorContext(ctx, "Failed to queue signal", "error", errors.New("connection refused"))
+	l.Info("Connected to Temporal server")
+
+	lines := logLines(t, &buf)
+	want := map[string]interface{}{
+		"requestID": testRequestID,
+		"billID":    testBillID,
+		"accountID": "acct_42",
+		"error":     "connection refused",
+	}
+	for k, v := range want {
+		if got := lines[0][k]; got != v {
+			t.Errorf("%s = %v, want %v", k, got, v)
+		}
+	}
+	if _, ok := lines[1]["billID"]; ok {
+		t.Errorf("line logged without a context has bill fields: %v", lines[1])
+	}
+}
+
+func TestLoggerRenamesTemporalKeys(t *testing.T) {
+	var buf bytes.Buffer
+	tlog.NewStructuredLogger(newLogger(&buf, LoggingConfig{})).
+		Warn("Failed to poll for task", "WorkflowID", "bill-"+testBillID, "RunID", "run-1", "Error", errors.New("timeout"))
+
+	line := logLines(t, &buf)[0]
+	for _, k := range []string{"workflowID", "runID", "error"} {
+		if _, ok := line[k]; !ok {
+			t.Errorf("no %s key in %v", k, line)
+		}
+	}
+}
+
+func TestLoggerRedactsDescriptions(t *testing.T) {
+	description := "Payroll for J. Smith"
+	tests := []struct {
+		name   string
+		config LoggingConfig
+		want   string
+	}{
+		{"kept", LoggingConfig{}, description},
+		{"redacted", LoggingConfig{RedactDescriptions: true}, redacted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := newLogger(&buf, tt.config)
+			l.Info("invalid add item signal", "signal", AddItemSignal{ItemID: testItemID, Amount: 500, Description: description})
+			l.Info("invalid amend item signal", "signal", AmendItemSignal{ItemID: testItemID, Description: &description})
+
+			for _, line := range logLines(t, &buf) {
+				signal, _ := line["signal"].(map[string]interface{})
+				if got := signal["description"]; got != tt.want {
+					t.Errorf("%s: description = %v, want %q", line["msg"], got, tt.want)
+				}
+				if signal["itemID"] != testItemID {
+					t.Errorf("%s: itemID = %v, want %s", line["msg"], signal["itemID"], testItemID)
+				}
+			}
+		})
+	}
+}
+
+func TestBillWorkflowLogsBillContext(t *testing.T) {
+	var buf bytes.Buffer
+	var s testsuite.WorkflowTestSuite
+	s.SetLogger(tlog.NewStructuredLogger(newLogger(&buf, LoggingConfig{})))
+	s.SetContextPropagators([]workflow.ContextPropagator{requestIDPropagator{}})
+	payload, err := converter.GetDefaultDataConverter().ToPayload(testRequestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetHeader(&commonpb.Header{Fields: map[string]*commonpb.Payload{requestIDHeader: payload}})
+
+	w := newBillWorkflowTestIn(t, &s)
+	var activityRequestIDs []string
+	w.env.SetOnActivityStartedListener(func(_ *activity.Info, ctx context.Context, _ converter.EncodedValues) {
+		activityRequestIDs = append(activityRequestIDs, logFieldsFrom(ctx).RequestID)
+	})
+	w.signal(time.Minute, w.send("remove-item", RemoveItemSignal{ItemID: "not-a-uuid"}))
+	w.signal(2*time.Minute, w.send("void-bill", StatusChangeSignal{Reason: "duplicate"}))
+	w.execute()
+	w.requireCompleted()
+
+	// A worker adds the workflow and run IDs, which the test environment leaves out
+	lines := logLines(t, &buf)
+	for _, msg := range []string{"Starting bill workflow", "invalid remove item signal", "Bill workflow completed"} {
+		line := findLine(t, lines, msg)
+		if line["billID"] != testBillID || line["requestID"] != testRequestID {
+			t.Errorf("%q line = %v, want the bill and request IDs", msg, line)
+		}
+	}
+	if line := findLine(t, lines, "invalid remove item signal"); line["error"] == nil {
+		t.Errorf("rejected signal line = %v, want the error under \"error\"", line)
+	}
+
+	if len(activityRequestIDs) == 0 {
+		t.Fatal("no activities ran")
+	}
+	for _, id := range activityRequestIDs {
+		if id != testRequestID {
+			t.Errorf("activity request ID = %q, want %q", id, testRequestID)
+		}
+	}
+}
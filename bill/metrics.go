@@ -0,0 +1,226 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fees-api/money"
+
+	"encore.dev/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	temporalotel "go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Business metrics are labelled by currency, item source, signal and reason only, never by bill,
+// item or account IDs, to keep the number of series bounded.
+//
+// What the API and the service loops see is counted with Encore metrics. What the workflow sees
+// goes through the Temporal metrics handler, which skips replays, so a workflow task replayed on
+// another worker is not counted again. The handler exports over OpenTelemetry, together with the
+// SDK's own metrics such as activity latency.
+
+type CurrencyLabels struct {
+	Currency string
+}
+
+// billsCreated counts bills created through the API by currency
+var billsCreated = metrics.NewCounterGroup[CurrencyLabels, uint64]("bills_created", metrics.CounterConfig{})
+
+// billsOpen is the number of open bills by currency, refreshed every openBillsInterval
+var billsOpen = metrics.NewGaugeGroup[CurrencyLabels, int64]("bills_open", metrics.GaugeConfig{})
+
+const openBillsInterval = time.Minute
+
+// Metrics recorded through the Temporal metrics handler
+const (
+	billsClosedMetric     = "bill_closed"           // by currency
+	itemsAddedMetric      = "bill_items_added"      // by currency and source
+	itemAmountMetric      = "bill_item_amount"      // histogram in minor units, by currency and source
+	signalsRejectedMetric = "bill_signals_rejected" // by signal and reason
+	activityRetriesMetric = "bill_activity_retries" // by activity type
+)
+
+// itemAmountBuckets are the upper bounds of the item amount histogram in minor units, up to
+// MaxAmountCents
+var itemAmountBuckets = []float64{100, 500, 1_000, 5_000, 10_000, 50_000, 100_000, 500_000, 1_000_000, 10_000_000, MaxAmountCents}
+
+// Signal rejection reasons besides the DeadLetterReason of add item signals
+const (
+	rejectInvalidTransition = "invalid_transition"
+	rejectInvoiced          = "invoiced"
+	rejectWindowExpired     = "window_expired"
+)
+
+// setupMetrics installs a meter provider exporting to the configured OTLP collector. It returns a
+// function flushing and stopping the exporter.
+func setupMetrics(ctx context.Context, c MetricsConfig) (func(context.Context) error, error) {
+	if !c.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(c.Endpoint)}
+	if c.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter,
+			sdkmetric.WithInterval(time.Duration(c.IntervalSeconds)*time.Second))),
+		sdkmetric.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("fees-api"))),
+	)
+	otel.SetMeterProvider(provider)
+	logger.Info("Exporting metrics", "endpoint", c.Endpoint, "intervalSeconds", c.IntervalSeconds)
+	return provider.Shutdown, nil
+}
+
+// validateMetricsConfig checks the metrics settings when export is enabled
+func validateMetricsConfig(c MetricsConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Endpoint == "" {
+		return errors.New("metrics endpoint is not set")
+	}
+	if c.IntervalSeconds <= 0 {
+		return fmt.Errorf("metrics export interval must be positive, got %d seconds", c.IntervalSeconds)
+	}
+	return nil
+}
+
+// newMetricsHandler returns the Temporal metrics handler of the client. It follows the global
+// meter provider, so nothing is exported until setupMetrics has installed one.
+func newMetricsHandler() client.MetricsHandler {
+	return temporalotel.NewMetricsHandler(temporalotel.MetricsHandlerOptions{
+		Meter: otel.Meter("fees-api/temporal"),
+		// A metric that cannot be recorded must not fail the workflow task recording it
+		OnError: func(err error) { logger.Error("Failed to record Temporal metric", "error", err) },
+	})
+}
+
+// recordBillClosed counts a bill the workflow has finalized
+func recordBillClosed(ctx workflow.Context, currency money.Currency) {
+	workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"currency": string(currency)}).
+		Counter(billsClosedMetric).Inc(1)
+}
+
+// recordItemsAdded counts line items the workflow has inserted and records their amounts
+func recordItemsAdded(ctx workflow.Context, currency money.Currency, items []AddLineItemInput) {
+	handler := workflow.GetMetricsHandler(ctx)
+	// The histogram is recorded on the handler's meter directly, which does not skip replays
+	replaying := workflow.IsReplaying(ctx)
+	for _, item := range items {
+		tagged := handler.WithTags(map[string]string{"currency": string(currency), "source": item.source()})
+		tagged.Counter(itemsAddedMetric).Inc(1)
+
+		h := temporalotel.ExtractMetricsHandler(tagged)
+		if h == nil || replaying {
+			continue
+		}
+		amounts, err := h.GetMeter().Int64Histogram(itemAmountMetric,
+			metric.WithUnit("{minor unit}"), metric.WithExplicitBucketBoundaries(itemAmountBuckets...))
+		if err != nil {
+			continue
+		}
+		amounts.Record(context.Background(), item.Amount.Amount, metric.WithAttributeSet(h.GetAttributes()))
+	}
+}
+
+// recordSignalRejected counts signals the workflow did not apply
+func recordSignalRejected(ctx workflow.Context, signal, reason string, count int) {
+	workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"signal": signal, "reason": reason}).
+		Counter(signalsRejectedMetric).Inc(int64(count))
+}
+
+// rejectionReason classifies the error a status change signal was rejected with
+func rejectionReason(err error) string {
+	if errors.Is(err, ErrInvalidTransition) {
+		return rejectInvalidTransition
+	}
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == billInvoicedError {
+		return rejectInvoiced
+	}
+	return string(ReasonFailed)
+}
+
+// source says where a line item comes from: a fee rule, the catalog, metered usage or the caller
+func (input AddLineItemInput) source() string {
+	switch {
+	case input.FeeRuleID != "":
+		return "fee"
+	case input.PriceID != "":
+		return "catalog"
+	case input.UnitAmount != nil:
+		return "usage"
+	default:
+		return "manual"
+	}
+}
+
+// activityMetrics counts activity retries. The SDK tags the activity metrics handler with the
+// activity type, and records latency and failures itself.
+type activityMetrics struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (*activityMetrics) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityRetries{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}}
+}
+
+type activityRetries struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (a *activityRetries) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	if activity.GetInfo(ctx).Attempt > 1 {
+		activity.GetMetricsHandler(ctx).Counter(activityRetriesMetric).Inc(1)
+	}
+	return a.Next.ExecuteActivity(ctx, in)
+}
+
+// trackOpenBills refreshes the open bill gauge until ctx is done. A currency with no open bills
+// left is set to zero rather than left at its last count.
+func trackOpenBills(ctx context.Context) {
+	ticker := time.NewTicker(openBillsInterval)
+	defer ticker.Stop()
+
+	seen := make(map[money.Currency]bool)
+	for {
+		counts, err := CountOpenBills(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to count open bills", "error", err)
+		} else {
+			for currency := range seen {
+				if _, ok := counts[currency]; !ok {
+					billsOpen.With(CurrencyLabels{Currency: string(currency)}).Set(0)
+				}
+			}
+			for currency, n := range counts {
+				seen[currency] = true
+				billsOpen.With(CurrencyLabels{Currency: string(currency)}).Set(n)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
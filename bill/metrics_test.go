@@ -0,0 +1,227 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"fees-api/money"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.temporal.io/sdk/activity"
+	temporalotel "go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// newMetricsTestSuite returns a test suite whose Temporal metrics are read from reader
+func newMetricsTestSuite() (*testsuite.WorkflowTestSuite, *sdkmetric.ManualReader) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	var s testsuite.WorkflowTestSuite
+	s.SetMetricsHandler(temporalotel.NewMetricsHandler(temporalotel.MetricsHandlerOptions{Meter: provider.Meter("test")}))
+	return &s, reader
+}
+
+// collectMetrics returns the metrics recorded so far by name
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	collected := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			collected[m.Name] = m.Data
+		}
+	}
+	return collected
+}
+
+// hasLabels reports whether attrs has every label in want
+func hasLabels(attrs attribute.Set, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := attrs.Value(attribute.Key(k)); !ok || got.AsString() != v {
+			return false
+		}
+	}
+	return true
+}
+
+// counterValue sums the data points of a Temporal counter that have the labels in want
+func counterValue(t *testing.T, collected map[string]metricdata.Aggregation, name string, want map[string]string) int64 {
+	t.Helper()
+	sum, ok := collected[name].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s is %T, want an int64 sum", name, collected[name])
+	}
+	var total int64
+	for _, dp := range sum.DataPoints {
+		if hasLabels(dp.Attributes, want) {
+			total += dp.Value
+		}
+	}
+	return total
+}
+
+func TestBillWorkflowMetrics(t *testing.T) {
+	s, reader := newMetricsTestSuite()
+	w := newBillWorkflowTestIn(t, s)
+	w.signal(time.Minute,
+		w.send("add-item", AddItemSignal{ItemID: testItemID, Amount: 2_500, Description: "Consulting"}),
+		w.send("add-item", AddItemSignal{ItemID: testItemID2, Amount: 0, Description: "Nothing"}),
+	)
+	w.signal(2*time.Minute, w.send("remove-item", RemoveItemSignal{ItemID: "not-a-uuid"}))
+	w.signal(3*time.Minute, w.send("close-bill", nil))
+	w.signal(4*time.Minute, w.send("add-item", AddItemSignal{ItemID: testItemID2, Amount: 700, Description: "Late"}))
+	w.execute()
+	w.requireCompleted()
+
+	collected := collectMetrics(t, reader)
+	usd := map[string]string{"currency": "USD"}
+	if n := counterValue(t, collected, itemsAddedMetric, map[string]string{"currency": "USD", "source": "manual"}); n != 1 {
+		t.Errorf("%s = %d, want 1", itemsAddedMetric, n)
+	}
+	if n := counterValue(t, collected, billsClosedMetric, usd); n != 1 {
+		t.Errorf("%s = %d, want 1", billsClosedMetric, n)
+	}
+	for _, tt := range []struct {
+		signal, reason string
+	}{
+		{"add-item", string(ReasonInvalid)},
+		{"add-item", string(ReasonNotOpen)},
+		{"remove-item", string(ReasonInvalid)},
+	} {
+		if n := counterValue(t, collected, signalsRejectedMetric, map[string]string{"signal": tt.signal, "reason": tt.reason}); n != 1 {
+			t.Errorf("%s{%s, %s} = %d, want 1", signalsRejectedMetric, tt.signal, tt.reason, n)
+		}
+	}
+
+	amounts, ok := collected[itemAmountMetric].(metricdata.Histogram[int64])
+	if !ok || len(amounts.DataPoints) != 1 {
+		t.Fatalf("%s = %+v, want one histogram data point", itemAmountMetric, collected[itemAmountMetric])
+	}
+	if dp := amounts.DataPoints[0]; dp.Count != 1 || dp.Sum != 2_500 || !hasLabels(dp.Attributes, usd) {
+		t.Errorf("%s has %d amounts summing to %d with %v, want the 2500 USD item", itemAmountMetric, dp.Count, dp.Sum, dp.Attributes)
+	}
+
+	// No series may be keyed by a bill or item
+	for name, data := range collected {
+		var sets []attribute.Set
+		switch d := data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range d.DataPoints {
+				sets = append(sets, dp.Attributes)
+			}
+		case metricdata.Histogram[int64]:
+			for _, dp := range d.DataPoints {
+				sets = append(sets, dp.Attributes)
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range d.DataPoints {
+				sets = append(sets, dp.Attributes)
+			}
+		}
+		for _, attrs := range sets {
+			for _, kv := range attrs.ToSlice() {
+				if v := kv.Value.Emit(); v == testBillID || v == testItemID || v == testItemID2 {
+					t.Errorf("%s is labelled %s=%s", name, kv.Key, v)
+				}
+			}
+		}
+	}
+}
+
+func TestActivityRetriesMetric(t *testing.T) {
+	s, reader := newMetricsTestSuite()
+	env := s.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{&activityMetrics{}}})
+
+	attempts := 0
+	env.RegisterActivityWithOptions(func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	}, activity.RegisterOptions{Name: "FlakyActivity"})
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Minute,
+			RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second},
+		})
+		return workflow.ExecuteActivity(ctx, "FlakyActivity").Get(ctx, nil)
+	}, workflow.RegisterOptions{Name: "FlakyWorkflow"})
+
+	env.ExecuteWorkflow("FlakyWorkflow")
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow error = %v", err)
+	}
+	collected := collectMetrics(t, reader)
+	if n := counterValue(t, collected, activityRetriesMetric, map[string]string{"activity_type": "FlakyActivity"}); n != 2 {
+		t.Errorf("%s = %d, want 2", activityRetriesMetric, n)
+	}
+}
+
+func TestLineItemSource(t *testing.T) {
+	unit := money.Money{Amount: 100, Currency: money.USD}
+	tests := []struct {
+		input AddLineItemInput
+		want  string
+	}{
+		{AddLineItemInput{}, "manual"},
+		{AddLineItemInput{PriceID: "price_1", UnitAmount: &unit}, "catalog"},
+		{AddLineItemInput{UnitAmount: &unit}, "usage"},
+		{AddLineItemInput{SourceItemID: testItemID, FeeRuleID: "rule_1"}, "fee"},
+	}
+	for _, tt := range tests {
+		if got := tt.input.source(); got != tt.want {
+			t.Errorf("source() of %+v = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRejectionReason(t *testing.T) {
+	invoiced := temporal.NewNonRetryableApplicationError("bill holds an invoice", billInvoicedError, nil)
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{validateTransition(Void, Open), rejectInvalidTransition},
+		{fmt.Errorf("activity error: %w", invoiced), rejectInvoiced},
+		{errors.New("database unavailable"), string(ReasonFailed)},
+	}
+	for _, tt := range tests {
+		if got := rejectionReason(tt.err); got != tt.want {
+			t.Errorf("rejectionReason(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMetricsConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  MetricsConfig
+		wantErr bool
+	}{
+		{"disabled", MetricsConfig{}, false},
+		{"enabled", MetricsConfig{Enabled: true, Endpoint: "localhost:4317", IntervalSeconds: 60}, false},
+		{"no endpoint", MetricsConfig{Enabled: true, IntervalSeconds: 60}, true},
+		{"no interval", MetricsConfig{Enabled: true, Endpoint: "localhost:4317"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMetricsConfig(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("validateMetricsConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -11,14 +11,19 @@ type Status string
 const (
 	Open   Status = "OPEN"
 	Closed Status = "CLOSED"
+	Void   Status = "VOID"
 )
 
 type Bill struct {
 	ID        string      `json:"id"`
+	AccountID string      `json:"account_id,omitempty"`
 	Status    Status      `json:"status"`
 	Total     money.Money `json:"total"`
 	CreatedAt time.Time   `json:"created_at"`
 	ClosedAt  *time.Time  `json:"closed_at,omitempty"` // omit if nil
+
+	// InvoiceNumber is assigned when the bill is first closed, e.g. INV-2026-000123
+	InvoiceNumber string `json:"invoice_number,omitempty"`
 }
 
 type LineItem struct {
@@ -27,4 +32,39 @@ type LineItem struct {
 	Amount      money.Money `json:"amount"`
 	Description string      `json:"description"`
 	CreatedAt   time.Time   `json:"created_at"`
+
+	// Catalog-priced items record how the amount was computed
+	Quantity   int64        `json:"quantity"`
+	UnitAmount *money.Money `json:"unit_amount,omitempty"`
+	ProductID  string       `json:"product_id,omitempty"`
+	PriceID    string       `json:"price_id,omitempty"`
+
+	// Fee items record the rule that charged them and, for per-item fees, the item they were charged on
+	SourceItemID string `json:"source_item_id,omitempty"`
+	FeeRuleID    string `json:"fee_rule_id,omitempty"`
+}
+
+// quantity defaults to 1 for items added with a raw amount
+func (li *LineItem) quantity() int64 {
+	if li.Quantity <= 0 {
+		return 1
+	}
+	return li.Quantity
+}
+
+// unitAmount returns the unit price in minor units, or nil for items added with a raw amount
+func (li *LineItem) unitAmount() *int64 {
+	if li.UnitAmount == nil {
+		return nil
+	}
+	return &li.UnitAmount.Amount
+}
+
+// StatusChange describes a single bill lifecycle transition
+type StatusChange struct {
+	BillID string
+	To     Status
+	Reason string
+	Actor  string
+	At     time.Time
 }
@@ -0,0 +1,163 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	// orphanGracePeriod is how long a running bill workflow may go without a bill row before it
+	// counts as an orphan. New workflows insert their row within the retries of their first step.
+	orphanGracePeriod = 10 * time.Minute
+
+	sweepBatchSize = 500
+)
+
+// sweepOrphans repairs what the old creation path could leave behind when the process crashed
+// between starting a workflow and inserting its bill: bills that should have a running workflow
+// but do not, and workflows whose bill was never inserted. Every step is idempotent, so each
+// instance can run it at startup.
+func sweepOrphans(ctx context.Context) {
+	if GetTemporalClient() == nil {
+		logger.WarnContext(ctx, "Orphan sweep skipped, Temporal workflow service is down")
+		return
+	}
+
+	linked, restarted, err := linkLegacyBills(ctx, time.Now())
+	if err != nil {
+		logger.ErrorContext(ctx, "Orphan sweep failed to link legacy bills", "error", err)
+	}
+	terminated, err := terminateOrphanWorkflows(ctx, time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		logger.ErrorContext(ctx, "Orphan sweep failed to terminate orphan workflows", "error", err)
+	}
+	if linked > 0 || terminated > 0 {
+		logger.InfoContext(ctx, "Orphan sweep finished",
+			"linkedBills", linked, "restartedWorkflows", restarted, "terminatedWorkflows", terminated)
+	}
+}
+
+// linkLegacyBills links every bill created before workflows inserted their own rows to its
+// workflow, restarting the workflow from the bill row where one should still be running
+func linkLegacyBills(ctx context.Context, now time.Time) (linked, restarted int, err error) {
+	after := ""
+	for {
+		bills, err := ListUnlinkedBills(ctx, after, sweepBatchSize)
+		if err != nil {
+			return linked, restarted, err
+		}
+		for _, b := range bills {
+			started, err := ensureBillWorkflow(ctx, b, now)
+			if err != nil {
+				return linked, restarted, err
+			}
+			if started {
+				restarted++
+			}
+			if err := LinkBillWorkflow(ctx, b.ID, "bill-"+b.ID); err != nil {
+				return linked, restarted, err
+			}
+			linked++
+			after = b.ID
+		}
+		if len(bills) < sweepBatchSize {
+			return linked, restarted, nil
+		}
+	}
+}
+
+// ensureBillWorkflow starts a workflow for a bill that should have a running one and does not.
+// The new workflow continues from the bill row instead of inserting one.
+func ensureBillWorkflow(ctx context.Context, b *Bill, now time.Time) (bool, error) {
+	if !workflowRunning(billTotals{Status: b.Status, ClosedAt: b.ClosedAt}, now) {
+		return false, nil
+	}
+
+	workflowID := "bill-" + b.ID
+	desc, err := GetTemporalClient().DescribeWorkflowExecution(ctx, workflowID, "")
+	var notFound *serviceerror.NotFound
+	if err != nil && !errors.As(err, &notFound) {
+		return false, fmt.Errorf("failed to describe workflow %s: %w", workflowID, err)
+	}
+	if err == nil && desc.GetWorkflowExecutionInfo().GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		return false, nil
+	}
+
+	state := BillState{BillID: b.ID, AccountID: b.AccountID, Total: b.Total, Status: b.Status}
+	if b.ClosedAt != nil {
+		state.ClosedAt = *b.ClosedAt
+	}
+	_, err = GetTemporalClient().ExecuteWorkflow(ctx,
+		client.StartWorkflowOptions{ID: workflowID, TaskQueue: cfg.Temporal.TaskQueue, RetryPolicy: cfg.Workflow.WorkflowRetry.policy()},
+		BillWorkflow, b.ID, b.Total.Currency, b.AccountID, cfg.Workflow.historyLimits(), &state, &cfg.Workflow.ActivityRetry,
+	)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to restart workflow %s: %w", workflowID, err)
+	}
+	return true, nil
+}
+
+// terminateOrphanWorkflows terminates running bill workflows started before the given time that
+// have no bill row. Their bill was never returned to a caller, so nothing can reach them.
+func terminateOrphanWorkflows(ctx context.Context, startedBefore time.Time) (int, error) {
+	query := fmt.Sprintf("WorkflowType = 'BillWorkflow' AND ExecutionStatus = 'Running' AND StartTime < '%s'",
+		startedBefore.UTC().Format(time.RFC3339))
+
+	terminated := 0
+	var pageToken []byte
+	for {
+		resp, err := GetTemporalClient().ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         query,
+			PageSize:      sweepBatchSize,
+			NextPageToken: pageToken,
+		})
+		if err != nil {
+			return terminated, fmt.Errorf("failed to list bill workflows: %w", err)
+		}
+
+		runs := make(map[string]string, len(resp.GetExecutions()))
+		ids := make([]string, 0, len(resp.GetExecutions()))
+		for _, info := range resp.GetExecutions() {
+			billID, ok := strings.CutPrefix(info.GetExecution().GetWorkflowId(), "bill-")
+			if !ok {
+				continue
+			}
+			runs[billID] = info.GetExecution().GetRunId()
+			ids = append(ids, billID)
+		}
+		if len(ids) > 0 {
+			existing, err := ExistingBillIDs(ctx, ids)
+			if err != nil {
+				return terminated, err
+			}
+			for _, billID := range ids {
+				if existing[billID] {
+					continue
+				}
+				err := GetTemporalClient().TerminateWorkflow(ctx, "bill-"+billID, runs[billID], "bill row was never created", nil)
+				var notFound *serviceerror.NotFound
+				if err != nil && !errors.As(err, &notFound) {
+					return terminated, fmt.Errorf("failed to terminate orphan workflow of bill %s: %w", billID, err)
+				}
+				terminated++
+			}
+		}
+
+		pageToken = resp.GetNextPageToken()
+		if len(pageToken) == 0 {
+			return terminated, nil
+		}
+	}
+}
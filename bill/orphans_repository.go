@@ -0,0 +1,71 @@
+package bill
+
+import (
+	"context"
+	"fmt"
+)
+
+// ListUnlinkedBills returns up to limit bills with IDs after the given one that are not yet linked
+// to a workflow, in ID order. Only bills created before workflows inserted their own rows qualify.
+func ListUnlinkedBills(ctx context.Context, after string, limit int) ([]*Bill, error) {
+	ctx, span := startDBSpan(ctx, "ListUnlinkedBills", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT id, account_id, currency, status, total_amount, created_at, closed_at, invoice_number
+		FROM bills
+		WHERE workflow_id IS NULL AND id > $1
+		ORDER BY id
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unlinked bills after %q: %w", after, err)
+	}
+	defer rows.Close()
+
+	var bills []*Bill
+	for rows.Next() {
+		b, err := scanBill(rows)
+		if err != nil {
+			return nil, err
+		}
+		bills = append(bills, b)
+	}
+	return bills, rows.Err()
+}
+
+// LinkBillWorkflow records the workflow that owns a bill, unless one is already recorded
+func LinkBillWorkflow(ctx context.Context, billID, workflowID string) error {
+	ctx, span := startDBSpan(ctx, "LinkBillWorkflow", billID)
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		UPDATE bills SET workflow_id = $2 WHERE id = $1 AND workflow_id IS NULL
+	`, billID, workflowID)
+	if err != nil {
+		return fmt.Errorf("failed to link bill %s to workflow %s: %w", billID, workflowID, err)
+	}
+	return nil
+}
+
+// ExistingBillIDs returns which of the given bill IDs have a row
+func ExistingBillIDs(ctx context.Context, ids []string) (map[string]bool, error) {
+	ctx, span := startDBSpan(ctx, "ExistingBillIDs", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `SELECT id FROM bills WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %d bills: %w", len(ids), err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
@@ -0,0 +1,114 @@
+package bill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.dev/beta/errs"
+	"go.temporal.io/api/serviceerror"
+)
+
+const (
+	outboxReplayInterval = 5 * time.Second
+	outboxBatchSize      = 100
+	outboxSignalTimeout  = 10 * time.Second
+	// outboxLease covers delivering a whole batch before another instance may take it over
+	outboxLease = outboxBatchSize*outboxSignalTimeout + time.Minute
+)
+
+// signalBill sends a signal to a bill's workflow. With the outbox enabled, a signal that cannot
+// reach Temporal is stored and delivered once it recovers, as is every signal to a bill that
+// still has queued ones, so a bill's signals arrive in the order they were accepted.
+func signalBill(ctx context.Context, billID, signalName string, arg interface{}) error {
+	workflowID := "bill-" + billID
+	if !cfg.Outbox.Enabled {
+		c := GetTemporalClient()
+		if c == nil {
+			return errs.WrapCode(nil, errs.Unavailable,
+				"bill operations unavailable - Temporal workflow service is down")
+		}
+		return c.SignalWorkflow(ctx, workflowID, "", signalName, arg)
+	}
+
+	queued, err := HasPendingSignals(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+	if c := GetTemporalClient(); c != nil && !queued {
+		err := c.SignalWorkflow(ctx, workflowID, "", signalName, arg)
+		var unavailable *serviceerror.Unavailable
+		if !errors.As(err, &unavailable) {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(arg)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s signal: %w", signalName, err)
+	}
+	if err := EnqueueSignal(ctx, workflowID, signalName, payload, time.Now()); err != nil {
+		return err
+	}
+	logger.InfoContext(withBill(ctx, billID, ""), "Queued signal until Temporal is reachable",
+		"signal", signalName, logWorkflowID, workflowID)
+	return nil
+}
+
+// runOutbox delivers queued signals while Temporal is reachable. It runs on every instance;
+// ReplayOutbox lets only one of them deliver at a time.
+func runOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !temporalManager.Status().Connected {
+			continue
+		}
+		for {
+			done, err := ReplayOutbox(ctx, outboxBatchSize, outboxLease, deliverSignal)
+			if err != nil {
+				logger.ErrorContext(ctx, "Failed to deliver queued signals", "error", err)
+			}
+			if done > 0 {
+				logger.InfoContext(ctx, "Delivered queued signals", "signals", done)
+			}
+			if err != nil || done < outboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliverSignal sends a queued signal. The signal stays queued while Temporal is unreachable or
+// overloaded, and fails for good on any other error, such as a workflow that is gone or a payload
+// Temporal rejects, so it does not hold up the signals behind it.
+func deliverSignal(s outboxSignal) (bool, error) {
+	c := GetTemporalClient()
+	if c == nil {
+		return true, errors.New("Temporal workflow service is down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outboxSignalTimeout)
+	defer cancel()
+	// The raw payload is encoded as the JSON the workflow would have received directly
+	err := c.SignalWorkflow(ctx, s.WorkflowID, "", s.SignalName, s.Payload)
+	return err != nil && transientSignalError(err), err
+}
+
+// transientSignalError reports whether a signal that failed with err may be delivered later
+func transientSignalError(err error) bool {
+	var (
+		unavailable *serviceerror.Unavailable
+		deadline    *serviceerror.DeadlineExceeded
+		exhausted   *serviceerror.ResourceExhausted
+	)
+	return errors.As(err, &unavailable) || errors.As(err, &deadline) || errors.As(err, &exhausted) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,182 @@
+package bill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// outboxSignal is a signal waiting in the outbox
+type outboxSignal struct {
+	ID         int64
+	WorkflowID string
+	SignalName string
+	Payload    json.RawMessage
+}
+
+// EnqueueSignal stores a signal for delivery once Temporal is reachable
+func EnqueueSignal(ctx context.Context, workflowID, signalName string, payload json.RawMessage, at time.Time) error {
+	ctx, span := startDBSpan(ctx, "EnqueueSignal", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO signal_outbox (workflow_id, signal_name, payload, created_at)
+		VALUES ($1, $2, $3::jsonb, $4)
+	`, workflowID, signalName, string(payload), at)
+	if err != nil {
+		return fmt.Errorf("failed to queue %s signal for workflow %s: %w", signalName, workflowID, err)
+	}
+	return nil
+}
+
+// HasPendingSignals reports whether signals to the workflow are still waiting in the outbox
+func HasPendingSignals(ctx context.Context, workflowID string) (bool, error) {
+	ctx, span := startDBSpan(ctx, "HasPendingSignals", "")
+	defer span.End()
+
+	var pending bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM signal_outbox WHERE workflow_id = $1 AND status = 'pending')
+	`, workflowID).Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to check outbox of workflow %s: %w", workflowID, err)
+	}
+	return pending, nil
+}
+
+func CountPendingSignals(ctx context.Context) (int, error) {
+	ctx, span := startDBSpan(ctx, "CountPendingSignals", "")
+	defer span.End()
+
+	var count int
+	if err := db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM signal_outbox WHERE status = 'pending'
+	`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count outbox signals: %w", err)
+	}
+	return count, nil
+}
+
+// ReplayOutbox hands up to limit pending signals, oldest first, to deliver and records the
+// outcome of each. deliver returns retry true when the signal should stay pending, which ends the
+// batch so that later signals do not overtake it. The batch is claimed for lease in a short
+// transaction and delivered after it commits; while a claim is live no other instance takes
+// signals, so signals reach their workflows in the order they were accepted. A claim left by an
+// instance that stopped part way expires, and its signals are delivered again. It returns how
+// many signals were taken off the queue.
+func ReplayOutbox(ctx context.Context, limit int, lease time.Duration, deliver func(outboxSignal) (retry bool, err error)) (int, error) {
+	ctx, span := startDBSpan(ctx, "ReplayOutbox", "")
+	defer span.End()
+
+	signals, err := claimOutbox(ctx, limit, lease)
+	if err != nil || len(signals) == 0 {
+		return 0, err
+	}
+
+	done := 0
+	for i, s := range signals {
+		retry, deliverErr := deliver(s)
+		var err error
+		switch {
+		case retry:
+			// The rest of the batch waits behind the signal, unclaimed
+			rest := make([]int64, 0, len(signals)-i)
+			for _, r := range signals[i:] {
+				rest = append(rest, r.ID)
+			}
+			_, err = db.Exec(ctx, `
+				UPDATE signal_outbox
+				SET claimed_until = NULL,
+				    attempts = attempts + CASE WHEN id = $2 THEN 1 ELSE 0 END,
+				    last_error = CASE WHEN id = $2 THEN $3 ELSE last_error END
+				WHERE id = ANY($1)
+			`, rest, s.ID, deliverErr.Error())
+		case deliverErr != nil:
+			_, err = db.Exec(ctx, `
+				UPDATE signal_outbox SET status = 'failed', claimed_until = NULL, attempts = attempts + 1, last_error = $2 WHERE id = $1
+			`, s.ID, deliverErr.Error())
+		default:
+			_, err = db.Exec(ctx, `
+				UPDATE signal_outbox SET status = 'delivered', claimed_until = NULL, attempts = attempts + 1, delivered_at = $2 WHERE id = $1
+			`, s.ID, time.Now())
+		}
+		if err != nil {
+			return done, fmt.Errorf("failed to record delivery of outbox signal %d: %w", s.ID, err)
+		}
+		if retry {
+			break
+		}
+		done++
+	}
+	return done, nil
+}
+
+// claimOutbox claims up to limit pending signals, oldest first, for lease. It claims none while
+// another instance holds a live claim.
+func claimOutbox(ctx context.Context, limit int, lease time.Duration) ([]outboxSignal, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `
+		SELECT pg_try_advisory_xact_lock(hashtext('signal_outbox'))
+	`).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return nil, nil // Another instance is claiming
+	}
+
+	var claimed bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM signal_outbox WHERE status = 'pending' AND claimed_until > NOW())
+	`).Scan(&claimed); err != nil {
+		return nil, fmt.Errorf("failed to check outbox claims: %w", err)
+	}
+	if claimed {
+		return nil, nil // Another instance is delivering
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE signal_outbox SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM signal_outbox
+			WHERE status = 'pending'
+			ORDER BY id
+			LIMIT $1
+		)
+		RETURNING id, workflow_id, signal_name, payload
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox signals: %w", err)
+	}
+	var signals []outboxSignal
+	for rows.Next() {
+		var (
+			s       outboxSignal
+			payload []byte
+		)
+		if err := rows.Scan(&s.ID, &s.WorkflowID, &s.SignalName, &payload); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Payload = payload
+		signals = append(signals, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit outbox claim: %w", err)
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(signals, func(i, j int) bool { return signals[i].ID < signals[j].ID })
+	return signals, nil
+}
@@ -0,0 +1,34 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.temporal.io/api/serviceerror"
+)
+
+func TestTransientSignalError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unavailable", serviceerror.NewUnavailable("connection refused"), true},
+		{"deadline exceeded", serviceerror.NewDeadlineExceeded("timeout"), true},
+		{"resource exhausted", serviceerror.NewResourceExhausted(0, "busy"), true},
+		{"client timeout", fmt.Errorf("signal: %w", context.DeadlineExceeded), true},
+		{"workflow gone", serviceerror.NewNotFound("workflow not found"), false},
+		{"invalid argument", serviceerror.NewInvalidArgument("payload too large"), false},
+		{"permission denied", serviceerror.NewPermissionDenied("denied", ""), false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transientSignalError(tt.err); got != tt.want {
+				t.Errorf("transientSignalError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"fees-api/money"
+
+	"encore.dev/beta/errs"
+	"encore.dev/cron"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/workflow"
+)
+
+// reconciliationWorkflowID is shared by every run, so that two runs never overlap
+const reconciliationWorkflowID = "bill-reconciliation"
+
+// FindingKind names the two totals a reconciliation finding disagrees on
+type FindingKind string
+
+const (
+	// FindingLineItems: bills.total_amount differs from the sum of the bill's line items
+	FindingLineItems FindingKind = "line_items"
+	// FindingWorkflow: the total held by the running BillWorkflow differs from bills.total_amount
+	FindingWorkflow FindingKind = "workflow"
+)
+
+// ReconciliationRun is one pass of ReconciliationWorkflow over every bill
+type ReconciliationRun struct {
+	ID           string     `json:"id"`
+	Repair       bool       `json:"repair"`
+	BillsChecked int        `json:"bills_checked"`
+	Mismatches   int        `json:"mismatches"`
+	Repaired     int        `json:"repaired"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// ReconciliationFinding is a bill whose totals disagree. Recorded is bills.total_amount when the
+// bill was checked; Expected is the sum of its line items or the workflow total, per Kind.
+type ReconciliationFinding struct {
+	BillID   string      `json:"bill_id"`
+	Kind     FindingKind `json:"kind"`
+	Recorded money.Money `json:"recorded"`
+	Expected money.Money `json:"expected"`
+	Repaired bool        `json:"repaired"`
+	FoundAt  time.Time   `json:"found_at"`
+}
+
+var _ = cron.NewJob("reconcile-bills", cron.JobConfig{
+	Title:    "Reconcile bill totals with line items and workflows",
+	Schedule: "0 3 * * *",
+	Endpoint: StartReconciliation,
+})
+
+// StartReconciliation starts a reconciliation run unless one is already running
+//
+//encore:api private
+func StartReconciliation(ctx context.Context) error {
+	if GetTemporalClient() == nil {
+		return errs.WrapCode(nil, errs.Unavailable,
+			"reconciliation unavailable - Temporal workflow service is down")
+	}
+
+	_, err := GetTemporalClient().ExecuteWorkflow(
+		ctx,
+		client.StartWorkflowOptions{
+			ID:        reconciliationWorkflowID,
+			TaskQueue: cfg.Temporal.TaskQueue,
+		},
+		ReconciliationWorkflow,
+		&cfg.Workflow.ActivityRetry,
+	)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return nil
+	}
+	if err != nil {
+		return errs.Wrap(err, "failed to start reconciliation workflow")
+	}
+	return nil
+}
+
+type ReconciliationReportRequest struct {
+	RunID string `query:"run_id"` // defaults to the latest run
+}
+
+type ReconciliationReport struct {
+	Run      *ReconciliationRun       `json:"run"`
+	Findings []*ReconciliationFinding `json:"findings"`
+}
+
+// GetReconciliationReport returns a reconciliation run and the mismatches it found. Requires the admin role.
+//
+//encore:api auth method=GET path=/admin/reconciliation
+func GetReconciliationReport(ctx context.Context, req ReconciliationReportRequest) (*ReconciliationReport, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	run, err := GetReconciliationRun(ctx, strings.TrimSpace(req.RunID))
+	if errors.Is(err, ErrReconciliationRunNotFound) {
+		return nil, errs.WrapCode(err, errs.NotFound, "reconciliation run not found")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to get reconciliation run")
+	}
+
+	findings, err := ListReconciliationFindings(ctx, run.ID)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list reconciliation findings")
+	}
+	return &ReconciliationReport{Run: run, Findings: findings}, nil
+}
+
+// ReconciliationWorkflow checks every bill, in batches of bills ordered by ID, and records each
+// mismatch it finds. With repair enabled, stored totals are reset to the sum of their line items.
+// A workflow total that disagrees is only reported: it is refreshed by the bill's next change.
+// retry is the activity retry policy, nil for defaultActivityRetry.
+func ReconciliationWorkflow(ctx workflow.Context, retry *RetryConfig) (*ReconciliationRun, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy:         activityRetryPolicy(retry),
+	})
+
+	run := &ReconciliationRun{
+		ID:        workflow.GetInfo(ctx).WorkflowExecution.RunID,
+		StartedAt: workflow.Now(ctx),
+	}
+	var settings ReconciliationSettings
+	if err := workflow.ExecuteActivity(ctx, StartReconciliationRunActivity, *run).Get(ctx, &settings); err != nil {
+		return nil, err
+	}
+	run.Repair = settings.Repair
+
+	input := ReconcileBillsInput{RunID: run.ID, Limit: settings.BatchSize, Repair: settings.Repair}
+	for {
+		var batch ReconcileBatchResult
+		if err := workflow.ExecuteActivity(ctx, ReconcileBillsActivity, input).Get(ctx, &batch); err != nil {
+			return nil, err
+		}
+		run.BillsChecked += batch.Checked
+		run.Mismatches += batch.Mismatches
+		run.Repaired += batch.Repaired
+		if batch.Checked < input.Limit {
+			break
+		}
+		input.After = batch.LastBillID
+	}
+
+	finishedAt := workflow.Now(ctx)
+	run.FinishedAt = &finishedAt
+	if err := workflow.ExecuteActivity(ctx, FinishReconciliationRunActivity, *run).Get(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	workflow.GetLogger(ctx).Info("Reconciliation finished", "reconciliationRunID", run.ID,
+		"billsChecked", run.BillsChecked, "mismatches", run.Mismatches, "repaired", run.Repaired)
+	return run, nil
+}
+
+// ReconciliationSettings are read from the config once per run
+type ReconciliationSettings struct {
+	Repair    bool
+	BatchSize int
+}
+
+// StartReconciliationRunActivity records the start of a run and returns the settings it uses
+func StartReconciliationRunActivity(ctx context.Context, run ReconciliationRun) (ReconciliationSettings, error) {
+	settings := ReconciliationSettings{
+		Repair:    cfg.Reconciliation.Repair,
+		BatchSize: cfg.Reconciliation.BatchSize,
+	}
+	run.Repair = settings.Repair
+	return settings, CreateReconciliationRun(ctx, &run)
+}
+
+func FinishReconciliationRunActivity(ctx context.Context, run ReconciliationRun) error {
+	return FinishReconciliationRun(ctx, &run)
+}
+
+type ReconcileBillsInput struct {
+	RunID  string
+	After  string // bill ID the previous batch ended on
+	Limit  int
+	Repair bool
+}
+
+type ReconcileBatchResult struct {
+	Checked    int
+	Mismatches int
+	Repaired   int
+	LastBillID string
+}
+
+// ReconcileBillsActivity checks one batch of bills and records what it finds
+func ReconcileBillsActivity(ctx context.Context, input ReconcileBillsInput) (ReconcileBatchResult, error) {
+	bills, err := ListBillTotals(ctx, input.After, input.Limit)
+	if err != nil {
+		return ReconcileBatchResult{}, err
+	}
+
+	result := ReconcileBatchResult{Checked: len(bills)}
+	for _, b := range bills {
+		activity.RecordHeartbeat(ctx, b.BillID)
+
+		findings, err := reconcileBill(ctx, b, input.Repair, time.Now())
+		if err != nil {
+			return ReconcileBatchResult{}, err
+		}
+		for _, f := range findings {
+			if err := InsertReconciliationFinding(ctx, input.RunID, f); err != nil {
+				return ReconcileBatchResult{}, err
+			}
+			result.Mismatches++
+			if f.Repaired {
+				result.Repaired++
+			}
+		}
+		result.LastBillID = b.BillID
+	}
+	return result, nil
+}
+
+// reconcileBill compares a bill's stored total with the sum of its line items and, while its
+// workflow is running, with the workflow's total
+func reconcileBill(ctx context.Context, b billTotals, repair bool, now time.Time) ([]*ReconciliationFinding, error) {
+	var findings []*ReconciliationFinding
+
+	recorded := b.Recorded
+	if b.Recorded != b.LineItems {
+		f := &ReconciliationFinding{
+			BillID:   b.BillID,
+			Kind:     FindingLineItems,
+			Recorded: money.Money{Amount: b.Recorded, Currency: b.Currency},
+			Expected: money.Money{Amount: b.LineItems, Currency: b.Currency},
+			FoundAt:  now,
+		}
+		if repair {
+			r, err := repairBillTotal(ctx, b, now)
+			if err != nil {
+				return nil, err
+			}
+			f.Recorded.Amount, f.Expected.Amount, f.Repaired = r.Recorded, r.LineItems, r.Repaired
+			recorded = r.Total.Amount
+			if r.LedgerMismatch {
+				logger.WarnContext(ctx, "Reconciliation left a bill total unrepaired: the ledger disagrees with its line items",
+					logBillID, b.BillID, "revenue", r.Revenue, "lineItems", r.LineItems)
+			}
+		}
+		// A bill changed between the scan and the repair may already agree again
+		if f.Recorded.Amount != f.Expected.Amount {
+			findings = append(findings, f)
+		}
+	}
+
+	if !workflowRunning(b, now) {
+		return findings, nil
+	}
+	state, err := queryBillState(ctx, b.BillID)
+	if err != nil {
+		logger.WarnContext(ctx, "Reconciliation failed to query bill workflow", logBillID, b.BillID, "error", err)
+		return findings, nil
+	}
+	if state != nil && state.Total.Amount != recorded {
+		findings = append(findings, &ReconciliationFinding{
+			BillID:   b.BillID,
+			Kind:     FindingWorkflow,
+			Recorded: money.Money{Amount: recorded, Currency: b.Currency},
+			Expected: state.Total,
+			FoundAt:  now,
+		})
+	}
+	return findings, nil
+}
+
+// repairBillTotal repairs a bill's stored total through its workflow while the workflow runs, so
+// the total the workflow holds follows, and directly once it has finished. A running bill whose
+// workflow cannot take the repair, for example while Temporal is down, is left unrepaired.
+func repairBillTotal(ctx context.Context, b billTotals, now time.Time) (TotalRepair, error) {
+	if !workflowRunning(b, now) {
+		return RepairBillTotal(ctx, b.BillID)
+	}
+	unrepaired := TotalRepair{Recorded: b.Recorded, LineItems: b.LineItems,
+		Total: money.Money{Amount: b.Recorded, Currency: b.Currency}}
+	if GetTemporalClient() == nil {
+		return unrepaired, nil
+	}
+
+	handle, err := GetTemporalClient().UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   "bill-" + b.BillID,
+		UpdateName:   repairTotalUpdate,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return RepairBillTotal(ctx, b.BillID)
+	}
+	var r TotalRepair
+	if err == nil {
+		err = handle.Get(ctx, &r)
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "Reconciliation failed to repair bill through its workflow", logBillID, b.BillID, "error", err)
+		return unrepaired, nil
+	}
+	return r, nil
+}
+
+// workflowRunning reports whether a bill's workflow should still be running: open bills, and
+// closed bills that can still be reopened
+func workflowRunning(b billTotals, now time.Time) bool {
+	switch b.Status {
+	case Open:
+		return true
+	case Closed:
+		return b.ClosedAt != nil && now.Sub(*b.ClosedAt) <= reopenWindow
+	default:
+		return false
+	}
+}
+
+// queryBillState returns the state held by a bill's workflow, or nil when Temporal is unavailable
+// or the workflow no longer exists
+func queryBillState(ctx context.Context, billID string) (*BillState, error) {
+	if GetTemporalClient() == nil {
+		return nil, nil
+	}
+
+	value, err := GetTemporalClient().QueryWorkflow(ctx, "bill-"+billID, "", "state")
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state BillState
+	if err := value.Get(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,239 @@
+package bill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fees-api/ledger"
+	"fees-api/money"
+)
+
+var ErrReconciliationRunNotFound = errors.New("reconciliation run not found")
+
+// billTotals is a bill's stored total next to the sum of its line items
+type billTotals struct {
+	BillID    string
+	Currency  money.Currency
+	Status    Status
+	ClosedAt  *time.Time
+	Recorded  int64 // bills.total_amount
+	LineItems int64 // sum of line_items.amount
+}
+
+// ListBillTotals returns up to limit bills with IDs after the given one, in ID order, each with
+// the sum of its line items. The sums and totals are read from one snapshot.
+func ListBillTotals(ctx context.Context, after string, limit int) ([]billTotals, error) {
+	ctx, span := startDBSpan(ctx, "ListBillTotals", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT b.id, b.currency, b.status, b.closed_at,
+		       COALESCE(b.total_amount, 0), COALESCE(SUM(li.amount), 0)
+		FROM bills b
+		LEFT JOIN line_items li ON li.bill_id = b.id
+		WHERE b.id > $1
+		GROUP BY b.id
+		ORDER BY b.id
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bill totals after %q: %w", after, err)
+	}
+	defer rows.Close()
+
+	var totals []billTotals
+	for rows.Next() {
+		var (
+			t        billTotals
+			currency string
+			status   string
+			closedAt sql.NullTime
+		)
+		if err := rows.Scan(&t.BillID, &currency, &status, &closedAt, &t.Recorded, &t.LineItems); err != nil {
+			return nil, err
+		}
+		t.Currency = money.Currency(currency)
+		t.Status = Status(status)
+		if closedAt.Valid {
+			t.ClosedAt = &closedAt.Time
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// TotalRepair is the outcome of repairing a bill's stored total
+type TotalRepair struct {
+	Recorded  int64 // bills.total_amount before the repair
+	LineItems int64 // sum of line_items.amount
+	Revenue   int64 // revenue the ledger recognised, when checked
+	Repaired  bool
+	Total     money.Money // bills.total_amount after the repair
+	// LedgerMismatch is set when the ledger disagrees with the line items and the total was left
+	LedgerMismatch bool
+}
+
+// RepairBillTotal sets a bill's total to the sum of its line items. The bill row is locked before
+// the sum is taken, so the comparison is repeated on current data and a mismatch that has since
+// resolved itself is left alone. The total is only repaired when the ledger agrees with the line
+// items: the ledger is then already right and needs no entry. Otherwise the bill is left for review.
+func RepairBillTotal(ctx context.Context, billID string) (TotalRepair, error) {
+	ctx, span := startDBSpan(ctx, "RepairBillTotal", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return TotalRepair{}, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	total, err := getBillTotalTx(ctx, tx, billID)
+	if err != nil {
+		return TotalRepair{}, err
+	}
+	r := TotalRepair{Recorded: total.Amount, Total: total}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0) FROM line_items WHERE bill_id = $1
+	`, billID).Scan(&r.LineItems)
+	if err != nil {
+		return TotalRepair{}, fmt.Errorf("failed to sum line items of bill %s: %w", billID, err)
+	}
+	balances, err := ledgerBalances(ctx, tx, billID)
+	if err != nil {
+		return TotalRepair{}, err
+	}
+	r.Revenue = -balances[ledger.Revenue]
+	if r.Recorded == r.LineItems {
+		return r, nil
+	}
+	if r.Revenue != r.LineItems {
+		r.LedgerMismatch = true
+		return r, nil
+	}
+
+	if err := updateBillTotalTx(ctx, tx, billID, r.LineItems); err != nil {
+		return TotalRepair{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return TotalRepair{}, fmt.Errorf("failed to commit repair of bill %s: %w", billID, err)
+	}
+	r.Repaired = true
+	r.Total.Amount = r.LineItems
+	return r, nil
+}
+
+// CreateReconciliationRun records the start of a run; recording the same run twice is a no-op
+func CreateReconciliationRun(ctx context.Context, run *ReconciliationRun) error {
+	ctx, span := startDBSpan(ctx, "CreateReconciliationRun", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO reconciliation_runs (id, repair, started_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+	`, run.ID, run.Repair, run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reconciliation run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+func FinishReconciliationRun(ctx context.Context, run *ReconciliationRun) error {
+	ctx, span := startDBSpan(ctx, "FinishReconciliationRun", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		UPDATE reconciliation_runs
+		SET bills_checked = $2, mismatches = $3, repaired = $4, finished_at = $5
+		WHERE id = $1
+	`, run.ID, run.BillsChecked, run.Mismatches, run.Repaired, run.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish reconciliation run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// InsertReconciliationFinding records a mismatch. A retried batch finds the same mismatches
+// again, so a finding already recorded for the run, bill and kind is kept as it is.
+func InsertReconciliationFinding(ctx context.Context, runID string, f *ReconciliationFinding) error {
+	ctx, span := startDBSpan(ctx, "InsertReconciliationFinding", f.BillID)
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO reconciliation_findings (
+			run_id, bill_id, kind, currency, recorded_amount, expected_amount, repaired, found_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (run_id, bill_id, kind) DO NOTHING
+	`, runID, f.BillID, f.Kind, f.Recorded.Currency, f.Recorded.Amount, f.Expected.Amount, f.Repaired, f.FoundAt)
+	if err != nil {
+		return fmt.Errorf("failed to record %s finding for bill %s: %w", f.Kind, f.BillID, err)
+	}
+	return nil
+}
+
+// GetReconciliationRun returns the run with the given ID, or the most recently started run
+// when runID is empty
+func GetReconciliationRun(ctx context.Context, runID string) (*ReconciliationRun, error) {
+	ctx, span := startDBSpan(ctx, "GetReconciliationRun", "")
+	defer span.End()
+
+	row := db.QueryRow(ctx, `
+		SELECT id, repair, bills_checked, mismatches, repaired, started_at, finished_at
+		FROM reconciliation_runs
+		WHERE $1 = '' OR id = $1
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, runID)
+
+	var (
+		run        ReconciliationRun
+		finishedAt sql.NullTime
+	)
+	err := row.Scan(&run.ID, &run.Repair, &run.BillsChecked, &run.Mismatches, &run.Repaired, &run.StartedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReconciliationRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reconciliation run %q: %w", runID, err)
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+func ListReconciliationFindings(ctx context.Context, runID string) ([]*ReconciliationFinding, error) {
+	ctx, span := startDBSpan(ctx, "ListReconciliationFindings", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT bill_id, kind, currency, recorded_amount, expected_amount, repaired, found_at
+		FROM reconciliation_findings
+		WHERE run_id = $1
+		ORDER BY id
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list findings of reconciliation run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var findings []*ReconciliationFinding
+	for rows.Next() {
+		var (
+			f        ReconciliationFinding
+			currency string
+		)
+		if err := rows.Scan(&f.BillID, &f.Kind, &currency, &f.Recorded.Amount, &f.Expected.Amount, &f.Repaired, &f.FoundAt); err != nil {
+			return nil, err
+		}
+		f.Recorded.Currency = money.Currency(currency)
+		f.Expected.Currency = money.Currency(currency)
+		findings = append(findings, &f)
+	}
+	return findings, rows.Err()
+}
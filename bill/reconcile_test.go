@@ -0,0 +1,84 @@
+package bill
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestWorkflowRunning(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	recently := now.Add(-24 * time.Hour)
+	longAgo := now.Add(-reopenWindow - time.Hour)
+
+	tests := []struct {
+		name string
+		bill billTotals
+		want bool
+	}{
+		{"open", billTotals{Status: Open}, true},
+		{"closed within the reopen window", billTotals{Status: Closed, ClosedAt: &recently}, true},
+		{"closed before the reopen window", billTotals{Status: Closed, ClosedAt: &longAgo}, false},
+		{"closed without a close time", billTotals{Status: Closed}, false},
+		{"void", billTotals{Status: Void}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workflowRunning(tt.bill, now); got != tt.want {
+				t.Errorf("workflowRunning() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconciliationWorkflow(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(ReconciliationWorkflow)
+
+	// Three batches of at most two bills; the last one is short
+	batches := map[string]ReconcileBatchResult{
+		"":   {Checked: 2, Mismatches: 1, Repaired: 1, LastBillID: "b2"},
+		"b2": {Checked: 2, LastBillID: "b4"},
+		"b4": {Checked: 1, Mismatches: 1, LastBillID: "b5"},
+	}
+	var cursors []string
+	env.OnActivity(StartReconciliationRunActivity, mock.Anything, mock.Anything).
+		Return(ReconciliationSettings{Repair: true, BatchSize: 2}, nil)
+	env.OnActivity(ReconcileBillsActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, input ReconcileBillsInput) (ReconcileBatchResult, error) {
+			if input.Limit != 2 || !input.Repair {
+				t.Errorf("batch input = %+v, want limit 2 with repair", input)
+			}
+			cursors = append(cursors, input.After)
+			return batches[input.After], nil
+		})
+	var finished ReconciliationRun
+	env.OnActivity(FinishReconciliationRunActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, run ReconciliationRun) error {
+			finished = run
+			return nil
+		})
+
+	env.ExecuteWorkflow(ReconciliationWorkflow, (*RetryConfig)(nil))
+
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow error = %v", err)
+	}
+	if len(cursors) != 3 || cursors[0] != "" || cursors[1] != "b2" || cursors[2] != "b4" {
+		t.Errorf("batches started after %q, want \"\", b2, b4", cursors)
+	}
+	if finished.BillsChecked != 5 || finished.Mismatches != 2 || finished.Repaired != 1 || !finished.Repair {
+		t.Errorf("finished run = %+v, want 5 checked, 2 mismatches, 1 repaired", finished)
+	}
+	if finished.FinishedAt == nil {
+		t.Error("finished run has no finish time")
+	}
+}
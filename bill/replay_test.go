@@ -0,0 +1,39 @@
+package bill
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.temporal.io/sdk/worker"
+)
+
+// TestBillWorkflowReplay replays recorded workflow histories against the current BillWorkflow.
+// A failure means the change is not deterministic: workflows already running in production
+// would fail to replay after the deploy. bill_baseline_closed.json was recorded with a worker of
+// the first release, and bill_baseline_upgraded.json was started by one and finished by the
+// current worker; the others were recorded with the current worker. Histories are recorded from
+// real runs and never edited, so a failing replay is fixed with workflow.GetVersion. Record new
+// histories with
+//
+//	temporal workflow show --workflow-id bill-<id> --output json > bill/testdata/histories/<name>.json
+//
+// Like every test of this package it needs the Encore runtime, so run it with encore test.
+func TestBillWorkflowReplay(t *testing.T) {
+	files, err := filepath.Glob("testdata/histories/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no recorded histories in testdata/histories")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(BillWorkflow)
+			if err := replayer.ReplayWorkflowHistoryFromJSONFile(nil, file); err != nil {
+				t.Errorf("replay failed: %v", err)
+			}
+		})
+	}
+}
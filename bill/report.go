@@ -0,0 +1,275 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"fees-api/money"
+
+	"encore.dev/beta/errs"
+)
+
+// reportDateLayout is the format of report date filters and day buckets
+const reportDateLayout = "2006-01-02"
+
+// maxReportRange bounds the date range of a single report
+const maxReportRange = 5 * 366 * 24 * time.Hour
+
+type ReportGroup string
+
+const (
+	GroupByCurrency ReportGroup = "currency"
+	GroupByStatus   ReportGroup = "status"
+	GroupByAccount  ReportGroup = "account"
+	GroupByDay      ReportGroup = "day"
+	GroupByWeek     ReportGroup = "week"
+	GroupByMonth    ReportGroup = "month"
+)
+
+// ReportDateBasis selects which bill timestamp the date range and time buckets apply to
+type ReportDateBasis string
+
+const (
+	ByCreatedAt ReportDateBasis = "created"
+	ByClosedAt  ReportDateBasis = "closed"
+)
+
+type BillReportRequest struct {
+	// GroupBy is currency, status, account, day, week or month
+	GroupBy string `query:"group_by"`
+	// From and To are inclusive dates (YYYY-MM-DD); both are optional
+	From string `query:"from"`
+	To   string `query:"to"`
+	// DateBasis is created (default) or closed; closed excludes bills that were never closed
+	DateBasis string `query:"date_basis"`
+
+	Status    string `query:"status"`
+	Currency  string `query:"currency"`
+	AccountID string `query:"account_id"`
+
+	// ReportingCurrency converts every bill into one currency with the FX rate effective on the bill date
+	ReportingCurrency string `query:"reporting_currency"`
+}
+
+type BillReportRow struct {
+	// Group is the value of the grouping dimension, e.g. USD, CLOSED, acct_123, 2026-05-01 or 2026-05.
+	// Week buckets are named by the Monday they start on.
+	Group         string      `json:"group"`
+	BillCount     int64       `json:"bill_count"`
+	LineItemCount int64       `json:"line_item_count"`
+	Total         money.Money `json:"total"`
+	AverageBill   money.Money `json:"average_bill"`
+}
+
+type BillReportResponse struct {
+	GroupBy           ReportGroup      `json:"group_by"`
+	DateBasis         ReportDateBasis  `json:"date_basis"`
+	ReportingCurrency money.Currency   `json:"reporting_currency,omitempty"`
+	Rows              []*BillReportRow `json:"rows"`
+}
+
+// billReportQuery is a validated report request
+type billReportQuery struct {
+	GroupBy           ReportGroup
+	DateBasis         ReportDateBasis
+	From, To          *time.Time // To is exclusive
+	Status            Status
+	Currency          money.Currency
+	AccountID         string
+	ReportingCurrency money.Currency
+}
+
+// BillReport aggregates bill totals, counts and average bill size. Without a reporting currency
+// every group is split further by bill currency. Requires the admin role.
+//
+//encore:api auth method=GET path=/reports/bills
+func BillReport(ctx context.Context, req BillReportRequest) (*BillReportResponse, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	q, err := parseBillReportRequest(req)
+	if err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	rows, err := AggregateBills(ctx, q)
+	if errors.Is(err, ErrMissingFXRate) {
+		return nil, errs.WrapCode(err, errs.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to aggregate bills")
+	}
+
+	return &BillReportResponse{
+		GroupBy:           q.GroupBy,
+		DateBasis:         q.DateBasis,
+		ReportingCurrency: q.ReportingCurrency,
+		Rows:              rows,
+	}, nil
+}
+
+// parseBillReportRequest validates a report request and applies its defaults
+func parseBillReportRequest(req BillReportRequest) (billReportQuery, error) {
+	q := billReportQuery{
+		GroupBy:           ReportGroup(strings.TrimSpace(req.GroupBy)),
+		DateBasis:         ReportDateBasis(strings.TrimSpace(req.DateBasis)),
+		Status:            Status(strings.TrimSpace(req.Status)),
+		Currency:          money.Currency(strings.TrimSpace(req.Currency)),
+		AccountID:         strings.TrimSpace(req.AccountID),
+		ReportingCurrency: money.Currency(strings.TrimSpace(req.ReportingCurrency)),
+	}
+
+	switch q.GroupBy {
+	case GroupByCurrency, GroupByStatus, GroupByAccount, GroupByDay, GroupByWeek, GroupByMonth:
+	default:
+		return billReportQuery{}, errors.New("group_by must be currency, status, account, day, week or month")
+	}
+	switch q.DateBasis {
+	case "":
+		q.DateBasis = ByCreatedAt
+	case ByCreatedAt, ByClosedAt:
+	default:
+		return billReportQuery{}, errors.New("date_basis must be created or closed")
+	}
+
+	if q.Status != "" && !q.Status.IsValid() {
+		return billReportQuery{}, errors.New("status must be OPEN, CLOSED or VOID")
+	}
+	if q.Currency != "" && !q.Currency.IsValid() {
+		return billReportQuery{}, fmt.Errorf("invalid currency: %s", q.Currency)
+	}
+	if q.ReportingCurrency != "" && !q.ReportingCurrency.IsValid() {
+		return billReportQuery{}, fmt.Errorf("invalid reporting_currency: %s", q.ReportingCurrency)
+	}
+	if len(q.AccountID) > maxAccountIDLength {
+		return billReportQuery{}, errors.New("account_id max 100 chars")
+	}
+
+	from, to, err := parseDateRange(req.From, req.To)
+	if err != nil {
+		return billReportQuery{}, err
+	}
+	q.From, q.To = from, to
+	return q, nil
+}
+
+// parseDateRange parses optional inclusive from and to dates into a half-open time range
+func parseDateRange(fromDate, toDate string) (from, to *time.Time, err error) {
+	if fromDate != "" {
+		t, err := time.Parse(reportDateLayout, fromDate)
+		if err != nil {
+			return nil, nil, errors.New("from must be a date (YYYY-MM-DD)")
+		}
+		from = &t
+	}
+	if toDate != "" {
+		t, err := time.Parse(reportDateLayout, toDate)
+		if err != nil {
+			return nil, nil, errors.New("to must be a date (YYYY-MM-DD)")
+		}
+		// The range includes the whole of the to date
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	if from != nil && to != nil {
+		if !from.Before(*to) {
+			return nil, nil, errors.New("from must not be after to")
+		}
+		if to.Sub(*from) > maxReportRange {
+			return nil, nil, errors.New("date range can span at most 5 years")
+		}
+	}
+	return from, to, nil
+}
+
+// averageBill divides total by count, rounding half away from zero
+func averageBill(total money.Money, count int64) money.Money {
+	if count <= 0 {
+		return money.Money{Currency: total.Currency}
+	}
+	average, remainder := total.Amount/count, total.Amount%count
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if remainder >= count-remainder {
+		if total.Amount < 0 {
+			average--
+		} else {
+			average++
+		}
+	}
+	return money.Money{Amount: average, Currency: total.Currency}
+}
+
+// FXRate converts one unit of From into Rate units of To from EffectiveDate onwards
+type FXRate struct {
+	From          money.Currency `json:"from"`
+	To            money.Currency `json:"to"`
+	Rate          string         `json:"rate"`
+	EffectiveDate string         `json:"effective_date"`
+}
+
+type ListFXRatesResponse struct {
+	Rates []*FXRate `json:"rates"`
+}
+
+// fxRatePattern accepts positive decimal rates with up to 10 fractional digits
+var fxRatePattern = regexp.MustCompile(`^\d{1,10}(\.\d{1,10})?$`)
+
+// validate checks that the rate converts between two different currencies at a positive rate
+func (r *FXRate) validate() error {
+	if !r.From.IsValid() || !r.To.IsValid() {
+		return errors.New("from and to must be supported currencies")
+	}
+	if r.From == r.To {
+		return errors.New("from and to must differ")
+	}
+	if !fxRatePattern.MatchString(r.Rate) || strings.Trim(r.Rate, "0.") == "" {
+		return errors.New("rate must be a positive decimal with up to 10 fractional digits")
+	}
+	if _, err := time.Parse(reportDateLayout, r.EffectiveDate); err != nil {
+		return errors.New("effective_date must be a date (YYYY-MM-DD)")
+	}
+	return nil
+}
+
+// PutFXRateAPI records the rate between two currencies from a date onwards, replacing any rate
+// already recorded for that date. Requires the admin role.
+//
+//encore:api auth method=PUT path=/reports/fx-rates
+func PutFXRateAPI(ctx context.Context, req FXRate) (*FXRate, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	req.Rate = strings.TrimSpace(req.Rate)
+	req.EffectiveDate = strings.TrimSpace(req.EffectiveDate)
+	if err := req.validate(); err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+
+	if err := UpsertFXRate(ctx, &req); err != nil {
+		return nil, errs.Wrap(err, "failed to save fx rate")
+	}
+	return &req, nil
+}
+
+// ListFXRatesAPI lists all recorded FX rates. Requires the admin role.
+//
+//encore:api auth method=GET path=/reports/fx-rates
+func ListFXRatesAPI(ctx context.Context) (*ListFXRatesResponse, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	rates, err := ListFXRates(ctx)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list fx rates")
+	}
+	return &ListFXRatesResponse{Rates: rates}, nil
+}
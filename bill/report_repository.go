@@ -0,0 +1,161 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fees-api/money"
+)
+
+var ErrMissingFXRate = errors.New("missing fx rate")
+
+// reportGroupExpressions maps each grouping to the SQL expression it groups on. {date} is
+// replaced by the bill timestamp selected by the date basis.
+var reportGroupExpressions = map[ReportGroup]string{
+	GroupByCurrency: "b.currency",
+	GroupByStatus:   "b.status",
+	GroupByAccount:  "COALESCE(b.account_id, '')",
+	GroupByDay:      "to_char(date_trunc('day', {date}), 'YYYY-MM-DD')",
+	GroupByWeek:     "to_char(date_trunc('week', {date}), 'YYYY-MM-DD')",
+	GroupByMonth:    "to_char(date_trunc('month', {date}), 'YYYY-MM')",
+}
+
+var reportDateColumns = map[ReportDateBasis]string{
+	ByCreatedAt: "b.created_at",
+	ByClosedAt:  "b.closed_at",
+}
+
+// AggregateBills runs a validated report query. With a reporting currency each bill total is
+// converted at the latest rate effective on its report date, and ErrMissingFXRate is returned if
+// any bill in range has no such rate.
+func AggregateBills(ctx context.Context, q billReportQuery) ([]*BillReportRow, error) {
+	ctx, span := startDBSpan(ctx, "AggregateBills", "")
+	defer span.End()
+
+	dateColumn := reportDateColumns[q.DateBasis]
+	group := strings.ReplaceAll(reportGroupExpressions[q.GroupBy], "{date}", dateColumn)
+
+	// Only whitelisted expressions are interpolated; every value is a parameter
+	query := `
+		SELECT ` + group + ` AS report_group,
+		       CASE WHEN $6 = '' THEN b.currency ELSE $6 END AS report_currency,
+		       COUNT(*),
+		       COALESCE(SUM(items.count), 0),
+		       COALESCE(SUM(CASE
+		           WHEN $6 = '' OR b.currency = $6 THEN b.total_amount
+		           ELSE ROUND(b.total_amount * fx.rate)::BIGINT
+		       END), 0),
+		       COUNT(*) FILTER (WHERE $6 <> '' AND b.currency <> $6 AND fx.rate IS NULL)
+		FROM bills b
+		LEFT JOIN LATERAL (
+		    SELECT COUNT(*) AS count FROM line_items li WHERE li.bill_id = b.id
+		) items ON TRUE
+		LEFT JOIN LATERAL (
+		    SELECT r.rate FROM fx_rates r
+		    WHERE $6 <> '' AND r.from_currency = b.currency AND r.to_currency = $6
+		      AND r.effective_date <= ` + dateColumn + `::date
+		    ORDER BY r.effective_date DESC
+		    LIMIT 1
+		) fx ON TRUE
+		WHERE ` + dateColumn + ` IS NOT NULL
+		  AND ($1::timestamp IS NULL OR ` + dateColumn + ` >= $1)
+		  AND ($2::timestamp IS NULL OR ` + dateColumn + ` < $2)
+		  AND ($3 = '' OR b.status = $3)
+		  AND ($4 = '' OR b.currency = $4)
+		  AND ($5 = '' OR b.account_id = $5)
+		GROUP BY report_group, report_currency
+		ORDER BY report_group, report_currency
+	`
+
+	rows, err := db.Query(ctx, query,
+		q.From, q.To, q.Status, q.Currency, q.AccountID, q.ReportingCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate bills by %s: %w", q.GroupBy, err)
+	}
+	defer rows.Close()
+
+	var (
+		result  []*BillReportRow
+		missing int64
+	)
+	for rows.Next() {
+		var (
+			row         BillReportRow
+			currencyStr string
+			total       int64
+			unconverted int64
+		)
+		if err := rows.Scan(&row.Group, &currencyStr, &row.BillCount, &row.LineItemCount, &total, &unconverted); err != nil {
+			return nil, err
+		}
+		missing += unconverted
+
+		row.Total = money.Money{Amount: total, Currency: money.Currency(currencyStr)}
+		row.AverageBill = averageBill(row.Total, row.BillCount)
+		result = append(result, &row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if missing > 0 {
+		return nil, fmt.Errorf("%d bills have no rate into %s on their date: %w", missing, q.ReportingCurrency, ErrMissingFXRate)
+	}
+	return result, nil
+}
+
+// UpsertFXRate records a rate, replacing the one for the same currencies and date
+func UpsertFXRate(ctx context.Context, r *FXRate) error {
+	ctx, span := startDBSpan(ctx, "UpsertFXRate", "")
+	defer span.End()
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO fx_rates (from_currency, to_currency, effective_date, rate)
+		VALUES ($1, $2, $3::date, $4::numeric)
+		ON CONFLICT (from_currency, to_currency, effective_date) DO UPDATE
+		SET rate = EXCLUDED.rate
+	`, r.From, r.To, r.EffectiveDate, r.Rate)
+	if err != nil {
+		return fmt.Errorf("failed to upsert fx rate %s/%s on %s: %w", r.From, r.To, r.EffectiveDate, err)
+	}
+	return nil
+}
+
+func ListFXRates(ctx context.Context) ([]*FXRate, error) {
+	ctx, span := startDBSpan(ctx, "ListFXRates", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT from_currency, to_currency, rate::text, effective_date
+		FROM fx_rates
+		ORDER BY from_currency, to_currency, effective_date DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []*FXRate
+	for rows.Next() {
+		var (
+			r             FXRate
+			from, to      string
+			effectiveDate time.Time
+		)
+		if err := rows.Scan(&from, &to, &r.Rate, &effectiveDate); err != nil {
+			return nil, err
+		}
+		if strings.Contains(r.Rate, ".") {
+			// NUMERIC(20, 10) pads the fraction with zeros
+			r.Rate = strings.TrimSuffix(strings.TrimRight(r.Rate, "0"), ".")
+		}
+		r.From = money.Currency(from)
+		r.To = money.Currency(to)
+		r.EffectiveDate = effectiveDate.Format(reportDateLayout)
+		rates = append(rates, &r)
+	}
+	return rates, rows.Err()
+}
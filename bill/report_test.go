@@ -0,0 +1,102 @@
+package bill
+
+import (
+	"testing"
+	"time"
+
+	"fees-api/money"
+)
+
+func TestParseBillReportRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     BillReportRequest
+		wantErr bool
+	}{
+		{"group by month", BillReportRequest{GroupBy: "month"}, false},
+		{"full filter set", BillReportRequest{GroupBy: "day", From: "2026-05-01", To: "2026-05-31", DateBasis: "closed", Status: "CLOSED", Currency: "GEL", AccountID: "acct_123", ReportingCurrency: "USD"}, false},
+		{"single day", BillReportRequest{GroupBy: "account", From: "2026-05-01", To: "2026-05-01"}, false},
+		{"missing group", BillReportRequest{}, true},
+		{"unknown group", BillReportRequest{GroupBy: "year"}, true},
+		{"unknown date basis", BillReportRequest{GroupBy: "day", DateBasis: "voided"}, true},
+		{"bad from", BillReportRequest{GroupBy: "day", From: "05/01/2026"}, true},
+		{"from after to", BillReportRequest{GroupBy: "day", From: "2026-06-01", To: "2026-05-01"}, true},
+		{"range too long", BillReportRequest{GroupBy: "month", From: "2016-01-01", To: "2026-01-01"}, true},
+		{"invalid status", BillReportRequest{GroupBy: "status", Status: "PAID"}, true},
+		{"invalid currency", BillReportRequest{GroupBy: "currency", Currency: "EUR"}, true},
+		{"invalid reporting currency", BillReportRequest{GroupBy: "currency", ReportingCurrency: "EUR"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBillReportRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseBillReportRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseBillReportRequestDefaults(t *testing.T) {
+	q, err := parseBillReportRequest(BillReportRequest{GroupBy: "week", From: "2026-05-01", To: "2026-05-31"})
+	if err != nil {
+		t.Fatalf("parseBillReportRequest() error = %v", err)
+	}
+	if q.DateBasis != ByCreatedAt {
+		t.Errorf("DateBasis = %q, want %q", q.DateBasis, ByCreatedAt)
+	}
+	if want := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC); q.To == nil || !q.To.Equal(want) {
+		t.Errorf("To = %v, want the exclusive end %v", q.To, want)
+	}
+}
+
+func TestAverageBill(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int64
+		count int64
+		want  int64
+	}{
+		{"exact", 3000, 3, 1000},
+		{"rounds down", 1000, 3, 333},
+		{"rounds half up", 5, 2, 3},
+		{"rounds up", 2000, 3, 667},
+		{"negative rounds away from zero", -5, 2, -3},
+		{"no bills", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := averageBill(money.Money{Amount: tt.total, Currency: money.USD}, tt.count)
+			if got.Amount != tt.want || got.Currency != money.USD {
+				t.Errorf("averageBill() = %v, want %d USD", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFXRateValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    FXRate
+		wantErr bool
+	}{
+		{"valid", FXRate{From: money.GEL, To: money.USD, Rate: "0.3690", EffectiveDate: "2026-05-01"}, false},
+		{"integer rate", FXRate{From: money.USD, To: money.GEL, Rate: "3", EffectiveDate: "2026-05-01"}, false},
+		{"same currency", FXRate{From: money.USD, To: money.USD, Rate: "1", EffectiveDate: "2026-05-01"}, true},
+		{"unsupported currency", FXRate{From: "EUR", To: money.USD, Rate: "1.1", EffectiveDate: "2026-05-01"}, true},
+		{"zero rate", FXRate{From: money.GEL, To: money.USD, Rate: "0.000", EffectiveDate: "2026-05-01"}, true},
+		{"negative rate", FXRate{From: money.GEL, To: money.USD, Rate: "-0.37", EffectiveDate: "2026-05-01"}, true},
+		{"exponent rate", FXRate{From: money.GEL, To: money.USD, Rate: "3.7e-1", EffectiveDate: "2026-05-01"}, true},
+		{"bad date", FXRate{From: money.GEL, To: money.USD, Rate: "0.37", EffectiveDate: "May 1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rate.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FXRate.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
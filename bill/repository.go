@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"fees-api/ledger"
 	"fees-api/money"
 
 	"encore.dev/storage/sqldb"
@@ -19,19 +20,23 @@ var db = sqldb.NewDatabase("fees", sqldb.DatabaseConfig{
 // scanBill reconstructs a Bill domain object from database row data
 func scanBill(row interface{ Scan(...interface{}) error }) (*Bill, error) {
 	var (
-		b           Bill
-		currencyStr string
-		totalAmount int64
-		closedAt    sql.NullTime
+		b             Bill
+		currencyStr   string
+		totalAmount   int64
+		closedAt      sql.NullTime
+		invoiceNumber sql.NullString
+		accountID     sql.NullString
 	)
 
 	err := row.Scan(
 		&b.ID,
+		&accountID,
 		&currencyStr,
 		&b.Status,
 		&totalAmount,
 		&b.CreatedAt,
 		&closedAt,
+		&invoiceNumber,
 	)
 	if err != nil {
 		return nil, err
@@ -47,32 +52,50 @@ func scanBill(row interface{ Scan(...interface{}) error }) (*Bill, error) {
 	if closedAt.Valid {
 		b.ClosedAt = &closedAt.Time
 	}
+	b.InvoiceNumber = invoiceNumber.String
+	b.AccountID = accountID.String
 
 	return &b, nil
 }
 
-func CreateBill(ctx context.Context, bill *Bill) error {
+// InsertBillForWorkflow inserts the bill owned by a workflow and returns the stored row. A bill
+// already inserted for the workflow is returned as it is, so the insert can be retried safely.
+func InsertBillForWorkflow(ctx context.Context, bill *Bill, workflowID string) (*Bill, error) {
+	ctx, span := startDBSpan(ctx, "InsertBillForWorkflow", bill.ID)
+	defer span.End()
+
 	_, err := db.Exec(ctx, `
-        INSERT INTO bills (id, currency, status, total_amount, created_at)
-        VALUES ($1, $2, $3, $4, $5)
-    `, bill.ID, bill.Total.Currency, bill.Status, bill.Total.Amount, bill.CreatedAt)
+		INSERT INTO bills (id, account_id, currency, status, total_amount, created_at, workflow_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7)
+		ON CONFLICT (workflow_id) DO NOTHING
+	`, bill.ID, bill.AccountID, bill.Total.Currency, bill.Status, bill.Total.Amount, bill.CreatedAt, workflowID)
 	if err != nil {
-		return fmt.Errorf("failed to create bill %s: %w", bill.ID, err)
+		return nil, fmt.Errorf("failed to create bill %s for workflow %s: %w", bill.ID, workflowID, err)
 	}
-	return nil
+	return GetBill(ctx, bill.ID)
 }
 
 var ErrBillNotFound = errors.New("bill not found")
 
+var ErrLineItemNotFound = errors.New("line item not found")
+
+// ErrLineItemConflict is returned when an item ID is already used by a line item of another bill
+var ErrLineItemConflict = errors.New("line item ID belongs to another bill")
+
 func GetBill(ctx context.Context, billID string) (*Bill, error) {
+	ctx, span := startDBSpan(ctx, "GetBill", billID)
+	defer span.End()
+
 	row := db.QueryRow(ctx, `
         SELECT
             id,
+            account_id,
             currency,
             status,
             total_amount,
             created_at,
-            closed_at
+            closed_at,
+            invoice_number
         FROM bills
         WHERE id = $1
     `, billID)
@@ -88,7 +111,40 @@ func GetBill(ctx context.Context, billID string) (*Bill, error) {
 	return bill, nil
 }
 
+// GetBillByInvoiceNumber looks up a bill by its assigned invoice number
+func GetBillByInvoiceNumber(ctx context.Context, invoiceNumber string) (*Bill, error) {
+	ctx, span := startDBSpan(ctx, "GetBillByInvoiceNumber", "")
+	defer span.End()
+
+	row := db.QueryRow(ctx, `
+        SELECT
+            id,
+            account_id,
+            currency,
+            status,
+            total_amount,
+            created_at,
+            closed_at,
+            invoice_number
+        FROM bills
+        WHERE invoice_number = $1
+    `, invoiceNumber)
+
+	bill, err := scanBill(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("bill not found for invoice number %s: %w", invoiceNumber, ErrBillNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan bill for invoice number %s: %w", invoiceNumber, err)
+	}
+
+	return bill, nil
+}
+
 func UpdateBill(ctx context.Context, b *Bill) error {
+	ctx, span := startDBSpan(ctx, "UpdateBill", b.ID)
+	defer span.End()
+
 	_, err := db.Exec(ctx, `
         UPDATE bills SET status=$1, total_amount=$2, closed_at=$3 WHERE id=$4
     `, b.Status, b.Total.Amount, b.ClosedAt, b.ID)
@@ -100,6 +156,9 @@ func UpdateBill(ctx context.Context, b *Bill) error {
 
 // UpdateBillTransactional updates bill status, total, and closed_at in a transaction
 func UpdateBillTransactional(ctx context.Context, billID string, total money.Money, status Status, closedAt *time.Time) error {
+	ctx, span := startDBSpan(ctx, "UpdateBillTransactional", billID)
+	defer span.End()
+
 	_, err := db.Exec(ctx, `
         UPDATE bills SET status=$1, total_amount=$2, closed_at=$3 WHERE id=$4
     `, status, total.Amount, closedAt, billID)
@@ -111,6 +170,9 @@ func UpdateBillTransactional(ctx context.Context, billID string, total money.Mon
 
 // UpdateBillStatusOnly updates only bill status and closed_at (preserves existing total)
 func UpdateBillStatusOnly(ctx context.Context, billID string, status Status, closedAt *time.Time) error {
+	ctx, span := startDBSpan(ctx, "UpdateBillStatusOnly", billID)
+	defer span.End()
+
 	_, err := db.Exec(ctx, `
         UPDATE bills SET status=$1, closed_at=$2 WHERE id=$3
     `, status, closedAt, billID)
@@ -120,9 +182,161 @@ func UpdateBillStatusOnly(ctx context.Context, billID string, status Status, clo
 	return nil
 }
 
+// TransitionBillStatus moves a bill to a new status and records the change in bill_status_history.
+// The transition is validated against the current status inside the transaction.
+// This function is idempotent - a bill already in the target status is left untouched.
+func TransitionBillStatus(ctx context.Context, change StatusChange) error {
+	ctx, span := startDBSpan(ctx, "TransitionBillStatus", change.BillID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for bill %s: %w", change.BillID, err)
+	}
+	defer tx.Rollback()
+
+	if err := transitionBillStatusTx(ctx, tx, change); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FinalizeBill closes a bill and assigns its invoice number in a single transaction.
+// The number is drawn from the issuer's sequence for the year the bill closed, so a rolled back
+// transaction also rolls back the sequence and numbers stay gap-free.
+// This function is idempotent - a bill keeps the invoice number it was first given,
+// including when it is reopened and closed again.
+// Tax at taxBasisPoints of the bill's revenue is posted to the ledger as the bill closes.
+func FinalizeBill(ctx context.Context, billID string, closedAt time.Time, issuer, numberFormat string, taxBasisPoints int64) error {
+	ctx, span := startDBSpan(ctx, "FinalizeBill", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	if err := transitionBillStatusTx(ctx, tx, StatusChange{
+		BillID: billID,
+		To:     Closed,
+		At:     closedAt,
+	}); err != nil {
+		return err
+	}
+
+	if err := assignInvoiceNumberTx(ctx, tx, billID, closedAt.UTC().Year(), issuer, numberFormat); err != nil {
+		return err
+	}
+
+	currentTotal, err := getBillTotalTx(ctx, tx, billID)
+	if err != nil {
+		return err
+	}
+	if err := settleTaxTx(ctx, tx, billID, currentTotal.Currency, taxBasisPoints, closedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// transitionBillStatusTx validates and applies a status change within a transaction,
+// locking the bill row until the transaction ends
+func transitionBillStatusTx(ctx context.Context, tx *sqldb.Tx, change StatusChange) error {
+	var (
+		current       Status
+		currency      string
+		invoiceNumber sql.NullString
+	)
+	err := tx.QueryRow(ctx, `
+		SELECT status, currency, invoice_number FROM bills WHERE id = $1 FOR UPDATE
+	`, change.BillID).Scan(&current, &currency, &invoiceNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("bill not found for id %s: %w", change.BillID, ErrBillNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get bill status for id %s: %w", change.BillID, err)
+	}
+
+	if current == change.To {
+		return nil // Idempotent - already in the target status
+	}
+	if err := validateTransition(current, change.To); err != nil {
+		return err
+	}
+	if change.To == Void && invoiceNumber.Valid {
+		return fmt.Errorf("%w: bill %s holds invoice %s", ErrBillInvoiced, change.BillID, invoiceNumber.String)
+	}
+
+	switch change.To {
+	case Closed:
+		_, err = tx.Exec(ctx, `
+			UPDATE bills SET status = $1, closed_at = $2 WHERE id = $3
+		`, change.To, change.At, change.BillID)
+	case Open:
+		_, err = tx.Exec(ctx, `
+			UPDATE bills SET status = $1, closed_at = NULL WHERE id = $2
+		`, change.To, change.BillID)
+	default:
+		_, err = tx.Exec(ctx, `
+			UPDATE bills SET status = $1 WHERE id = $2
+		`, change.To, change.BillID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update bill %s status: %w", change.BillID, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO bill_status_history (bill_id, from_status, to_status, reason, actor, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, change.BillID, current, change.To, change.Reason, change.Actor, change.At)
+	if err != nil {
+		return fmt.Errorf("failed to record status change for bill %s: %w", change.BillID, err)
+	}
+
+	return reverseStatusEntriesTx(ctx, tx, change, money.Currency(currency))
+}
+
+// assignInvoiceNumberTx gives a bill the next number in its issuer's yearly sequence
+// unless it already has one
+func assignInvoiceNumberTx(ctx context.Context, tx *sqldb.Tx, billID string, year int, issuer, numberFormat string) error {
+	var existing sql.NullString
+	if err := tx.QueryRow(ctx, `
+		SELECT invoice_number FROM bills WHERE id = $1
+	`, billID).Scan(&existing); err != nil {
+		return fmt.Errorf("failed to get invoice number for bill %s: %w", billID, err)
+	}
+	if existing.Valid {
+		return nil // Idempotent - number already assigned
+	}
+
+	// The upsert locks the sequence row until commit, serializing concurrent closes
+	var seq int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO invoice_sequences (issuer, year, last_value)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (issuer, year) DO UPDATE SET last_value = invoice_sequences.last_value + 1
+		RETURNING last_value
+	`, issuer, year).Scan(&seq); err != nil {
+		return fmt.Errorf("failed to allocate invoice number for bill %s: %w", billID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE bills SET invoice_number = $1 WHERE id = $2
+	`, formatInvoiceNumber(numberFormat, year, seq), billID); err != nil {
+		return fmt.Errorf("failed to assign invoice number for bill %s: %w", billID, err)
+	}
+
+	return nil
+}
+
 // InsertLineItemAndUpdateTotal inserts a line item and updates the bill total atomically in a single transaction.
 // This function is idempotent - it can be called multiple times safely.
 func InsertLineItemAndUpdateTotal(ctx context.Context, billID string, item *LineItem) error {
+	ctx, span := startDBSpan(ctx, "InsertLineItemAndUpdateTotal", billID)
+	defer span.End()
+
 	return insertLineItemAndUpdateTotalTx(ctx, billID, item)
 }
 
@@ -142,6 +356,9 @@ func insertLineItemAndUpdateTotalTx(ctx context.Context, billID string, item *Li
 	if existing != nil {
 		return nil // Idempotent - item already exists, no changes needed
 	}
+	if err := checkLineItemIDsTx(ctx, tx, billID, []string{item.ID}); err != nil {
+		return err
+	}
 
 	// Get current bill total within transaction
 	currentTotal, err := getBillTotalTx(ctx, tx, billID)
@@ -160,6 +377,10 @@ func insertLineItemAndUpdateTotalTx(ctx context.Context, billID string, item *Li
 		return err
 	}
 
+	if err := postEntriesTx(ctx, tx, ledger.Charge(billID, item.ID, item.Amount, item.CreatedAt)); err != nil {
+		return err
+	}
+
 	if err := updateBillTotalTx(ctx, tx, billID, newTotal.Amount); err != nil {
 		return err
 	}
@@ -167,10 +388,270 @@ func insertLineItemAndUpdateTotalTx(ctx context.Context, billID string, item *Li
 	return tx.Commit()
 }
 
-// getBillTotalTx retrieves the current total for a bill within a transaction
+// InsertLineItemsAndUpdateTotal inserts a batch of line items with a single statement and adds
+// the amounts of the inserted items to the bill total in the same transaction, returning the
+// resulting total. Items whose ID already exists are skipped, so the batch is idempotent per item.
+// A non-zero usageBilledAt marks the usage claimed by the bill billed in the same transaction.
+func InsertLineItemsAndUpdateTotal(ctx context.Context, billID string, items []*LineItem, usageBilledAt time.Time) (money.Money, error) {
+	ctx, span := startDBSpan(ctx, "InsertLineItemsAndUpdateTotal", billID)
+	defer span.End()
+
+	var (
+		ids           = make([]string, len(items))
+		amounts       = make([]int64, len(items))
+		currencies    = make([]string, len(items))
+		descriptions  = make([]string, len(items))
+		createdAts    = make([]time.Time, len(items))
+		quantities    = make([]int64, len(items))
+		unitAmounts   = make([]*int64, len(items))
+		productIDs    = make([]string, len(items))
+		priceIDs      = make([]string, len(items))
+		sourceItemIDs = make([]string, len(items))
+		feeRuleIDs    = make([]string, len(items))
+	)
+	for i, item := range items {
+		ids[i] = item.ID
+		amounts[i] = item.Amount.Amount
+		currencies[i] = string(item.Amount.Currency)
+		descriptions[i] = item.Description
+		createdAts[i] = item.CreatedAt
+		quantities[i] = item.quantity()
+		unitAmounts[i] = item.unitAmount()
+		productIDs[i] = item.ProductID
+		priceIDs[i] = item.PriceID
+		sourceItemIDs[i] = item.SourceItemID
+		feeRuleIDs[i] = item.FeeRuleID
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	currentTotal, err := getBillTotalTx(ctx, tx, billID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO line_items (
+			id, bill_id, amount, currency, description, created_at,
+			quantity, unit_amount, product_id, price_id, source_item_id, fee_rule_id
+		)
+		SELECT id, $1, amount, currency, description, created_at,
+		       quantity, unit_amount, NULLIF(product_id, ''), NULLIF(price_id, ''),
+		       NULLIF(source_item_id, ''), NULLIF(fee_rule_id, '')
+		FROM unnest(
+			$2::text[], $3::bigint[], $4::text[], $5::text[], $6::timestamp[], $7::bigint[],
+			$8::bigint[], $9::text[], $10::text[], $11::text[], $12::text[]
+		) AS i(id, amount, currency, description, created_at, quantity,
+		       unit_amount, product_id, price_id, source_item_id, fee_rule_id)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id, amount, created_at
+	`, billID, ids, amounts, currencies, descriptions, createdAts, quantities,
+		unitAmounts, productIDs, priceIDs, sourceItemIDs, feeRuleIDs)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to insert %d line items for bill %s: %w", len(items), billID, err)
+	}
+	// Only the rows that were actually inserted are returned
+	var (
+		newTotal = currentTotal
+		charges  []*ledger.Entry
+	)
+	for rows.Next() {
+		var (
+			id        string
+			amount    int64
+			createdAt time.Time
+		)
+		if err := rows.Scan(&id, &amount, &createdAt); err != nil {
+			rows.Close()
+			return money.Money{}, err
+		}
+		charge := money.Money{Amount: amount, Currency: newTotal.Currency}
+		if newTotal, err = newTotal.Add(charge); err != nil {
+			rows.Close()
+			return money.Money{}, fmt.Errorf("failed to calculate new total for bill %s: %w", billID, err)
+		}
+		charges = append(charges, ledger.Charge(billID, id, charge, createdAt))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return money.Money{}, err
+	}
+	// A skipped item is only already added if it is on this bill. Checked after the insert,
+	// which waits for any concurrent insert of the same ID to commit.
+	if err := checkLineItemIDsTx(ctx, tx, billID, ids); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := postEntriesTx(ctx, tx, charges...); err != nil {
+		return money.Money{}, err
+	}
+
+	if !usageBilledAt.IsZero() {
+		if err := markUsageBilledTx(ctx, tx, billID, usageBilledAt); err != nil {
+			return money.Money{}, err
+		}
+	}
+
+	if err := updateBillTotalTx(ctx, tx, billID, newTotal.Amount); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return money.Money{}, err
+	}
+	return newTotal, nil
+}
+
+// DeleteLineItemAndUpdateTotal removes a line item and any fees charged on it and subtracts their amounts from the bill total
+// atomically in a single transaction. It returns the resulting bill total.
+// This function is idempotent - removing an item that no longer exists leaves the total unchanged.
+func DeleteLineItemAndUpdateTotal(ctx context.Context, billID, itemID string) (money.Money, error) {
+	ctx, span := startDBSpan(ctx, "DeleteLineItemAndUpdateTotal", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	currentTotal, err := getBillTotalTx(ctx, tx, billID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	// Fees charged on the item are removed with it
+	rows, err := tx.Query(ctx, `
+		DELETE FROM line_items
+		WHERE bill_id = $1 AND (id = $2 OR (source_item_id = $2 AND fee_rule_id IS NOT NULL))
+		RETURNING id, amount
+	`, billID, itemID)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to delete line item %s for bill %s: %w", itemID, billID, err)
+	}
+	var (
+		removed   int64
+		deleted   int
+		reversals []*ledger.Entry
+		now       = time.Now()
+	)
+	for rows.Next() {
+		var (
+			id     string
+			amount int64
+		)
+		if err := rows.Scan(&id, &amount); err != nil {
+			rows.Close()
+			return money.Money{}, err
+		}
+		removed += amount
+		deleted++
+		reversals = append(reversals, ledger.Charge(billID, id, money.Money{Amount: -amount, Currency: currentTotal.Currency}, now))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return money.Money{}, err
+	}
+	if deleted == 0 {
+		return currentTotal, nil // Idempotent - item already removed
+	}
+
+	newTotal, err := currentTotal.Sub(money.Money{Amount: removed, Currency: currentTotal.Currency})
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to calculate new total for bill %s: %w", billID, err)
+	}
+
+	if err := postEntriesTx(ctx, tx, reversals...); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := updateBillTotalTx(ctx, tx, billID, newTotal.Amount); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return money.Money{}, err
+	}
+	return newTotal, nil
+}
+
+// UpdateLineItemAndUpdateTotal changes the amount and/or description of a line item and adjusts
+// the bill total by the difference atomically in a single transaction. Nil fields are left unchanged.
+// It returns the resulting bill total. Re-applying the same change is a no-op.
+func UpdateLineItemAndUpdateTotal(ctx context.Context, billID, itemID string, amount *money.Money, description *string) (money.Money, error) {
+	ctx, span := startDBSpan(ctx, "UpdateLineItemAndUpdateTotal", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	currentTotal, err := getBillTotalTx(ctx, tx, billID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	existing, err := getLineItemByIDTx(ctx, tx, billID, itemID)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if existing == nil {
+		return money.Money{}, fmt.Errorf("line item %s for bill %s: %w", itemID, billID, ErrLineItemNotFound)
+	}
+
+	newAmount := existing.Amount
+	if amount != nil {
+		newAmount = *amount
+	}
+	newDescription := existing.Description
+	if description != nil {
+		newDescription = *description
+	}
+
+	// Adjust the total by the difference between the new and old amounts
+	newTotal, err := currentTotal.Sub(existing.Amount)
+	if err == nil {
+		newTotal, err = newTotal.Add(newAmount)
+	}
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to calculate new total for bill %s: %w", billID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE line_items SET amount = $1, description = $2 WHERE bill_id = $3 AND id = $4
+	`, newAmount.Amount, newDescription, billID, itemID); err != nil {
+		return money.Money{}, fmt.Errorf("failed to update line item %s for bill %s: %w", itemID, billID, err)
+	}
+
+	change, err := newAmount.Sub(existing.Amount)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("failed to calculate change of line item %s for bill %s: %w", itemID, billID, err)
+	}
+	if err := postEntriesTx(ctx, tx, ledger.Charge(billID, itemID, change, time.Now())); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := updateBillTotalTx(ctx, tx, billID, newTotal.Amount); err != nil {
+		return money.Money{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return money.Money{}, err
+	}
+	return newTotal, nil
+}
+
+// getBillTotalTx retrieves the current total for a bill within a transaction and locks the bill
+// row, so the total cannot change before the transaction writes the new one
 func getBillTotalTx(ctx context.Context, tx *sqldb.Tx, billID string) (money.Money, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT currency, total_amount FROM bills WHERE id = $1
+		SELECT currency, total_amount FROM bills WHERE id = $1 FOR UPDATE
 	`, billID)
 
 	var currencyStr string
@@ -201,15 +682,19 @@ func ListBillsByStatus(
 	ctx context.Context,
 	status Status,
 ) ([]*Bill, error) {
+	ctx, span := startDBSpan(ctx, "ListBillsByStatus", "")
+	defer span.End()
 
 	rows, err := db.Query(ctx, `
         SELECT
             id,
+            account_id,
             currency,
             status,
             total_amount,
             created_at,
-            closed_at
+            closed_at,
+            invoice_number
         FROM bills
         WHERE status = $1
         ORDER BY created_at DESC
@@ -231,15 +716,47 @@ func ListBillsByStatus(
 	return bills, nil
 }
 
+// CountOpenBills returns the number of open bills per currency
+func CountOpenBills(ctx context.Context) (map[money.Currency]int64, error) {
+	ctx, span := startDBSpan(ctx, "CountOpenBills", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT currency, COUNT(*) FROM bills WHERE status = $1 GROUP BY currency
+	`, Open)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count open bills: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[money.Currency]int64)
+	for rows.Next() {
+		var (
+			currency string
+			n        int64
+		)
+		if err := rows.Scan(&currency, &n); err != nil {
+			return nil, err
+		}
+		counts[money.Currency(currency)] = n
+	}
+	return counts, rows.Err()
+}
+
 func ListBillsAll(ctx context.Context) ([]*Bill, error) {
+	ctx, span := startDBSpan(ctx, "ListBillsAll", "")
+	defer span.End()
+
 	rows, err := db.Query(ctx, `
         SELECT
             id,
+            account_id,
             currency,
             status,
             total_amount,
             created_at,
-            closed_at
+            closed_at,
+            invoice_number
         FROM bills
         ORDER BY created_at DESC
     `)
@@ -261,10 +778,14 @@ func ListBillsAll(ctx context.Context) ([]*Bill, error) {
 }
 
 func InsertLineItem(ctx context.Context, item *LineItem) error {
+	ctx, span := startDBSpan(ctx, "InsertLineItem", item.BillID)
+	defer span.End()
+
 	_, err := db.Exec(ctx, `
         INSERT INTO line_items (
-            id, bill_id, amount, currency, description, created_at
-        ) VALUES ($1, $2, $3, $4, $5, $6)
+            id, bill_id, amount, currency, description, created_at,
+            quantity, unit_amount, product_id, price_id, source_item_id, fee_rule_id
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''))
     `,
 		item.ID,
 		item.BillID,
@@ -272,6 +793,12 @@ func InsertLineItem(ctx context.Context, item *LineItem) error {
 		item.Amount.Currency,
 		item.Description,
 		item.CreatedAt,
+		item.quantity(),
+		item.unitAmount(),
+		item.ProductID,
+		item.PriceID,
+		item.SourceItemID,
+		item.FeeRuleID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert line item %s for bill %s: %w", item.ID, item.BillID, err)
@@ -283,8 +810,9 @@ func InsertLineItem(ctx context.Context, item *LineItem) error {
 func InsertLineItemTx(ctx context.Context, tx *sqldb.Tx, item *LineItem) error {
 	_, err := tx.Exec(ctx, `
         INSERT INTO line_items (
-            id, bill_id, amount, currency, description, created_at
-        ) VALUES ($1, $2, $3, $4, $5, $6)
+            id, bill_id, amount, currency, description, created_at,
+            quantity, unit_amount, product_id, price_id, source_item_id, fee_rule_id
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''))
     `,
 		item.ID,
 		item.BillID,
@@ -292,6 +820,12 @@ func InsertLineItemTx(ctx context.Context, tx *sqldb.Tx, item *LineItem) error {
 		item.Amount.Currency,
 		item.Description,
 		item.CreatedAt,
+		item.quantity(),
+		item.unitAmount(),
+		item.ProductID,
+		item.PriceID,
+		item.SourceItemID,
+		item.FeeRuleID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert line item %s for bill %s in transaction: %w", item.ID, item.BillID, err)
@@ -299,9 +833,64 @@ func InsertLineItemTx(ctx context.Context, tx *sqldb.Tx, item *LineItem) error {
 	return nil
 }
 
+// scanLineItem reconstructs a LineItem domain object from database row data
+func scanLineItem(row interface{ Scan(...interface{}) error }, billID string) (*LineItem, error) {
+	var (
+		li           LineItem
+		amount       int64
+		currencyStr  string
+		unitAmount   sql.NullInt64
+		productID    sql.NullString
+		priceID      sql.NullString
+		sourceItemID sql.NullString
+		feeRuleID    sql.NullString
+	)
+
+	if err := row.Scan(
+		&li.ID,
+		&amount,
+		&currencyStr,
+		&li.Description,
+		&li.CreatedAt,
+		&li.Quantity,
+		&unitAmount,
+		&productID,
+		&priceID,
+		&sourceItemID,
+		&feeRuleID,
+	); err != nil {
+		return nil, err
+	}
+
+	li.BillID = billID
+	m, err := money.NewMoney(amount, money.Currency(currencyStr))
+	if err != nil {
+		return nil, err
+	}
+	li.Amount = m
+
+	if unitAmount.Valid {
+		unit, err := money.NewMoney(unitAmount.Int64, m.Currency)
+		if err != nil {
+			return nil, err
+		}
+		li.UnitAmount = &unit
+	}
+	li.ProductID = productID.String
+	li.PriceID = priceID.String
+	li.SourceItemID = sourceItemID.String
+	li.FeeRuleID = feeRuleID.String
+
+	return &li, nil
+}
+
 func ListLineItems(ctx context.Context, billID string) ([]*LineItem, error) {
+	ctx, span := startDBSpan(ctx, "ListLineItems", billID)
+	defer span.End()
+
 	rows, err := db.Query(ctx, `
-        SELECT id, amount, currency, description, created_at
+        SELECT id, amount, currency, description, created_at,
+               quantity, unit_amount, product_id, price_id, source_item_id, fee_rule_id
         FROM line_items
         WHERE bill_id = $1
         ORDER BY created_at ASC
@@ -314,30 +903,11 @@ func ListLineItems(ctx context.Context, billID string) ([]*LineItem, error) {
 	var items []*LineItem
 
 	for rows.Next() {
-		var (
-			li          LineItem
-			amount      int64
-			currencyStr string
-		)
-
-		if err := rows.Scan(
-			&li.ID,
-			&amount,
-			&currencyStr,
-			&li.Description,
-			&li.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-
-		li.BillID = billID
-		m, err := money.NewMoney(amount, money.Currency(currencyStr))
+		li, err := scanLineItem(rows, billID)
 		if err != nil {
 			return nil, err
 		}
-		li.Amount = m
-
-		items = append(items, &li)
+		items = append(items, li)
 	}
 
 	return items, nil
@@ -346,79 +916,60 @@ func ListLineItems(ctx context.Context, billID string) ([]*LineItem, error) {
 // getLineItemByIDTx retrieves a specific line item by ID and bill ID within a transaction
 func getLineItemByIDTx(ctx context.Context, tx *sqldb.Tx, billID, itemID string) (*LineItem, error) {
 	row := tx.QueryRow(ctx, `
-        SELECT id, amount, currency, description, created_at
+        SELECT id, amount, currency, description, created_at,
+               quantity, unit_amount, product_id, price_id, source_item_id, fee_rule_id
         FROM line_items
         WHERE bill_id = $1 AND id = $2
     `, billID, itemID)
 
-	var (
-		li          LineItem
-		amount      int64
-		currencyStr string
-	)
-
-	err := row.Scan(
-		&li.ID,
-		&amount,
-		&currencyStr,
-		&li.Description,
-		&li.CreatedAt,
-	)
-
+	li, err := scanLineItem(row, billID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // Not found
 	}
-
 	if err != nil {
 		return nil, err
 	}
 
-	li.BillID = billID
-	m, err := money.NewMoney(amount, money.Currency(currencyStr))
+	return li, nil
+}
+
+// checkLineItemIDsTx returns ErrLineItemConflict if any of ids is the ID of a line item on
+// another bill. The other bill is not named, as it may belong to another account.
+func checkLineItemIDsTx(ctx context.Context, tx *sqldb.Tx, billID string, ids []string) error {
+	var id string
+	err := tx.QueryRow(ctx, `
+        SELECT id FROM line_items
+        WHERE id = ANY($1) AND bill_id <> $2
+        LIMIT 1
+    `, ids, billID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to check line item IDs for bill %s: %w", billID, err)
 	}
-	li.Amount = m
-
-	return &li, nil
+	return fmt.Errorf("item %s: %w", id, ErrLineItemConflict)
 }
 
 // GetLineItemByID retrieves a specific line item by ID and bill ID
 func GetLineItemByID(ctx context.Context, billID, itemID string) (*LineItem, error) {
+	ctx, span := startDBSpan(ctx, "GetLineItemByID", billID)
+	defer span.End()
+
 	row := db.QueryRow(ctx, `
-        SELECT id, amount, currency, description, created_at
+        SELECT id, amount, currency, description, created_at,
+               quantity, unit_amount, product_id, price_id, source_item_id, fee_rule_id
         FROM line_items
         WHERE bill_id = $1 AND id = $2
     `, billID, itemID)
 
-	var (
-		li          LineItem
-		amount      int64
-		currencyStr string
-	)
-
-	err := row.Scan(
-		&li.ID,
-		&amount,
-		&currencyStr,
-		&li.Description,
-		&li.CreatedAt,
-	)
-
+	li, err := scanLineItem(row, billID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // Not found
 	}
-
 	if err != nil {
 		return nil, err
 	}
 
-	li.BillID = billID
-	m, err := money.NewMoney(amount, money.Currency(currencyStr))
-	if err != nil {
-		return nil, err
-	}
-	li.Amount = m
-
-	return &li, nil
+	return li, nil
 }
@@ -3,36 +3,33 @@ package bill
 import (
 	"context"
 	"errors"
-	"log"
-	"sync"
 	"time"
 
 	"fees-api/money"
 
+	"encore.dev/beta/auth"
 	"encore.dev/beta/errs"
 	"encore.dev/config"
 	"github.com/google/uuid"
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/client"
-	"go.temporal.io/sdk/temporal"
 )
 
-var (
-	temporalCfg    = config.Load[*Config]()
-	temporalClient client.Client
-	temporalOnce   sync.Once
-)
+var cfg = config.Load[*Config]()
 
 //encore:service
-type Service struct{}
-
-//encore:api private
-func (s *Service) HealthCheck(ctx context.Context) error {
-	// Could add actual health checks here
-	return nil
+type Service struct {
+	stopTracing func(context.Context) error // flushes spans not yet exported
+	stopMetrics func(context.Context) error // flushes metrics not yet exported
 }
 
-// Create creates a new bill with the specified currency and starts a Temporal workflow
-func Create(ctx context.Context, currency money.Currency) (*Bill, error) {
+// createTimeout bounds how long Create waits for the workflow to insert the bill row
+const createTimeout = 30 * time.Second
+
+// Create creates a new bill with the specified currency by starting its Temporal workflow, which
+// inserts the bill row as its first step, and waits for that step to finish.
+// accountID is optional; bills with an account collect the account's metered usage on close.
+func Create(ctx context.Context, currency money.Currency, accountID string) (*Bill, error) {
 	// Check if Temporal is available
 	if GetTemporalClient() == nil {
 		return nil, errs.WrapCode(nil, errs.Unavailable,
@@ -40,48 +37,46 @@ func Create(ctx context.Context, currency money.Currency) (*Bill, error) {
 	}
 
 	billID := uuid.NewString()
+	ctx = withBill(ctx, billID, accountID)
 
-	retryPolicy := &temporal.RetryPolicy{
-		InitialInterval:    time.Second,
-		BackoffCoefficient: 2.0,
-		MaximumInterval:    time.Minute,
-		MaximumAttempts:    3,
-	}
-
-	// Start Temporal workflow FIRST to avoid race condition
-	_, err := GetTemporalClient().ExecuteWorkflow(
+	run, err := GetTemporalClient().ExecuteWorkflow(
 		ctx,
 		client.StartWorkflowOptions{
 			ID:          "bill-" + billID,
-			TaskQueue:   "BILLING_TASK_QUEUE",
-			RetryPolicy: retryPolicy,
+			TaskQueue:   cfg.Temporal.TaskQueue,
+			RetryPolicy: cfg.Workflow.WorkflowRetry.policy(),
 		},
 		"BillWorkflow",
 		billID,
 		currency,
+		accountID,
+		cfg.Workflow.historyLimits(),
+		(*BillState)(nil),
+		&cfg.Workflow.ActivityRetry,
 	)
 	if err != nil {
 		return nil, errs.Wrap(err, "failed to start bill workflow")
 	}
 
-	// Create bill in database AFTER workflow successfully started
-	total, err := money.NewMoney(0, currency)
+	// The workflow owns the row, so there is nothing to undo if waiting fails: the bill is
+	// either created by the workflow or the workflow fails without one
+	ctx, cancel := context.WithTimeout(ctx, createTimeout)
+	defer cancel()
+	handle, err := GetTemporalClient().UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   run.GetID(),
+		RunID:        run.GetRunID(),
+		UpdateName:   createdUpdate,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
 	if err != nil {
-		return nil, errs.Wrap(err, "failed to create initial money amount")
-	}
-
-	bill := &Bill{
-		ID:        billID,
-		Total:     total,
-		Status:    Open,
-		CreatedAt: time.Now(),
+		return nil, errs.Wrap(err, "failed to wait for bill creation")
 	}
-	if err := CreateBill(ctx, bill); err != nil {
-		// Workflow started but bill creation failed
-		_ = GetTemporalClient().TerminateWorkflow(ctx, "bill-"+billID, "", "Database creation failed", nil)
-		return nil, errs.Wrap(err, "workflow started but bill creation failed")
+	var bill *Bill
+	if err := handle.Get(ctx, &bill); err != nil {
+		return nil, errs.Wrap(err, "bill creation failed")
 	}
 
+	billsCreated.With(CurrencyLabels{Currency: string(currency)}).Increment()
 	return bill, nil
 }
 
@@ -101,12 +96,6 @@ func GetLineItems(ctx context.Context, billID string) ([]*LineItem, error) {
 
 // Close closes a bill by signaling the Temporal workflow
 func Close(ctx context.Context, billID string) error {
-	// Check if Temporal is available
-	if GetTemporalClient() == nil {
-		return errs.WrapCode(nil, errs.Unavailable,
-			"bill operations unavailable - Temporal workflow service is down")
-	}
-
 	bill, err := GetByID(ctx, billID)
 	if err != nil {
 		return err
@@ -116,13 +105,7 @@ func Close(ctx context.Context, billID string) error {
 		return errs.Wrap(err, "bill is not open")
 	}
 
-	err = GetTemporalClient().SignalWorkflow(
-		ctx,
-		"bill-"+bill.ID,
-		"",
-		"close-bill",
-		nil,
-	)
+	err = signalBill(ctx, bill.ID, "close-bill", nil)
 	if err != nil {
 		return errs.Wrap(err, "failed to signal close bill workflow")
 	}
@@ -132,6 +115,179 @@ func Close(ctx context.Context, billID string) error {
 
 // AddLineItem adds a line item to a bill by signaling the Temporal workflow
 func AddLineItem(ctx context.Context, billID string, amount int64, description string) error {
+	return signalAddItem(ctx, billID, AddItemSignal{
+		ItemID:      uuid.NewString(),
+		Amount:      amount,
+		Description: description,
+	}, "")
+}
+
+// AddCatalogLineItem adds quantity units of a catalog price to a bill by signaling the Temporal workflow.
+// The workflow computes the amount; an empty description defaults to the product name.
+func AddCatalogLineItem(ctx context.Context, billID, priceID string, quantity int64, description string) error {
+	price, err := GetPrice(ctx, priceID)
+	if errors.Is(err, ErrPriceNotFound) {
+		return errs.WrapCode(err, errs.NotFound, "price not found")
+	}
+	if err != nil {
+		return errs.Wrap(err, "failed to look up price")
+	}
+	if !price.Active {
+		return errs.WrapCode(errors.New("price is archived"), errs.FailedPrecondition, "price is archived")
+	}
+
+	return signalAddItem(ctx, billID, AddItemSignal{
+		ItemID:      uuid.NewString(),
+		Description: description,
+		PriceID:     price.ID,
+		Quantity:    quantity,
+	}, price.Currency)
+}
+
+// signalAddItem checks that the bill is open and signals its workflow to add the item.
+// currency, when set, must match the bill's currency.
+func signalAddItem(ctx context.Context, billID string, signal AddItemSignal, currency money.Currency) error {
+	bill, err := getOpenBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+
+	if currency != "" && currency != bill.Total.Currency {
+		return errs.WrapCode(errors.New("currency mismatch"), errs.InvalidArgument, "price currency does not match bill currency")
+	}
+
+	err = signalBill(ctx, bill.ID, "add-item", signal)
+	if err != nil {
+		return errs.Wrap(err, "failed to add item workflow")
+	}
+
+	return nil
+}
+
+// AddLineItems delivers already validated items to the bill workflow as one batch, which is
+// inserted and added to the bill total in a single transaction
+func AddLineItems(ctx context.Context, billID string, items []AddItemSignal) error {
+	bill, err := getOpenBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+
+	err = signalBill(ctx, bill.ID, "add-items", AddItemsSignal{Items: items})
+	if err != nil {
+		return errs.Wrap(err, "failed to add items workflow")
+	}
+
+	return nil
+}
+
+// getOpenBill returns the bill if it can still take line items
+func getOpenBill(ctx context.Context, billID string) (*Bill, error) {
+	bill, err := GetByID(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureOpen(bill); err != nil {
+		return nil, errs.Wrap(err, "failed to add item workflow")
+	}
+	return bill, nil
+}
+
+// RemoveLineItem removes a line item from an open bill by signaling the Temporal workflow
+func RemoveLineItem(ctx context.Context, billID, itemID string) error {
+	bill, err := GetByID(ctx, billID)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureOpen(bill); err != nil {
+		return errs.WrapCode(err, errs.FailedPrecondition, "bill is not open")
+	}
+
+	if err := ensureLineItemExists(ctx, billID, itemID); err != nil {
+		return err
+	}
+
+	err = signalBill(ctx, bill.ID, "remove-item", RemoveItemSignal{ItemID: itemID})
+	if err != nil {
+		return errs.Wrap(err, "failed to signal remove item workflow")
+	}
+
+	return nil
+}
+
+// AmendLineItem changes the amount and/or description of a line item on an open bill
+// by signaling the Temporal workflow. Nil fields are left unchanged.
+func AmendLineItem(ctx context.Context, billID, itemID string, amount *int64, description *string) error {
+	bill, err := GetByID(ctx, billID)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureOpen(bill); err != nil {
+		return errs.WrapCode(err, errs.FailedPrecondition, "bill is not open")
+	}
+
+	item, err := GetLineItemByID(ctx, billID, itemID)
+	if err != nil {
+		return errs.Wrap(err, "failed to look up line item")
+	}
+	if item == nil {
+		return errs.WrapCode(ErrLineItemNotFound, errs.NotFound, "line item not found")
+	}
+	if item.PriceID != "" && amount != nil {
+		return errs.WrapCode(errors.New("catalog-priced item amount is computed"), errs.FailedPrecondition,
+			"the amount of a catalog-priced line item cannot be changed; remove it and add it again")
+	}
+
+	err = signalBill(ctx, bill.ID, "amend-item", AmendItemSignal{ItemID: itemID, Amount: amount, Description: description})
+	if err != nil {
+		return errs.Wrap(err, "failed to signal amend item workflow")
+	}
+
+	return nil
+}
+
+// ensureLineItemExists returns NotFound if the bill has no line item with the given ID
+func ensureLineItemExists(ctx context.Context, billID, itemID string) error {
+	item, err := GetLineItemByID(ctx, billID, itemID)
+	if err != nil {
+		return errs.Wrap(err, "failed to look up line item")
+	}
+	if item == nil {
+		return errs.WrapCode(ErrLineItemNotFound, errs.NotFound, "line item not found")
+	}
+	return nil
+}
+
+// VoidBill marks an open bill as created by mistake by signaling the Temporal workflow.
+// A bill that was invoiced before being reopened cannot be voided.
+func VoidBill(ctx context.Context, billID string, reason string) error {
+	bill, err := GetByID(ctx, billID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateTransition(bill.Status, Void); err != nil {
+		return errs.WrapCode(err, errs.FailedPrecondition, "bill cannot be voided")
+	}
+	if bill.InvoiceNumber != "" {
+		return errs.WrapCode(ErrBillInvoiced, errs.FailedPrecondition,
+			"bill was invoiced as "+bill.InvoiceNumber+" and must be corrected with a credit note")
+	}
+
+	actor, _ := auth.UserID()
+	err = signalBill(ctx, bill.ID, "void-bill", StatusChangeSignal{Reason: reason, Actor: string(actor)})
+	if err != nil {
+		return errs.Wrap(err, "failed to signal void bill workflow")
+	}
+
+	return nil
+}
+
+// ReopenBill moves a recently closed bill back to OPEN by signaling the Temporal workflow.
+// It is never queued: only the workflow knows whether the bill can still be reopened.
+func ReopenBill(ctx context.Context, billID string, reason string) error {
 	// Check if Temporal is available
 	if GetTemporalClient() == nil {
 		return errs.WrapCode(nil, errs.Unavailable,
@@ -143,49 +299,39 @@ func AddLineItem(ctx context.Context, billID string, amount int64, description s
 		return err
 	}
 
-	if err := ensureOpen(bill); err != nil {
-		return errs.Wrap(err, "failed to add item workflow")
+	if err := validateTransition(bill.Status, Open); err != nil {
+		return errs.WrapCode(err, errs.FailedPrecondition, "bill cannot be reopened")
 	}
-
-	signal := AddItemSignal{
-		ItemID:      uuid.NewString(),
-		Amount:      amount,
-		Description: description,
+	if bill.ClosedAt != nil && time.Since(*bill.ClosedAt) > reopenWindow {
+		return errs.WrapCode(errors.New("reopen window has passed"), errs.FailedPrecondition, "bill was closed too long ago to reopen")
 	}
 
+	actor, _ := auth.UserID()
 	err = GetTemporalClient().SignalWorkflow(
 		ctx,
 		"bill-"+bill.ID,
 		"",
-		"add-item",
-		signal,
+		"reopen-bill",
+		StatusChangeSignal{Reason: reason, Actor: string(actor)},
 	)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return errs.WrapCode(err, errs.FailedPrecondition, "bill was closed too long ago to reopen")
+	}
 	if err != nil {
-		return errs.Wrap(err, "failed to add item workflow")
+		return errs.Wrap(err, "failed to signal reopen bill workflow")
 	}
 
 	return nil
 }
 
-// GetTemporalClient returns the temporal client initialized for this service
-// Returns nil if Temporal server is unavailable (logs warning)
-func GetTemporalClient() client.Client {
-	temporalOnce.Do(func() {
-		client, err := client.Dial(client.Options{HostPort: temporalCfg.TemporalServer})
-		if err != nil {
-			log.Printf("WARNING: Temporal server unavailable at %s: %v. Bill workflow operations will fail.",
-				temporalCfg.TemporalServer, err)
-			return // temporalClient remains nil
-		}
-		temporalClient = client
-	})
-	return temporalClient
-}
-
 // ensureOpen checks if a bill is open and returns an error if not
 func ensureOpen(b *Bill) error {
-	if b.Status == Closed {
+	switch b.Status {
+	case Closed:
 		return errors.New("bill already closed")
+	case Void:
+		return errors.New("bill is void")
 	}
 	return nil
 }
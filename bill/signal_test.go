@@ -1,7 +1,10 @@
 package bill
 
 import (
+	"context"
 	"testing"
+
+	"fees-api/money"
 )
 
 func TestAddItemSignal(t *testing.T) {
@@ -21,3 +24,101 @@ func TestAddItemSignal(t *testing.T) {
 		t.Errorf("Expected Description test item, got %v", signal.Description)
 	}
 }
+
+func TestValidateAmendItemSignal(t *testing.T) {
+	itemID := "9f1c2b1e-4f7a-4d8e-9a55-0c6f3c2b7d11"
+	amount := int64(250)
+	zero := int64(0)
+	tooMuch := int64(MaxAmountCents + 1)
+	description := "corrected fee"
+	blank := "   "
+
+	tests := []struct {
+		name    string
+		signal  AmendItemSignal
+		wantErr bool
+	}{
+		{"amount only", AmendItemSignal{ItemID: itemID, Amount: &amount}, false},
+		{"description only", AmendItemSignal{ItemID: itemID, Description: &description}, false},
+		{"both fields", AmendItemSignal{ItemID: itemID, Amount: &amount, Description: &description}, false},
+		{"no fields", AmendItemSignal{ItemID: itemID}, true},
+		{"missing item ID", AmendItemSignal{Amount: &amount}, true},
+		{"invalid item ID", AmendItemSignal{ItemID: "not-a-uuid", Amount: &amount}, true},
+		{"zero amount", AmendItemSignal{ItemID: itemID, Amount: &zero}, true},
+		{"amount over limit", AmendItemSignal{ItemID: itemID, Amount: &tooMuch}, true},
+		{"blank description", AmendItemSignal{ItemID: itemID, Description: &blank}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAmendItemSignal(tt.signal)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAmendItemSignal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAddItemSignal_Catalog(t *testing.T) {
+	itemID := "9f1c2b1e-4f7a-4d8e-9a55-0c6f3c2b7d11"
+	priceID := "3e7c1f0a-8b2d-4c6e-9f13-5a7b9c1d2e3f"
+
+	tests := []struct {
+		name    string
+		signal  AddItemSignal
+		wantErr bool
+	}{
+		{"raw amount", AddItemSignal{ItemID: itemID, Amount: 100, Description: "fee"}, false},
+		{"catalog item", AddItemSignal{ItemID: itemID, PriceID: priceID, Quantity: 3}, false},
+		{"catalog item with description", AddItemSignal{ItemID: itemID, PriceID: priceID, Quantity: 3, Description: "3 seats"}, false},
+		{"catalog item without quantity", AddItemSignal{ItemID: itemID, PriceID: priceID}, true},
+		{"catalog item with amount", AddItemSignal{ItemID: itemID, PriceID: priceID, Quantity: 1, Amount: 100}, true},
+		{"invalid price ID", AddItemSignal{ItemID: itemID, PriceID: "nope", Quantity: 1}, true},
+		{"quantity without price", AddItemSignal{ItemID: itemID, Amount: 100, Description: "fee", Quantity: 2}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAddItemSignal(tt.signal)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAddItemSignal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBatchItemSignal(t *testing.T) {
+	prices := newPriceChecker(context.Background(), money.USD)
+	const itemID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	tests := []struct {
+		name    string
+		item    BatchItemRequest
+		wantID  string
+		wantErr bool
+	}{
+		{"client item ID", BatchItemRequest{ItemID: itemID, Amount: 1500, Description: "Setup fee"}, itemID, false},
+		{"generated item ID", BatchItemRequest{Amount: 1500, Description: "Setup fee"}, "", false},
+		{"invalid item ID", BatchItemRequest{ItemID: "item-1", Amount: 1500, Description: "Setup fee"}, "", true},
+		{"invalid amount", BatchItemRequest{Amount: -1, Description: "Setup fee"}, "", true},
+		{"missing description", BatchItemRequest{Amount: 1500}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal, err := batchItemSignal(tt.item, prices)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("batchItemSignal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantID != "" && signal.ItemID != tt.wantID {
+				t.Errorf("ItemID = %q, want %q", signal.ItemID, tt.wantID)
+			}
+			if err := validateItemID(signal.ItemID); err != nil {
+				t.Errorf("ItemID %q is not valid: %v", signal.ItemID, err)
+			}
+		})
+	}
+}
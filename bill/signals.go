@@ -1,7 +1,35 @@
 package bill
 
+// AddItemSignal adds a line item either with a raw Amount or, when PriceID is set,
+// priced from the catalog as Quantity units of that price
 type AddItemSignal struct {
 	ItemID      string
 	Amount      int64
 	Description string
+	PriceID     string
+	Quantity    int64
+}
+
+// AddItemsSignal adds many line items at once; the workflow inserts them, and the fees they
+// incur, in a single transaction
+type AddItemsSignal struct {
+	Items []AddItemSignal
+}
+
+// StatusChangeSignal carries the reason for a void or reopen request
+type StatusChangeSignal struct {
+	Reason string
+	Actor  string
+}
+
+// RemoveItemSignal removes a line item from an open bill
+type RemoveItemSignal struct {
+	ItemID string
+}
+
+// AmendItemSignal changes a line item on an open bill; nil fields are left unchanged
+type AmendItemSignal struct {
+	ItemID      string
+	Amount      *int64
+	Description *string
 }
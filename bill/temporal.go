@@ -0,0 +1,288 @@
+package bill
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// TemporalStatus describes the connection to the Temporal server
+type TemporalStatus struct {
+	Connected bool      `json:"connected"`
+	Server    string    `json:"server"`
+	Since     time.Time `json:"since"`              // when the connection was made or lost
+	Attempts  int       `json:"attempts,omitempty"` // failed attempts since it was lost
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// clientManager owns the Temporal client. When the server cannot be reached it keeps dialing in
+// the background with exponential backoff, and runs the registered hooks once it connects.
+// A connected client reconnects on its own; the manager only tracks whether it is healthy.
+type clientManager struct {
+	server     string
+	dial       func() (client.Client, error)
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	start     sync.Once
+	mu        sync.RWMutex
+	client    client.Client
+	status    TemporalStatus
+	onConnect []func(client.Client)
+	closed    bool
+}
+
+func newClientManager(server string, dial func() (client.Client, error)) *clientManager {
+	return &clientManager{
+		server:     server,
+		dial:       dial,
+		minBackoff: minReconnectBackoff,
+		maxBackoff: maxReconnectBackoff,
+		status:     TemporalStatus{Server: server, Since: time.Now()},
+	}
+}
+
+var temporalManager = newClientManager(cfg.TemporalServer, func() (client.Client, error) {
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client.Dial(opts)
+})
+
+// clientOptions returns the options for dialing the configured Temporal server
+func clientOptions(c *Config) (client.Options, error) {
+	opts := client.Options{
+		HostPort:       c.TemporalServer,
+		Namespace:      c.Temporal.Namespace,
+		MetricsHandler: newMetricsHandler(),
+		Logger:         temporalLogger(),
+		// Workflows and their activities log the ID of the request that started them
+		ContextPropagators: []workflow.ContextPropagator{requestIDPropagator{}},
+	}
+	tlsConfig, err := c.Temporal.TLS.load()
+	if err != nil {
+		return client.Options{}, err
+	}
+	opts.ConnectionOptions.TLS = tlsConfig
+
+	// Workers created from the client trace workflows and activities too
+	tracing, err := newTracingInterceptor(tracer)
+	if err != nil {
+		return client.Options{}, fmt.Errorf("failed to create tracing interceptor: %w", err)
+	}
+	opts.Interceptors = []interceptor.ClientInterceptor{tracing}
+	return opts, nil
+}
+
+// load returns the TLS configuration for the Temporal connection, or nil when TLS is off
+func (c TLSConfig) load() (*tls.Config, error) {
+	if !c.Enabled {
+		if c.CertFile != "" || c.KeyFile != "" || c.CAFile != "" {
+			return nil, errors.New("temporal TLS: certificates are set but TLS is not enabled")
+		}
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.ServerName}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("temporal TLS: client certificate and key must be set together")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("temporal TLS: failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("temporal TLS: failed to read CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("temporal TLS: no certificates found in %s", c.CAFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// validateTemporalConfig checks the Temporal connection, worker and retry settings, loading any
+// certificates, so that a bad configuration stops the service instead of every dial failing
+func validateTemporalConfig(c *Config) error {
+	if c.TemporalServer == "" {
+		return errors.New("temporal server address is not set")
+	}
+	if c.Temporal.Namespace == "" {
+		return errors.New("temporal namespace is not set")
+	}
+	if c.Temporal.TaskQueue == "" {
+		return errors.New("temporal task queue is not set")
+	}
+	if _, err := c.Temporal.TLS.load(); err != nil {
+		return err
+	}
+
+	w := c.Worker
+	if w.MaxConcurrentActivities < 0 || w.MaxConcurrentWorkflowTasks < 0 || w.ActivityPollers < 0 || w.WorkflowTaskPollers < 0 || w.StopTimeoutSeconds < 0 {
+		return fmt.Errorf("worker limits cannot be negative, got %+v", w)
+	}
+	if err := c.Workflow.ActivityRetry.validate("activity"); err != nil {
+		return err
+	}
+	return c.Workflow.WorkflowRetry.validate("workflow")
+}
+
+// GetTemporalClient returns the temporal client initialized for this service.
+// Returns nil while the Temporal server is unavailable; the client is dialed again in the background.
+func GetTemporalClient() client.Client {
+	return temporalManager.Client()
+}
+
+// Client returns the connected client, or nil. The first call dials once before returning and,
+// if that fails, leaves a background loop redialing until the server is reachable.
+func (m *clientManager) Client() client.Client {
+	m.start.Do(func() {
+		if !m.connect() {
+			go m.reconnect()
+		}
+	})
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.client
+}
+
+// Status returns the state of the connection as last observed
+func (m *clientManager) Status() TemporalStatus {
+	m.Client()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Check asks a connected server for its health and records the result
+func (m *clientManager) Check(ctx context.Context) TemporalStatus {
+	c := m.Client()
+	if c == nil {
+		return m.Status()
+	}
+
+	_, err := c.CheckHealth(ctx, &client.CheckHealthRequest{})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case err != nil && m.status.Connected:
+		logger.Warn("Temporal server is unhealthy", "server", m.server, "error", err)
+		m.status = TemporalStatus{Server: m.server, Since: time.Now(), LastError: err.Error()}
+	case err != nil:
+		m.status.LastError = err.Error()
+	case !m.status.Connected:
+		logger.Info("Temporal server is healthy again", "server", m.server)
+		m.status = TemporalStatus{Connected: true, Server: m.server, Since: time.Now()}
+	}
+	return m.status
+}
+
+// OnConnect registers a hook run once the client connects, straight away if it already has
+func (m *clientManager) OnConnect(hook func(client.Client)) {
+	m.mu.Lock()
+	c := m.client
+	if c == nil && !m.closed {
+		m.onConnect = append(m.onConnect, hook)
+	}
+	m.mu.Unlock()
+
+	if c != nil {
+		hook(c)
+	}
+}
+
+// Close closes the client and stops redialing. Client returns nil from then on, and hooks still
+// waiting for a connection never run.
+func (m *clientManager) Close() {
+	m.mu.Lock()
+	c := m.client
+	m.client = nil
+	m.closed = true
+	m.onConnect = nil
+	m.status = TemporalStatus{Server: m.server, Since: time.Now(), LastError: "client closed"}
+	m.mu.Unlock()
+
+	if c != nil {
+		c.Close()
+	}
+}
+
+// connect dials once and reports whether the manager is done dialing, because the client is
+// connected or the manager was closed
+func (m *clientManager) connect() bool {
+	if m.isClosed() {
+		return true
+	}
+	c, err := m.dial()
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		if c != nil {
+			c.Close()
+		}
+		return true
+	}
+	if err != nil {
+		m.status.Attempts++
+		m.status.LastError = err.Error()
+		attempts := m.status.Attempts
+		m.mu.Unlock()
+		logger.Warn("Temporal server unavailable, bill workflow operations will fail until it is reachable",
+			"server", m.server, "attempt", attempts, "error", err)
+		return false
+	}
+	m.client = c
+	m.status = TemporalStatus{Connected: true, Server: m.server, Since: time.Now()}
+	hooks := m.onConnect
+	m.onConnect = nil
+	m.mu.Unlock()
+
+	logger.Info("Connected to Temporal server", "server", m.server)
+	for _, hook := range hooks {
+		hook(c)
+	}
+	return true
+}
+
+func (m *clientManager) isClosed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.closed
+}
+
+// reconnect dials until it succeeds or the manager is closed, doubling the wait between attempts up to maxBackoff
+func (m *clientManager) reconnect() {
+	backoff := m.minBackoff
+	for {
+		time.Sleep(backoff)
+		if m.connect() {
+			return
+		}
+		backoff = min(2*backoff, m.maxBackoff)
+	}
+}
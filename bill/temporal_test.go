@@ -0,0 +1,232 @@
+package bill
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// fakeClient stands in for a connected client; the manager only ever closes it
+type fakeClient struct {
+	client.Client
+	closed atomic.Int32
+}
+
+func (c *fakeClient) Close() {
+	c.closed.Add(1)
+}
+
+// fakeDial fails the first failures dials and then returns a fake client
+func fakeDial(failures int) (func() (client.Client, error), *int) {
+	var (
+		mu    sync.Mutex
+		dials int
+	)
+	return func() (client.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials <= failures {
+			return nil, errors.New("connection refused")
+		}
+		return &fakeClient{}, nil
+	}, &dials
+}
+
+func newTestClientManager(dial func() (client.Client, error)) *clientManager {
+	m := newClientManager("temporal:7233", dial)
+	m.minBackoff = time.Millisecond
+	m.maxBackoff = 4 * time.Millisecond
+	return m
+}
+
+func TestClientManagerReconnects(t *testing.T) {
+	dial, dials := fakeDial(3)
+	m := newTestClientManager(dial)
+
+	connected := make(chan client.Client, 2)
+	m.OnConnect(func(c client.Client) { connected <- c })
+
+	if c := m.Client(); c != nil {
+		t.Fatalf("Client() = %v before the server is reachable, want nil", c)
+	}
+	status := m.Status()
+	if status.Connected || status.Attempts == 0 || status.LastError != "connection refused" {
+		t.Errorf("status while down = %+v, want failed attempts with the dial error", status)
+	}
+
+	select {
+	case c := <-connected:
+		if c != m.Client() {
+			t.Errorf("hook got %v, want the managed client", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hook did not run after the server became reachable")
+	}
+
+	status = m.Status()
+	if !status.Connected || status.Attempts != 0 || status.LastError != "" || status.Server != "temporal:7233" {
+		t.Errorf("status after reconnecting = %+v, want connected with no errors", status)
+	}
+	if *dials != 4 {
+		t.Errorf("dialed %d times, want 4", *dials)
+	}
+
+	// Hooks run once, and those registered later run straight away
+	ran := false
+	m.OnConnect(func(client.Client) { ran = true })
+	if !ran {
+		t.Error("hook registered after connecting did not run")
+	}
+	select {
+	case <-connected:
+		t.Error("hook ran twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestClientManagerConnectsOnFirstUse(t *testing.T) {
+	dial, dials := fakeDial(0)
+	m := newTestClientManager(dial)
+
+	if *dials != 0 {
+		t.Fatalf("dialed %d times before first use, want 0", *dials)
+	}
+	if m.Client() == nil {
+		t.Fatal("Client() = nil, want the dialed client")
+	}
+	m.Client()
+	if *dials != 1 {
+		t.Errorf("dialed %d times, want 1", *dials)
+	}
+	if !m.Status().Connected {
+		t.Error("status is not connected")
+	}
+}
+
+func TestClientManagerClose(t *testing.T) {
+	dial, _ := fakeDial(0)
+	m := newTestClientManager(dial)
+	c := m.Client().(*fakeClient)
+
+	m.Close()
+	if got := m.Client(); got != nil {
+		t.Errorf("Client() = %v after Close, want nil", got)
+	}
+	if n := c.closed.Load(); n != 1 {
+		t.Errorf("client closed %d times, want 1", n)
+	}
+	if m.Status().Connected {
+		t.Error("status is connected after Close")
+	}
+	ran := false
+	m.OnConnect(func(client.Client) { ran = true })
+	if ran {
+		t.Error("hook ran after Close")
+	}
+}
+
+func TestClientManagerCloseStopsReconnecting(t *testing.T) {
+	dial, dials := fakeDial(1 << 30)
+	var mu sync.Mutex
+	m := newTestClientManager(func() (client.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return dial()
+	})
+	ran := false
+	m.OnConnect(func(client.Client) { ran = true })
+
+	m.Client()
+	time.Sleep(10 * time.Millisecond)
+	m.Close()
+	mu.Lock()
+	before := *dials
+	mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	after := *dials
+	mu.Unlock()
+	if after > before+1 { // one dial may have been in flight
+		t.Errorf("dialed %d more times after Close", after-before)
+	}
+	if ran {
+		t.Error("hook ran after Close")
+	}
+}
+
+func TestValidateTemporalConfig(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			TemporalServer: "localhost:7233",
+			Temporal:       TemporalConfig{Namespace: "default", TaskQueue: "BILLING_TASK_QUEUE"},
+			Workflow: WorkflowConfig{
+				ActivityRetry: defaultActivityRetry,
+				WorkflowRetry: RetryConfig{InitialIntervalSeconds: 1, BackoffCoefficient: 2, MaximumIntervalSeconds: 60, MaximumAttempts: 3},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr bool
+	}{
+		{"valid", func(c *Config) {}, false},
+		{"SDK default worker limits", func(c *Config) { c.Worker = WorkerConfig{} }, false},
+		{"worker limits", func(c *Config) { c.Worker = WorkerConfig{MaxConcurrentActivities: 50, ActivityPollers: 4} }, false},
+		{"unlimited attempts", func(c *Config) { c.Workflow.ActivityRetry.MaximumAttempts = 0 }, false},
+		{"no server", func(c *Config) { c.TemporalServer = "" }, true},
+		{"no namespace", func(c *Config) { c.Temporal.Namespace = "" }, true},
+		{"no task queue", func(c *Config) { c.Temporal.TaskQueue = "" }, true},
+		{"negative worker limit", func(c *Config) { c.Worker.WorkflowTaskPollers = -1 }, true},
+		{"zero initial interval", func(c *Config) { c.Workflow.ActivityRetry.InitialIntervalSeconds = 0 }, true},
+		{"backoff below 1", func(c *Config) { c.Workflow.WorkflowRetry.BackoffCoefficient = 0.5 }, true},
+		{"maximum below initial interval", func(c *Config) { c.Workflow.ActivityRetry.MaximumIntervalSeconds = 0 }, true},
+		{"negative attempts", func(c *Config) { c.Workflow.WorkflowRetry.MaximumAttempts = -1 }, true},
+		{"TLS without certificates", func(c *Config) { c.Temporal.TLS = TLSConfig{Enabled: true, ServerName: "temporal"} }, false},
+		{"certificates without TLS", func(c *Config) { c.Temporal.TLS = TLSConfig{CAFile: "ca.pem"} }, true},
+		{"certificate without key", func(c *Config) { c.Temporal.TLS = TLSConfig{Enabled: true, CertFile: "client.pem"} }, true},
+		{"missing CA bundle", func(c *Config) { c.Temporal.TLS = TLSConfig{Enabled: true, CAFile: "testdata/missing.pem"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.change(c)
+			if err := validateTemporalConfig(c); (err != nil) != tt.wantErr {
+				t.Errorf("validateTemporalConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	c := &Config{
+		TemporalServer: "temporal.internal:7233",
+		Temporal:       TemporalConfig{Namespace: "fees", TLS: TLSConfig{Enabled: true, ServerName: "temporal.internal"}},
+	}
+	opts, err := clientOptions(c)
+	if err != nil {
+		t.Fatalf("clientOptions() error = %v", err)
+	}
+	if opts.HostPort != c.TemporalServer || opts.Namespace != "fees" {
+		t.Errorf("options = %s in %s, want %s in fees", opts.HostPort, opts.Namespace, c.TemporalServer)
+	}
+	if tls := opts.ConnectionOptions.TLS; tls == nil || tls.ServerName != "temporal.internal" {
+		t.Errorf("TLS = %+v, want server name temporal.internal", tls)
+	}
+	if opts.MetricsHandler == nil {
+		t.Error("no metrics handler, want SDK metrics exported")
+	}
+
+	c.Temporal.TLS = TLSConfig{}
+	if opts, _ := clientOptions(c); opts.ConnectionOptions.TLS != nil {
+		t.Error("TLS is set while disabled")
+	}
+}
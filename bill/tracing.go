@@ -0,0 +1,109 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"encore.dev/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+	temporalotel "go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// tracer starts the spans of this service. It follows the global provider, so spans are only
+// recorded once setupTracing has installed one.
+var tracer = otel.Tracer("fees-api/bill")
+
+// setupTracing installs a provider exporting spans to the configured OTLP collector, and the W3C
+// trace context propagator. It returns a function flushing and stopping the exporter.
+func setupTracing(ctx context.Context, c TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !c.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(c.Endpoint)}
+	if c.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, so a collector that is down does not stop the service
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("fees-api"))),
+	)
+	otel.SetTracerProvider(provider)
+	logger.Info("Exporting traces", "endpoint", c.Endpoint)
+	return provider.Shutdown, nil
+}
+
+// validateTracingConfig checks the tracing settings when tracing is enabled
+func validateTracingConfig(c TracingConfig) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Endpoint == "" {
+		return errors.New("tracing endpoint is not set")
+	}
+	if c.SampleRatio <= 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample ratio must be in (0, 1], got %g", c.SampleRatio)
+	}
+	return nil
+}
+
+// newTracingInterceptor carries the span of the caller through Temporal headers, so workflow
+// and activity spans join the trace of the request that started or signaled the workflow
+func newTracingInterceptor(t trace.Tracer) (interceptor.Interceptor, error) {
+	return temporalotel.NewTracingInterceptor(temporalotel.TracerOptions{Tracer: t})
+}
+
+// TracingMiddleware starts a server span for every API call, continuing the trace of the caller
+// when the request carries a W3C traceparent header
+//
+//encore:middleware target=all
+func TracingMiddleware(req middleware.Request, next middleware.Next) middleware.Response {
+	data := req.Data()
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(data.Headers))
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(data.Method),
+		attribute.String("encore.endpoint", data.Service+"."+data.Endpoint),
+	}
+	if id := billPathID(data); id != "" {
+		attrs = append(attrs, attribute.String("bill.id", id))
+	}
+	ctx, span := tracer.Start(ctx, data.Service+"."+data.Endpoint,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	resp := next(req.WithContext(ctx))
+	if resp.Err != nil {
+		span.RecordError(resp.Err)
+		span.SetStatus(codes.Error, resp.Err.Error())
+	}
+	return resp
+}
+
+// startDBSpan starts a span around a repository call. Errors are recorded on the API, workflow
+// or activity span around it.
+func startDBSpan(ctx context.Context, operation, billID string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{semconv.DBSystemPostgreSQL, semconv.DBOperation(operation)}
+	if billID != "" {
+		attrs = append(attrs, attribute.String("bill.id", billID))
+	}
+	return tracer.Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,99 @@
+package bill
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/worker"
+)
+
+func TestBillWorkflowPropagatesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing, err := newTracingInterceptor(provider.Tracer("test"))
+	if err != nil {
+		t.Fatalf("newTracingInterceptor() error = %v", err)
+	}
+
+	w := newBillWorkflowTest(t)
+	w.env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{tracing}})
+	w.signal(time.Minute, w.send("add-item", AddItemSignal{ItemID: testItemID, Amount: 500, Description: "Consulting"}))
+	w.signal(2*time.Minute, w.send("close-bill", nil))
+	w.execute()
+	w.requireCompleted()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	run, ok := spans["RunWorkflow:BillWorkflow"]
+	if !ok {
+		t.Fatalf("no workflow span among %v", spanNames(recorder.Ended()))
+	}
+	// A signal continues the trace of its sender, which the test environment does not have
+	if _, ok := spans["HandleSignal:add-item"]; !ok {
+		t.Errorf("no signal span among %v", spanNames(recorder.Ended()))
+	}
+	for _, name := range []string{"StartActivity:AddLineItemsActivity", "RunActivity:AddLineItemsActivity", "StartActivity:FinalizeBillActivity"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span among %v", name, spanNames(recorder.Ended()))
+			continue
+		}
+		if s.SpanContext().TraceID() != run.SpanContext().TraceID() {
+			t.Errorf("%s is in trace %s, want the workflow's trace %s", name, s.SpanContext().TraceID(), run.SpanContext().TraceID())
+		}
+	}
+	if s, ok := spans["RunActivity:AddLineItemsActivity"]; ok && s.Parent().SpanID() != spans["StartActivity:AddLineItemsActivity"].SpanContext().SpanID() {
+		t.Error("activity span is not a child of the span that scheduled it")
+	}
+}
+
+func TestStartDBSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "RunActivity:AddLineItemActivity")
+
+	// The package tracer records nothing until a provider is installed; the span context still flows
+	ctx, span := startDBSpan(ctx, "InsertLineItemAndUpdateTotal", testBillID)
+	span.End()
+	parent.End()
+
+	if got := trace.SpanContextFromContext(ctx); got.TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("repository context is in trace %s, want the activity's trace %s", got.TraceID(), parent.SpanContext().TraceID())
+	}
+}
+
+func TestValidateTracingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TracingConfig
+		wantErr bool
+	}{
+		{"disabled", TracingConfig{}, false},
+		{"enabled", TracingConfig{Enabled: true, Endpoint: "localhost:4317", SampleRatio: 0.25}, false},
+		{"no endpoint", TracingConfig{Enabled: true, SampleRatio: 1}, true},
+		{"no samples", TracingConfig{Enabled: true, Endpoint: "localhost:4317"}, true},
+		{"ratio above 1", TracingConfig{Enabled: true, Endpoint: "localhost:4317", SampleRatio: 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTracingConfig(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("validateTracingConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name()
+	}
+	return names
+}
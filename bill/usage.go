@@ -0,0 +1,267 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fees-api/money"
+	"fees-api/pricing"
+
+	"encore.dev/beta/errs"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxUsageEventsPerRequest bounds the size of a single ingestion batch
+	maxUsageEventsPerRequest = 1000
+	maxAccountIDLength       = 100
+	maxMeterKeyLength        = 100
+	maxIdempotencyKeyLength  = 200
+	// maxUsageClockSkew is how far in the future an event timestamp may be
+	maxUsageClockSkew = 5 * time.Minute
+)
+
+// usageItemNamespace derives stable line item IDs for usage charges so retried activities stay idempotent
+var usageItemNamespace = uuid.MustParse("6f0f5c7e-3b7d-4f5a-9c53-2a1f4f8e9d10")
+
+type UsageEvent struct {
+	AccountID      string    `json:"account_id"`
+	Meter          string    `json:"meter"`
+	Quantity       int64     `json:"quantity"`
+	Timestamp      time.Time `json:"timestamp"`
+	IdempotencyKey string    `json:"idempotency_key"`
+}
+
+type IngestUsageRequest struct {
+	Events []UsageEvent `json:"events"`
+}
+
+type IngestUsageResponse struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+}
+
+// IngestUsage records a batch of metered usage events. Events are deduplicated per account
+// by idempotency key, so clients can safely resend a batch after a timeout.
+//
+//encore:api public method=POST path=/usage/events
+func IngestUsage(ctx context.Context, req IngestUsageRequest) (*IngestUsageResponse, error) {
+	if len(req.Events) == 0 || len(req.Events) > maxUsageEventsPerRequest {
+		return nil, errs.WrapCode(errors.New("events must contain 1 to 1000 entries"), errs.InvalidArgument, "events must contain 1 to 1000 entries")
+	}
+
+	now := time.Now()
+	meters := make(map[string]bool)
+	for i := range req.Events {
+		e := &req.Events[i]
+		e.AccountID = strings.TrimSpace(e.AccountID)
+		e.Meter = strings.TrimSpace(e.Meter)
+		e.IdempotencyKey = strings.TrimSpace(e.IdempotencyKey)
+
+		if err := validateUsageEvent(*e, now); err != nil {
+			msg := fmt.Sprintf("events[%d]: %v", i, err)
+			return nil, errs.WrapCode(errors.New(msg), errs.InvalidArgument, msg)
+		}
+		meters[e.Meter] = true
+	}
+
+	unknown, err := UnknownMeters(ctx, meters)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to look up meters")
+	}
+	if len(unknown) > 0 {
+		msg := "unknown meters: " + strings.Join(unknown, ", ")
+		return nil, errs.WrapCode(errors.New(msg), errs.InvalidArgument, msg)
+	}
+
+	accepted, err := InsertUsageEvents(ctx, req.Events, now)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to record usage events")
+	}
+
+	return &IngestUsageResponse{
+		Accepted:   accepted,
+		Duplicates: len(req.Events) - accepted,
+	}, nil
+}
+
+// validateUsageEvent checks a single event; fields must already be trimmed
+func validateUsageEvent(e UsageEvent, now time.Time) error {
+	if e.AccountID == "" || len(e.AccountID) > maxAccountIDLength {
+		return errors.New("account_id required and max 100 chars")
+	}
+	if e.Meter == "" || len(e.Meter) > maxMeterKeyLength {
+		return errors.New("meter required and max 100 chars")
+	}
+	if e.Quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if e.Timestamp.IsZero() {
+		return errors.New("timestamp is required")
+	}
+	if e.Timestamp.After(now.Add(maxUsageClockSkew)) {
+		return errors.New("timestamp is in the future")
+	}
+	if e.IdempotencyKey == "" || len(e.IdempotencyKey) > maxIdempotencyKeyLength {
+		return errors.New("idempotency_key required and max 200 chars")
+	}
+	return nil
+}
+
+// Meter prices one kind of usage in one currency. UnitPrice carries the currency and, for
+// per_unit, flat and package models, the amount; tiered models take their rates from Tiers.
+type Meter struct {
+	Key         string         `json:"key"`
+	Description string         `json:"description"`
+	Model       pricing.Model  `json:"model"`
+	UnitPrice   money.Money    `json:"unit_price"`
+	PackageSize int64          `json:"package_size,omitempty"`
+	Tiers       []pricing.Tier `json:"tiers,omitempty"`
+}
+
+// definition returns the pricing model of a meter
+func (m *Meter) definition() pricing.Price {
+	return pricing.Price{
+		Model:       m.Model,
+		Currency:    m.UnitPrice.Currency,
+		UnitAmount:  m.UnitPrice.Amount,
+		PackageSize: m.PackageSize,
+		Tiers:       m.Tiers,
+	}
+}
+
+type UpsertMeterRequest struct {
+	Key         string         `json:"key"`
+	Description string         `json:"description"`
+	Currency    money.Currency `json:"currency"`
+	// Model defaults to per_unit
+	Model       pricing.Model  `json:"model,omitempty"`
+	UnitAmount  int64          `json:"unit_amount,omitempty"`
+	PackageSize int64          `json:"package_size,omitempty"`
+	Tiers       []pricing.Tier `json:"tiers,omitempty"`
+}
+
+type ListMetersResponse struct {
+	Meters []*Meter `json:"meters"`
+}
+
+// UpsertMeterAPI creates or reprices a meter in one currency. Requires the admin role.
+//
+//encore:api auth method=PUT path=/meters
+func UpsertMeterAPI(ctx context.Context, req UpsertMeterRequest) (*Meter, error) {
+	if err := requireAdmin(); err != nil {
+		return nil, err
+	}
+
+	req.Key = strings.TrimSpace(req.Key)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Key == "" || len(req.Key) > maxMeterKeyLength {
+		return nil, errs.WrapCode(errors.New("key required and max 100 chars"), errs.InvalidArgument, "key required and max 100 chars")
+	}
+	if len(req.Description) == 0 || len(req.Description) > 500 {
+		return nil, errs.WrapCode(errors.New("description required and max 500 chars"), errs.InvalidArgument, "description required and max 500 chars")
+	}
+	if req.Model == "" {
+		req.Model = pricing.PerUnit
+	}
+
+	m := &Meter{
+		Key:         req.Key,
+		Description: req.Description,
+		Model:       req.Model,
+		UnitPrice:   money.Money{Amount: req.UnitAmount, Currency: req.Currency},
+		PackageSize: req.PackageSize,
+		Tiers:       req.Tiers,
+	}
+	if err := m.definition().Validate(); err != nil {
+		return nil, errs.WrapCode(err, errs.InvalidArgument, err.Error())
+	}
+	if err := UpsertMeter(ctx, m); err != nil {
+		return nil, errs.Wrap(err, "failed to save meter")
+	}
+	return m, nil
+}
+
+//encore:api public method=GET path=/meters
+func ListMetersAPI(ctx context.Context) (*ListMetersResponse, error) {
+	meters, err := ListMeters(ctx)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to list meters")
+	}
+	return &ListMetersResponse{Meters: meters}, nil
+}
+
+type CollectUsageInput struct {
+	BillID    string
+	AccountID string
+	Currency  money.Currency
+	Until     time.Time
+}
+
+// MeteredUsage is the usage of one meter claimed by a bill, with the meter's price in the bill currency
+type MeteredUsage struct {
+	Meter       string
+	Description string
+	Quantity    int64
+	Price       pricing.Price
+}
+
+// CollectUsageActivity claims the account's unbilled usage up to input.Until for the bill and
+// looks up each meter's price. Usage of meters with no price in the bill's currency is not
+// claimed. Retries return the same usage; the workflow prices it.
+func CollectUsageActivity(ctx context.Context, input CollectUsageInput) ([]MeteredUsage, error) {
+	meters, err := ListMeters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	priced := metersPricedIn(meters, input.Currency)
+	keys := make([]string, 0, len(priced))
+	for key := range priced {
+		keys = append(keys, key)
+	}
+
+	aggregates, err := ClaimUsage(ctx, input.BillID, input.AccountID, keys, input.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]MeteredUsage, 0, len(aggregates))
+	for _, agg := range aggregates {
+		m := priced[agg.Meter]
+		usage = append(usage, MeteredUsage{
+			Meter:       agg.Meter,
+			Description: m.Description,
+			Quantity:    agg.Quantity,
+			Price:       m.definition(),
+		})
+	}
+	return usage, nil
+}
+
+// metersPricedIn returns the meters that have a price in currency, by key
+func metersPricedIn(meters []*Meter, currency money.Currency) map[string]*Meter {
+	priced := make(map[string]*Meter)
+	for _, m := range meters {
+		if m.UnitPrice.Currency == currency {
+			priced[m.Key] = m
+		}
+	}
+	return priced
+}
+
+// usageDescription describes one line of a usage charge, e.g. "API calls (units 1-1000): 1000 × $0.10"
+func usageDescription(meter string, line pricing.Line) string {
+	if line.Description != "" {
+		meter = fmt.Sprintf("%s (%s)", meter, line.Description)
+	}
+	return fmt.Sprintf("%s: %d × %s", meter, line.Quantity, line.UnitAmount.Format())
+}
+
+// usageItemID is stable for a bill, meter, charge line and close time
+func usageItemID(billID, meter string, line int, until time.Time) string {
+	name := fmt.Sprintf("%s/%s/%d/%d", billID, meter, line, until.UnixNano())
+	return uuid.NewSHA1(usageItemNamespace, []byte(name)).String()
+}
@@ -0,0 +1,268 @@
+package bill
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"fees-api/money"
+
+	"encore.dev/storage/sqldb"
+)
+
+var ErrMeterNotFound = errors.New("meter not found")
+
+// UsageAggregate is the total quantity of one meter claimed by a bill
+type UsageAggregate struct {
+	Meter    string
+	Quantity int64
+}
+
+// InsertUsageEvents stores a batch of usage events in a single statement, skipping any whose
+// (account_id, idempotency_key) has been seen before. It returns the number of new events.
+func InsertUsageEvents(ctx context.Context, events []UsageEvent, receivedAt time.Time) (int, error) {
+	ctx, span := startDBSpan(ctx, "InsertUsageEvents", "")
+	defer span.End()
+
+	var (
+		accounts   = make([]string, len(events))
+		keys       = make([]string, len(events))
+		meters     = make([]string, len(events))
+		quantities = make([]int64, len(events))
+		timestamps = make([]time.Time, len(events))
+	)
+	for i, e := range events {
+		accounts[i] = e.AccountID
+		keys[i] = e.IdempotencyKey
+		meters[i] = e.Meter
+		quantities[i] = e.Quantity
+		timestamps[i] = e.Timestamp
+	}
+
+	result, err := db.Exec(ctx, `
+		INSERT INTO usage_events (account_id, idempotency_key, meter, quantity, occurred_at, received_at)
+		SELECT account_id, idempotency_key, meter, quantity, occurred_at, $6
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::bigint[], $5::timestamp[])
+			AS e(account_id, idempotency_key, meter, quantity, occurred_at)
+		ON CONFLICT (account_id, idempotency_key) DO NOTHING
+	`, accounts, keys, meters, quantities, timestamps, receivedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert %d usage events: %w", len(events), err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// ClaimUsage assigns the account's unclaimed events of the given meters that occurred before until
+// to the bill and returns the quantity per meter of every event the bill has claimed but not yet
+// billed. Only meters priced in the bill's currency are passed, so events the bill cannot price
+// are left for a bill that can; events of other meters claimed by an earlier close are released.
+// Claimed events are only marked billed in the transaction that inserts their line items, see
+// markUsageBilledTx, so events claimed by a close that failed are billed by the next one.
+func ClaimUsage(ctx context.Context, billID, accountID string, meters []string, until time.Time) ([]UsageAggregate, error) {
+	ctx, span := startDBSpan(ctx, "ClaimUsage", billID)
+	defer span.End()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction for bill %s: %w", billID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE usage_events SET bill_id = NULL
+		WHERE bill_id = $1 AND billed_at IS NULL AND meter <> ALL($2::text[])
+	`, billID, meters); err != nil {
+		return nil, fmt.Errorf("failed to release usage for bill %s: %w", billID, err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE usage_events SET bill_id = $1
+		WHERE account_id = $3 AND bill_id IS NULL AND occurred_at < $2 AND meter = ANY($4::text[])
+	`, billID, until, accountID, meters); err != nil {
+		return nil, fmt.Errorf("failed to claim usage for bill %s: %w", billID, err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT meter, SUM(quantity)
+		FROM usage_events
+		WHERE bill_id = $1 AND billed_at IS NULL
+		GROUP BY meter
+		ORDER BY meter
+	`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage for bill %s: %w", billID, err)
+	}
+	defer rows.Close()
+
+	var aggregates []UsageAggregate
+	for rows.Next() {
+		var agg UsageAggregate
+		if err := rows.Scan(&agg.Meter, &agg.Quantity); err != nil {
+			return nil, err
+		}
+		aggregates = append(aggregates, agg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return aggregates, nil
+}
+
+// markUsageBilledTx marks the usage the bill has claimed but not yet billed as billed at billedAt
+func markUsageBilledTx(ctx context.Context, tx *sqldb.Tx, billID string, billedAt time.Time) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE usage_events SET billed_at = $2
+		WHERE bill_id = $1 AND billed_at IS NULL
+	`, billID, billedAt); err != nil {
+		return fmt.Errorf("failed to mark usage billed for bill %s: %w", billID, err)
+	}
+	return nil
+}
+
+// UpsertMeter creates a meter price or replaces the existing one for the same key and currency
+func UpsertMeter(ctx context.Context, m *Meter) error {
+	ctx, span := startDBSpan(ctx, "UpsertMeter", "")
+	defer span.End()
+
+	var tiers []byte
+	if len(m.Tiers) > 0 {
+		var err error
+		if tiers, err = json.Marshal(m.Tiers); err != nil {
+			return fmt.Errorf("failed to encode tiers for meter %s: %w", m.Key, err)
+		}
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO meters (key, currency, description, model, unit_amount, package_size, tiers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (key, currency) DO UPDATE
+		SET description = EXCLUDED.description,
+		    model = EXCLUDED.model,
+		    unit_amount = EXCLUDED.unit_amount,
+		    package_size = EXCLUDED.package_size,
+		    tiers = EXCLUDED.tiers
+	`, m.Key, m.UnitPrice.Currency, m.Description, m.Model, m.UnitPrice.Amount, m.PackageSize, tiers)
+	if err != nil {
+		return fmt.Errorf("failed to upsert meter %s: %w", m.Key, err)
+	}
+	return nil
+}
+
+// GetMeter returns the price of a meter in the given currency
+func GetMeter(ctx context.Context, key string, currency money.Currency) (*Meter, error) {
+	ctx, span := startDBSpan(ctx, "GetMeter", "")
+	defer span.End()
+
+	row := db.QueryRow(ctx, `
+		SELECT key, description, model, unit_amount, currency, COALESCE(package_size, 0), tiers
+		FROM meters
+		WHERE key = $1 AND currency = $2
+	`, key, currency)
+
+	m, err := scanMeter(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("meter %s has no %s price: %w", key, currency, ErrMeterNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan meter %s: %w", key, err)
+	}
+	return m, nil
+}
+
+func ListMeters(ctx context.Context) ([]*Meter, error) {
+	ctx, span := startDBSpan(ctx, "ListMeters", "")
+	defer span.End()
+
+	rows, err := db.Query(ctx, `
+		SELECT key, description, model, unit_amount, currency, COALESCE(package_size, 0), tiers
+		FROM meters
+		ORDER BY key, currency
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var meters []*Meter
+	for rows.Next() {
+		m, err := scanMeter(rows)
+		if err != nil {
+			return nil, err
+		}
+		meters = append(meters, m)
+	}
+	return meters, rows.Err()
+}
+
+// UnknownMeters returns, sorted, the keys that have no price in any currency
+func UnknownMeters(ctx context.Context, keys map[string]bool) ([]string, error) {
+	ctx, span := startDBSpan(ctx, "UnknownMeters", "")
+	defer span.End()
+
+	requested := make([]string, 0, len(keys))
+	for k := range keys {
+		requested = append(requested, k)
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT key FROM meters WHERE key = ANY($1::text[])
+	`, requested)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]bool)
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		known[k] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for _, k := range requested {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func scanMeter(row interface{ Scan(...interface{}) error }) (*Meter, error) {
+	var (
+		m           Meter
+		unitAmount  int64
+		currencyStr string
+		tiers       []byte
+	)
+	if err := row.Scan(&m.Key, &m.Description, &m.Model, &unitAmount, &currencyStr, &m.PackageSize, &tiers); err != nil {
+		return nil, err
+	}
+	if len(tiers) > 0 {
+		if err := json.Unmarshal(tiers, &m.Tiers); err != nil {
+			return nil, fmt.Errorf("failed to decode tiers for meter %s: %w", m.Key, err)
+		}
+	}
+
+	price, err := money.NewMoney(unitAmount, money.Currency(currencyStr))
+	if err != nil {
+		return nil, err
+	}
+	m.UnitPrice = price
+	return &m, nil
+}
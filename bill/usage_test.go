@@ -0,0 +1,96 @@
+package bill
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fees-api/money"
+	"fees-api/pricing"
+)
+
+func TestValidateUsageEvent(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	valid := UsageEvent{
+		AccountID:      "acct_123",
+		Meter:          "api_calls",
+		Quantity:       10,
+		Timestamp:      now.Add(-time.Hour),
+		IdempotencyKey: "evt-1",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(e *UsageEvent)
+		wantErr bool
+	}{
+		{"valid event", func(e *UsageEvent) {}, false},
+		{"slightly in the future", func(e *UsageEvent) { e.Timestamp = now.Add(time.Minute) }, false},
+		{"missing account", func(e *UsageEvent) { e.AccountID = "" }, true},
+		{"account too long", func(e *UsageEvent) { e.AccountID = strings.Repeat("a", 101) }, true},
+		{"missing meter", func(e *UsageEvent) { e.Meter = "" }, true},
+		{"zero quantity", func(e *UsageEvent) { e.Quantity = 0 }, true},
+		{"negative quantity", func(e *UsageEvent) { e.Quantity = -5 }, true},
+		{"missing timestamp", func(e *UsageEvent) { e.Timestamp = time.Time{} }, true},
+		{"far in the future", func(e *UsageEvent) { e.Timestamp = now.Add(time.Hour) }, true},
+		{"missing idempotency key", func(e *UsageEvent) { e.IdempotencyKey = "" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := valid
+			tt.modify(&e)
+			err := validateUsageEvent(e, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateUsageEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsageItemID(t *testing.T) {
+	until := time.Date(2026, 5, 31, 23, 59, 59, 0, time.UTC)
+	billID := "1b4e28ba-2fa1-41d2-883f-0016d3cca427"
+
+	first := usageItemID(billID, "api_calls", 0, until)
+	if err := validateItemID(first); err != nil {
+		t.Fatalf("usageItemID() = %q is not a valid item ID: %v", first, err)
+	}
+	if again := usageItemID(billID, "api_calls", 0, until); again != first {
+		t.Errorf("usageItemID() not stable: %q then %q", first, again)
+	}
+	if other := usageItemID(billID, "storage_gb_hours", 0, until); other == first {
+		t.Error("usageItemID() returned the same ID for different meters")
+	}
+	if tier := usageItemID(billID, "api_calls", 1, until); tier == first {
+		t.Error("usageItemID() returned the same ID for different charge lines")
+	}
+	if later := usageItemID(billID, "api_calls", 0, until.Add(time.Second)); later == first {
+		t.Error("usageItemID() returned the same ID for different close times")
+	}
+}
+
+func TestMetersPricedIn(t *testing.T) {
+	meters := []*Meter{
+		{Key: "api_calls", Description: "API calls", Model: pricing.PerUnit, UnitPrice: money.Money{Amount: 2, Currency: money.USD}},
+		{Key: "api_calls", Description: "API calls", Model: pricing.PerUnit, UnitPrice: money.Money{Amount: 3, Currency: money.GEL}},
+		{Key: "storage_gb_hours", Description: "Storage", Model: pricing.PerUnit, UnitPrice: money.Money{Amount: 1, Currency: money.USD}},
+	}
+
+	// A bill in a second currency only claims the meters priced in it, at that price
+	gel := metersPricedIn(meters, money.GEL)
+	if len(gel) != 1 || gel["api_calls"] == nil {
+		t.Fatalf("metersPricedIn(GEL) = %v, want api_calls only", gel)
+	}
+	if price := gel["api_calls"].definition(); price.Currency != money.GEL || price.UnitAmount != 3 {
+		t.Errorf("GEL api_calls price = %+v, want 3 GEL", price)
+	}
+
+	usd := metersPricedIn(meters, money.USD)
+	if len(usd) != 2 || usd["api_calls"].UnitPrice.Amount != 2 || usd["storage_gb_hours"] == nil {
+		t.Errorf("metersPricedIn(USD) = %v, want both meters at their USD prices", usd)
+	}
+	if gbp := metersPricedIn(meters, money.Currency("GBP")); len(gbp) != 0 {
+		t.Errorf("metersPricedIn(GBP) = %v, want none", gbp)
+	}
+}
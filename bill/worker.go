@@ -1,31 +1,211 @@
 package bill
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"encore.dev/shutdown"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 )
 
-const taskQueue = "BILLING_TASK_QUEUE"
-
 // initService initializes the Temporal worker when the Encore service starts
 func initService() (*Service, error) {
-	log.Println("Initializing Temporal workflow service...")
+	logger.Info("Initializing Temporal workflow service")
+
+	if err := validateInvoiceNumberFormat(cfg.Invoice.NumberFormat); err != nil {
+		return nil, err
+	}
+	if cfg.Reconciliation.BatchSize <= 0 {
+		return nil, fmt.Errorf("reconciliation batch size must be positive, got %d", cfg.Reconciliation.BatchSize)
+	}
+	if cfg.Ledger.TaxBasisPoints < 0 {
+		return nil, fmt.Errorf("tax rate cannot be negative, got %d basis points", cfg.Ledger.TaxBasisPoints)
+	}
+	if err := validateTemporalConfig(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateTracingConfig(cfg.Tracing); err != nil {
+		return nil, err
+	}
+	if err := validateMetricsConfig(cfg.Metrics); err != nil {
+		return nil, err
+	}
+	stopTracing, err := setupTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	stopMetrics, err := setupMetrics(context.Background(), cfg.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	// The worker starts once Temporal is reachable, which may be long after the service
+	temporalManager.OnConnect(func(c client.Client) {
+		startWorker(c)
+		background.Go(sweepOrphans)
+	})
+	if cfg.Outbox.Enabled {
+		background.Go(runOutbox)
+	}
+	background.Go(trackOpenBills)
+	GetTemporalClient()
+
+	return &Service{stopTracing: stopTracing, stopMetrics: stopMetrics}, nil
+}
+
+// Shutdown stops the worker from taking new tasks and lets running activities finish while the
+// database is still open, so no line item is cut off part way. The drain is bounded by the
+// worker's stop timeout and the end of the shutdown window, not by ForceCloseTasks: Encore
+// cancels that as soon as no API call is in flight, which says nothing about activities. The
+// Temporal client is closed once no API call can use it any more.
+func (s *Service) Shutdown(p shutdown.Progress) error {
+	logger.Info("Stopping Temporal worker")
+	err := background.Stop(p.ForceShutdown)
+	if err != nil {
+		logger.Warn("Temporal worker did not drain in time", "error", err)
+	}
+
+	select {
+	case <-p.OutstandingRequests.Done():
+	case <-p.ForceCloseTasks.Done():
+	}
+	temporalManager.Close()
+	logger.Info("Temporal client closed")
 
-	w := worker.New(GetTemporalClient(), taskQueue, worker.Options{})
+	// Spans and metrics of the drained activities are flushed last
+	if stopErr := s.stopTracing(p.ForceShutdown); stopErr != nil {
+		logger.Error("Failed to flush traces", "error", stopErr)
+	}
+	if stopErr := s.stopMetrics(p.ForceShutdown); stopErr != nil {
+		logger.Error("Failed to flush metrics", "error", stopErr)
+	}
+	return err
+}
+
+// startWorker registers the workflows and activities and starts polling the task queue
+func startWorker(c client.Client) {
+	w := worker.New(c, cfg.Temporal.TaskQueue, worker.Options{
+		MaxConcurrentActivityExecutionSize:     cfg.Worker.MaxConcurrentActivities,
+		MaxConcurrentWorkflowTaskExecutionSize: cfg.Worker.MaxConcurrentWorkflowTasks,
+		MaxConcurrentActivityTaskPollers:       cfg.Worker.ActivityPollers,
+		MaxConcurrentWorkflowTaskPollers:       cfg.Worker.WorkflowTaskPollers,
+		WorkerStopTimeout:                      time.Duration(cfg.Worker.StopTimeoutSeconds) * time.Second,
+		Interceptors:                           []interceptor.WorkerInterceptor{&activityMetrics{}},
+	})
 
 	// Register your workflow and activities with the worker
 	w.RegisterWorkflow(BillWorkflow)
+	w.RegisterActivity(CreateBillActivity)
 	w.RegisterActivity(FinalizeBillActivity)
 	w.RegisterActivity(AddLineItemActivity)
+	w.RegisterActivity(AddLineItemsActivity)
+	w.RegisterActivity(ChangeBillStatusActivity)
+	w.RegisterActivity(RemoveLineItemActivity)
+	w.RegisterActivity(AmendLineItemActivity)
+	w.RegisterActivity(RepairBillTotalActivity)
+	w.RegisterActivity(CollectUsageActivity)
+	w.RegisterActivity(GetPriceActivity)
+	w.RegisterActivity(ListFeeRulesActivity)
+	w.RegisterActivity(ReplaceFeeItemsActivity)
+	w.RegisterActivity(DeadLetterActivity)
+
+	w.RegisterWorkflow(ReconciliationWorkflow)
+	w.RegisterActivity(StartReconciliationRunActivity)
+	w.RegisterActivity(ReconcileBillsActivity)
+	w.RegisterActivity(FinishReconciliationRunActivity)
+
+	logger.Info("Starting Temporal worker", "taskQueue", cfg.Temporal.TaskQueue)
+	if err := background.Start(w); err != nil {
+		logger.Error("Failed to start Temporal worker", "taskQueue", cfg.Temporal.TaskQueue, "error", err)
+	}
+}
+
+// background runs the worker and the loops the service keeps going, until Shutdown
+var background = newLifecycle()
+
+// runner is the part of a Temporal worker the lifecycle starts and stops
+type runner interface {
+	Start() error
+	Stop()
+}
+
+// lifecycle starts background work and stops all of it at shutdown
+type lifecycle struct {
+	ctx    context.Context // canceled when stopping begins
+	cancel context.CancelFunc
+	loops  sync.WaitGroup
+
+	mu       sync.Mutex
+	stopping bool
+	runners  []runner
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Start starts r, unless the lifecycle is already stopping
+func (l *lifecycle) Start(r runner) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopping {
+		return errors.New("service is shutting down")
+	}
+	if err := r.Start(); err != nil {
+		return err
+	}
+	l.runners = append(l.runners, r)
+	return nil
+}
+
+// Go runs loop in the background with a context that is canceled when stopping begins
+func (l *lifecycle) Go(loop func(ctx context.Context)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopping {
+		return
+	}
+	l.loops.Add(1)
+	go func() {
+		defer l.loops.Done()
+		loop(l.ctx)
+	}()
+}
+
+// Stop stops every runner and loop and waits for them, or until force is done. A Temporal worker
+// stops polling at once and waits up to its stop timeout for the activities it is running.
+func (l *lifecycle) Stop(force context.Context) error {
+	l.mu.Lock()
+	l.stopping = true
+	runners := l.runners
+	l.mu.Unlock()
+	l.cancel()
 
-	// Start listening to the task queue in a separate goroutine
+	done := make(chan struct{})
 	go func() {
-		log.Println("Starting Temporal worker on task queue BILLING_TASK_QUEUE...")
-		if err := w.Run(worker.InterruptCh()); err != nil {
-			log.Printf("Temporal worker error: %v", err)
+		var wg sync.WaitGroup
+		for _, r := range runners {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.Stop()
+			}()
 		}
+		wg.Wait()
+		l.loops.Wait()
+		close(done)
 	}()
 
-	return &Service{}, nil
+	select {
+	case <-done:
+		return nil
+	case <-force.Done():
+		return fmt.Errorf("stopped waiting for running activities: %w", force.Err())
+	}
 }
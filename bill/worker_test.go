@@ -0,0 +1,265 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"encore.dev/shutdown"
+	"go.temporal.io/sdk/client"
+)
+
+// memoryBill applies line items the way InsertLineItemAndUpdateTotal does: the item and the new
+// total are committed together, or not at all when the activity is canceled first
+type memoryBill struct {
+	mu    sync.Mutex
+	items map[string]int64
+	total int64
+}
+
+func (b *memoryBill) addItem(ctx context.Context, itemID string, amount int64, work <-chan struct{}) error {
+	// The transaction is open while the activity works
+	select {
+	case <-work:
+	case <-ctx.Done():
+		return ctx.Err() // rolled back
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.items[itemID]; ok {
+		return nil
+	}
+	b.items[itemID] = amount
+	b.total += amount
+	return nil
+}
+
+// consistent reports whether the total is the sum of the items
+func (b *memoryBill) consistent() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var sum int64
+	for _, amount := range b.items {
+		sum += amount
+	}
+	return sum == b.total
+}
+
+// fakeWorker runs activities like a Temporal worker: Stop stops taking tasks, waits up to
+// stopTimeout for running activities and then cancels them
+type fakeWorker struct {
+	stopTimeout time.Duration
+
+	mu       sync.Mutex
+	started  bool
+	stopped  bool
+	running  sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	finished []error
+}
+
+func newFakeWorker(stopTimeout time.Duration) *fakeWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &fakeWorker{stopTimeout: stopTimeout, ctx: ctx, cancel: cancel}
+}
+
+func (w *fakeWorker) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.started = true
+	return nil
+}
+
+func (w *fakeWorker) Stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(w.stopTimeout):
+		w.cancel()
+		<-done
+	}
+}
+
+// run starts an activity unless the worker has stopped taking tasks
+func (w *fakeWorker) run(activity func(ctx context.Context) error) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return false
+	}
+	w.running.Add(1)
+	go func() {
+		defer w.running.Done()
+		err := activity(w.ctx)
+		w.mu.Lock()
+		w.finished = append(w.finished, err)
+		w.mu.Unlock()
+	}()
+	return true
+}
+
+func TestLifecycleDrainsRunningActivities(t *testing.T) {
+	l := newLifecycle()
+	w := newFakeWorker(time.Second)
+	if err := l.Start(w); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	var loopStopped bool
+	l.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		loopStopped = true
+	})
+
+	bill := &memoryBill{items: map[string]int64{}}
+	work := make(chan struct{})
+	w.run(func(ctx context.Context) error { return bill.addItem(ctx, testItemID, 500, work) })
+
+	stopped := make(chan error)
+	go func() { stopped <- l.Stop(context.Background()) }()
+
+	// The activity still running holds up shutdown, while new tasks are refused
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop() returned %v before the running activity finished", err)
+	default:
+	}
+	if w.run(func(ctx context.Context) error { return bill.addItem(ctx, testItemID2, 700, nil) }) {
+		t.Error("worker took a new activity while stopping")
+	}
+
+	close(work)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if len(w.finished) != 1 || w.finished[0] != nil {
+		t.Errorf("activities finished with %v, want one success", w.finished)
+	}
+	if bill.items[testItemID] != 500 || bill.total != 500 || !bill.consistent() {
+		t.Errorf("bill has items %v and total %d, want the drained item applied", bill.items, bill.total)
+	}
+	if !loopStopped {
+		t.Error("background loop was not stopped")
+	}
+	if err := l.Start(newFakeWorker(0)); err == nil {
+		t.Error("Start() after Stop succeeded")
+	}
+}
+
+func TestLifecycleStopTimeoutLeavesNoPartialItems(t *testing.T) {
+	l := newLifecycle()
+	w := newFakeWorker(10 * time.Millisecond)
+	if err := l.Start(w); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	bill := &memoryBill{items: map[string]int64{"earlier": 300}, total: 300}
+	w.run(func(ctx context.Context) error { return bill.addItem(ctx, testItemID, 500, nil) })
+
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if len(w.finished) != 1 || !errors.Is(w.finished[0], context.Canceled) {
+		t.Errorf("activities finished with %v, want one canceled", w.finished)
+	}
+	// The canceled activity is retried elsewhere; nothing of it was applied here
+	if _, ok := bill.items[testItemID]; ok || bill.total != 300 || !bill.consistent() {
+		t.Errorf("bill has items %v and total %d, want the canceled item left out", bill.items, bill.total)
+	}
+}
+
+func TestLifecycleStopForced(t *testing.T) {
+	l := newLifecycle()
+	w := newFakeWorker(time.Hour)
+	if err := l.Start(w); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	work := make(chan struct{})
+	defer close(work)
+	w.run(func(ctx context.Context) error {
+		<-work
+		return nil
+	})
+
+	force, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Stop(force); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop() took %v, want it bounded by the deadline", elapsed)
+	}
+}
+
+func TestServiceShutdownDrainsActivitiesWithoutRequests(t *testing.T) {
+	savedBackground, savedManager := background, temporalManager
+	defer func() { background, temporalManager = savedBackground, savedManager }()
+	background = newLifecycle()
+	temporalManager = newClientManager("localhost:7233", func() (client.Client, error) {
+		return nil, errors.New("not dialed in tests")
+	})
+
+	w := newFakeWorker(time.Second)
+	if err := background.Start(w); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	bill := &memoryBill{items: map[string]int64{}}
+	work := make(chan struct{})
+	w.run(func(ctx context.Context) error { return bill.addItem(ctx, testItemID, 500, work) })
+
+	// No API call is in flight, so Encore has already canceled ForceCloseTasks
+	idle, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &Service{
+		stopTracing: func(context.Context) error { return nil },
+		stopMetrics: func(context.Context) error { return nil },
+	}
+	stopped := make(chan error)
+	go func() {
+		stopped <- s.Shutdown(shutdown.Progress{
+			OutstandingRequests:       idle,
+			OutstandingPubSubMessages: idle,
+			OutstandingTasks:          idle,
+			ForceCloseTasks:           idle,
+			ForceShutdown:             context.Background(),
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-stopped:
+		t.Fatalf("Shutdown() returned %v before the running activity finished", err)
+	default:
+	}
+	if temporalManager.isClosed() {
+		t.Error("Temporal client closed while an activity was running")
+	}
+
+	close(work)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if len(w.finished) != 1 || w.finished[0] != nil {
+		t.Errorf("activities finished with %v, want one success", w.finished)
+	}
+	if bill.items[testItemID] != 500 || !bill.consistent() {
+		t.Errorf("bill has items %v and total %d, want the drained item applied", bill.items, bill.total)
+	}
+	if !temporalManager.isClosed() {
+		t.Error("Temporal client not closed after the drain")
+	}
+}
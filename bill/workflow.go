@@ -2,156 +2,860 @@ package bill
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"fees-api/money"
+	"fees-api/pricing"
 
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+// createdUpdate is the update Create sends to wait for the bill row to be inserted
+const createdUpdate = "created"
+
+// repairTotalUpdate is the update reconciliation sends to repair the total of a running bill
+const repairTotalUpdate = "repair-total"
+
+// createBillVersion gates the CreateBillActivity step, so histories of workflows started before
+// it existed still replay
+const createBillVersion = "create-bill-row"
+
+// reopenWindow is how long a closed bill can still be reopened before its workflow completes
+const reopenWindow = 7 * 24 * time.Hour
+
+// reopenWindowVersion gates the reopen window, so histories of workflows started before bills
+// could be reopened still replay; those workflows complete as soon as their bill is closed
+const reopenWindowVersion = "reopen-window"
+
+// feeRulesVersion gates fee rule evaluation, so histories of workflows started before fee rules
+// existed still replay; those workflows charge no fees
+const feeRulesVersion = "fee-rules"
+
+// batchLineItemsVersion gates inserting items in one transaction, so histories of workflows
+// started before it still replay; those workflows insert items one activity at a time
+const batchLineItemsVersion = "batch-line-items"
+
 type BillState struct {
-	BillID string
-	Total  money.Money
-	Closed bool
+	BillID    string
+	AccountID string
+	Total     money.Money
+	Status    Status
+	ClosedAt  time.Time
+}
+
+// HistoryLimits bound the event history of one workflow run. Once either limit is reached, or
+// Temporal suggests it, the run continues as new and carries its BillState over to the next run.
+// Workflows started before the limits existed receive zero limits and rely on the suggestion alone.
+type HistoryLimits struct {
+	MaxEvents int // 0 disables the check
+	MaxBytes  int // 0 disables the check
 }
 
-// BillWorkflow manages the lifecycle of a bill, handling item additions and bill closure.
+// defaultActivityRetry is the activity retry policy of workflows started before it was configurable
+var defaultActivityRetry = RetryConfig{
+	InitialIntervalSeconds: 1,
+	BackoffCoefficient:     2.0,
+	MaximumIntervalSeconds: 60,
+	MaximumAttempts:        5,
+}
+
+func activityRetryPolicy(retry *RetryConfig) *temporal.RetryPolicy {
+	if retry == nil {
+		return defaultActivityRetry.policy()
+	}
+	return retry.policy()
+}
+
+// reached reports whether the current run should hand over to a new one
+func (l HistoryLimits) reached(ctx workflow.Context) bool {
+	info := workflow.GetInfo(ctx)
+	return info.GetContinueAsNewSuggested() ||
+		(l.MaxEvents > 0 && info.GetCurrentHistoryLength() >= l.MaxEvents) ||
+		(l.MaxBytes > 0 && info.GetCurrentHistorySize() >= l.MaxBytes)
+}
+
+// BillWorkflow manages the lifecycle of a bill, handling item additions, closure, voiding and reopening.
 // It uses Temporal workflow patterns to ensure consistency and reliability.
-func BillWorkflow(ctx workflow.Context, billID string, currency money.Currency) error {
-	logger := workflow.GetLogger(ctx)
-	logger.Info("Starting bill workflow", "billID", billID, "currency", currency)
+// The first run inserts the bill row itself, so a bill exists exactly when its workflow was started.
+// A closed bill keeps its workflow alive for reopenWindow so that an admin can reopen it.
+// A run continues as new when its history reaches limits; carried is the state handed over by
+// the previous run and is nil for the first one. retry is the activity retry policy, nil for
+// defaultActivityRetry.
+func BillWorkflow(ctx workflow.Context, billID string, currency money.Currency, accountID string, limits HistoryLimits, carried *BillState, retry *RetryConfig) error {
+	logger := billLogger(ctx, &BillState{BillID: billID, AccountID: accountID})
 
-	total, err := money.NewMoney(0, currency)
-	if err != nil {
-		logger.Error("Failed to create initial money", "error", err)
+	var state BillState
+	if carried != nil {
+		state = *carried
+		logger.Info("Continuing bill workflow", "status", state.Status, "total", state.Total)
+	} else {
+		logger.Info("Starting bill workflow", "currency", currency)
+
+		total, err := money.NewMoney(0, currency)
+		if err != nil {
+			logger.Error("Failed to create initial money", "error", err)
+			return err
+		}
+
+		state = BillState{
+			BillID:    billID,
+			AccountID: accountID,
+			Total:     total,
+			Status:    Open,
+		}
+	}
+
+	// The reconciliation job compares this state with the database
+	if err := workflow.SetQueryHandler(ctx, "state", func() (BillState, error) {
+		return state, nil
+	}); err != nil {
 		return err
 	}
 
-	state := BillState{
-		BillID: billID,
-		Total:  total,
+	// Create waits on this update until the bill row exists
+	var (
+		bill      *Bill
+		created   bool
+		createErr error
+	)
+	if err := workflow.SetUpdateHandler(ctx, createdUpdate, func(ctx workflow.Context) (*Bill, error) {
+		if err := workflow.Await(ctx, func() bool { return created || createErr != nil }); err != nil {
+			return nil, err
+		}
+		return bill, createErr
+	}); err != nil {
+		return err
 	}
 
 	// Add retry policy for activities
-	retryPolicy := &temporal.RetryPolicy{
-		InitialInterval:    time.Second,
-		BackoffCoefficient: 2.0,
-		MaximumInterval:    time.Minute,
-		MaximumAttempts:    5,
-	}
 	activityOptions := workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute * 5,
-		RetryPolicy:         retryPolicy,
+		RetryPolicy:         activityRetryPolicy(retry),
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
 
+	// Reconciliation repairs the stored total through the workflow, so the total it holds follows
+	if err := workflow.SetUpdateHandler(ctx, repairTotalUpdate, func(ctx workflow.Context) (TotalRepair, error) {
+		var repair TotalRepair
+		err := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, activityOptions), RepairBillTotalActivity, state.BillID).Get(ctx, &repair)
+		if err != nil {
+			return TotalRepair{}, err
+		}
+		state.Total = repair.Total
+		return repair, nil
+	}); err != nil {
+		return err
+	}
+
+	// The first run inserts the bill row. Workflows started before this step existed were
+	// started after their row was inserted, and continued runs carry a bill that exists.
+	if carried == nil && workflow.GetVersion(ctx, createBillVersion, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+		err := workflow.ExecuteActivity(ctx, CreateBillActivity, CreateBillInput{
+			BillID:    billID,
+			AccountID: accountID,
+			Currency:  currency,
+			CreatedAt: workflow.Now(ctx), // Use workflow time for determinism
+		}).Get(ctx, &bill)
+		if err != nil {
+			logger.Error("Failed to create bill", "error", err)
+			createErr = err
+			// Let a waiting Create see the error before the workflow fails
+			_ = workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) })
+			return err
+		}
+	}
+	created = true
+
 	addItemCh := workflow.GetSignalChannel(ctx, "add-item")
+	addItemsCh := workflow.GetSignalChannel(ctx, "add-items")
 	closeCh := workflow.GetSignalChannel(ctx, "close-bill")
+	voidCh := workflow.GetSignalChannel(ctx, "void-bill")
+	reopenCh := workflow.GetSignalChannel(ctx, "reopen-bill")
+	removeItemCh := workflow.GetSignalChannel(ctx, "remove-item")
+	amendItemCh := workflow.GetSignalChannel(ctx, "amend-item")
+
+	// reopenTimer fires when a closed bill can no longer be reopened
+	var (
+		reopenTimer   workflow.Future
+		cancelReopen  workflow.CancelFunc
+		windowExpired bool
+	)
 
 	for {
+		if state.Status == Closed && reopenTimer == nil &&
+			workflow.GetVersion(ctx, reopenWindowVersion, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+			break
+		}
+
+		switch {
+		case state.Status == Closed && reopenTimer == nil:
+			// Measured from the close itself, so a continued run only waits out what is left
+			var timerCtx workflow.Context
+			timerCtx, cancelReopen = workflow.WithCancel(ctx)
+			reopenTimer = workflow.NewTimer(timerCtx, reopenWindow-workflow.Now(ctx).Sub(state.ClosedAt))
+		case state.Status == Open && reopenTimer != nil:
+			cancelReopen()
+			reopenTimer = nil
+		}
+
 		selector := workflow.NewSelector(ctx)
 
 		selector.AddReceive(addItemCh, func(c workflow.ReceiveChannel, more bool) {
 			var s AddItemSignal
 			c.Receive(ctx, &s)
 
-			// Enhanced signal validation
-			if err := validateAddItemSignal(s); err != nil {
-				workflow.GetLogger(ctx).Error("invalid add item signal", "error", err, "signal", s)
+			// Take every add item signal already waiting too, so that a burst of signals is
+			// inserted with one activity and one transaction instead of one per item
+			signals := []AddItemSignal{s}
+			for len(signals) < maxBatchItems {
+				var next AddItemSignal
+				if !c.ReceiveAsync(&next) {
+					break
+				}
+				signals = append(signals, next)
+			}
+
+			valid := make([]AddItemSignal, 0, len(signals))
+			for _, s := range signals {
+				// Enhanced signal validation
+				if err := validateAddItemSignal(s); err != nil {
+					deadLetter(ctx, &state, ReasonInvalid, err, s)
+					continue
+				}
+				valid = append(valid, s)
+			}
+			if len(valid) == 0 {
 				return
 			}
 
-			if state.Closed {
-				workflow.GetLogger(ctx).Warn("attempted to add item to closed bill", "billID", state.BillID)
+			if state.Status != Open {
+				deadLetter(ctx, &state, ReasonNotOpen, fmt.Errorf("bill is %s", state.Status), valid...)
 				return
 			}
 
-			itemMoney, err := money.NewMoney(s.Amount, state.Total.Currency)
-			if err != nil {
-				workflow.GetLogger(ctx).Error("failed to create item money", "err", err)
+			// Independent signals are added on their own merits: one that cannot be priced is skipped
+			if unapplied, err := addItems(ctx, &state, valid, false); err != nil {
+				deadLetter(ctx, &state, ReasonFailed, err, unapplied...)
+			}
+		})
+
+		selector.AddReceive(addItemsCh, func(c workflow.ReceiveChannel, more bool) {
+			var s AddItemsSignal
+			c.Receive(ctx, &s)
+
+			if err := validateAddItemsSignal(s); err != nil {
+				if len(s.Items) > 0 {
+					deadLetter(ctx, &state, ReasonInvalid, err, s.Items...)
+				}
+				return
+			}
+
+			if state.Status != Open {
+				deadLetter(ctx, &state, ReasonNotOpen, fmt.Errorf("bill is %s", state.Status), s.Items...)
 				return
 			}
 
-			// Use transactional activity that handles both line item insertion and total update atomically
-			err = workflow.ExecuteActivity(
+			if unapplied, err := addItems(ctx, &state, s.Items, true); err != nil {
+				deadLetter(ctx, &state, ReasonFailed, err, unapplied...)
+			}
+		})
+
+		selector.AddReceive(removeItemCh, func(c workflow.ReceiveChannel, more bool) {
+			var s RemoveItemSignal
+			c.Receive(ctx, &s)
+
+			if err := validateItemID(s.ItemID); err != nil {
+				logger.Error("invalid remove item signal", "error", err, "signal", s)
+				recordSignalRejected(ctx, "remove-item", string(ReasonInvalid), 1)
+				return
+			}
+
+			if state.Status != Open {
+				logger.Warn("attempted to remove item from bill that is not open", "status", state.Status)
+				recordSignalRejected(ctx, "remove-item", string(ReasonNotOpen), 1)
+				return
+			}
+
+			// The activity returns the bill total from the same transaction that removed the item
+			var newTotal money.Money
+			err := workflow.ExecuteActivity(
 				ctx,
-				AddLineItemActivity,
-				AddLineItemInput{
-					ItemID:      s.ItemID,
-					BillID:      state.BillID,
-					Amount:      itemMoney,
-					Description: s.Description,
-					CreatedAt:   workflow.Now(ctx), // Use workflow time for determinism
-				},
-			).Get(ctx, nil)
+				RemoveLineItemActivity,
+				RemoveLineItemInput{BillID: state.BillID, ItemID: s.ItemID},
+			).Get(ctx, &newTotal)
 			if err != nil {
-				workflow.GetLogger(ctx).Error("failed to add line item transactionally", "err", err)
+				logger.Error("failed to remove line item transactionally", "error", err)
+				recordSignalRejected(ctx, "remove-item", string(ReasonFailed), 1)
 				return
 			}
+			state.Total = newTotal
+		})
 
-			// Update workflow state after successful transactional activity
-			// Add the new item amount to the running total
-			newTotal, err := state.Total.Add(itemMoney)
+		selector.AddReceive(amendItemCh, func(c workflow.ReceiveChannel, more bool) {
+			var s AmendItemSignal
+			c.Receive(ctx, &s)
+
+			if err := validateAmendItemSignal(s); err != nil {
+				logger.Error("invalid amend item signal", "error", err, "signal", s)
+				recordSignalRejected(ctx, "amend-item", string(ReasonInvalid), 1)
+				return
+			}
+
+			if state.Status != Open {
+				logger.Warn("attempted to amend item on bill that is not open", "status", state.Status)
+				recordSignalRejected(ctx, "amend-item", string(ReasonNotOpen), 1)
+				return
+			}
+
+			input := AmendLineItemInput{BillID: state.BillID, ItemID: s.ItemID}
+			if s.Amount != nil {
+				itemMoney, err := money.NewMoney(*s.Amount, state.Total.Currency)
+				if err != nil {
+					logger.Error("failed to create item money", "error", err)
+					recordSignalRejected(ctx, "amend-item", string(ReasonInvalid), 1)
+					return
+				}
+				input.Amount = &itemMoney
+			}
+			if s.Description != nil {
+				description := strings.TrimSpace(*s.Description)
+				input.Description = &description
+			}
+
+			// The activity returns the bill total from the same transaction that amended the item
+			var newTotal money.Money
+			err := workflow.ExecuteActivity(ctx, AmendLineItemActivity, input).Get(ctx, &newTotal)
 			if err != nil {
-				workflow.GetLogger(ctx).Error("failed to add item to total", "err", err)
+				logger.Error("failed to amend line item transactionally", "error", err)
+				recordSignalRejected(ctx, "amend-item", string(ReasonFailed), 1)
 				return
 			}
 			state.Total = newTotal
+
+			// Fees charged on the item follow its new amount
+			if input.Amount != nil {
+				if err := applyItemFees(ctx, &state, input.ItemID, *input.Amount); err != nil {
+					logger.Error("failed to apply fees to line item", "error", err, "itemID", input.ItemID)
+				}
+			}
 		})
 
 		selector.AddReceive(closeCh, func(c workflow.ReceiveChannel, more bool) {
-			state.Closed = true
+			c.Receive(ctx, nil)
+
+			if err := closeBill(ctx, &state); err != nil {
+				logger.Error("failed to close bill", "error", err)
+				recordSignalRejected(ctx, "close-bill", rejectionReason(err), 1)
+			}
+		})
+
+		selector.AddReceive(voidCh, func(c workflow.ReceiveChannel, more bool) {
+			var s StatusChangeSignal
+			c.Receive(ctx, &s)
+
+			if err := changeStatus(ctx, &state, Void, s); err != nil {
+				logger.Warn("rejected void signal", "error", err)
+				recordSignalRejected(ctx, "void-bill", rejectionReason(err), 1)
+			}
+		})
+
+		selector.AddReceive(reopenCh, func(c workflow.ReceiveChannel, more bool) {
+			var s StatusChangeSignal
+			c.Receive(ctx, &s)
+
+			if state.Status == Closed && workflow.Now(ctx).Sub(state.ClosedAt) > reopenWindow {
+				logger.Warn("rejected reopen signal: reopen window has passed")
+				recordSignalRejected(ctx, "reopen-bill", rejectWindowExpired, 1)
+				return
+			}
+			if err := changeStatus(ctx, &state, Open, s); err != nil {
+				logger.Warn("rejected reopen signal", "error", err)
+				recordSignalRejected(ctx, "reopen-bill", rejectionReason(err), 1)
+				return
+			}
+			state.ClosedAt = time.Time{}
 		})
 
+		if reopenTimer != nil {
+			selector.AddFuture(reopenTimer, func(f workflow.Future) {
+				windowExpired = true
+			})
+		}
+
 		selector.Select(ctx)
 
-		if state.Closed {
+		if state.Status != Void && !windowExpired && limits.reached(ctx) {
+			// Signals already delivered to this run would be dropped with its history, so
+			// handle every one of them before handing the state over
+			for selector.HasPending() && state.Status != Void && !windowExpired {
+				selector.Select(ctx)
+			}
+			if state.Status != Void && !windowExpired {
+				// A repair in progress would be lost with this run
+				_ = workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) })
+				logger.Info("Continuing bill workflow as new",
+					"historyLength", workflow.GetInfo(ctx).GetCurrentHistoryLength())
+				return workflow.NewContinueAsNewError(ctx, BillWorkflow, billID, currency, accountID, limits, &state, retry)
+			}
+		}
+
+		if state.Status == Void || windowExpired {
 			break
 		}
 	}
 
-	return workflow.ExecuteActivity(
-		ctx,
-		FinalizeBillActivity,
-		state.BillID,
-		workflow.Now(ctx), // Use workflow time for determinism
-	).Get(ctx, nil)
+	_ = workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) })
+	logger.Info("Bill workflow completed", "status", state.Status)
+	return nil
+}
+
+// resolveLineItem turns an add item signal into activity input. Catalog items are priced here,
+// in workflow code, from the price returned by GetPriceActivity; prices caches prices by ID.
+func resolveLineItem(ctx workflow.Context, state *BillState, s AddItemSignal, prices map[string]Price) (AddLineItemInput, error) {
+	input := AddLineItemInput{
+		ItemID:      s.ItemID,
+		BillID:      state.BillID,
+		Description: strings.TrimSpace(s.Description),
+		CreatedAt:   workflow.Now(ctx), // Use workflow time for determinism
+	}
+
+	if s.PriceID == "" {
+		amount, err := money.NewMoney(s.Amount, state.Total.Currency)
+		if err != nil {
+			return AddLineItemInput{}, err
+		}
+		input.Amount = amount
+		return input, nil
+	}
+
+	price, ok := prices[s.PriceID]
+	if !ok {
+		if err := workflow.ExecuteActivity(ctx, GetPriceActivity, s.PriceID).Get(ctx, &price); err != nil {
+			return AddLineItemInput{}, err
+		}
+		prices[s.PriceID] = price
+	}
+	if !price.Active {
+		return AddLineItemInput{}, fmt.Errorf("price %s is archived", price.ID)
+	}
+	if price.Currency != state.Total.Currency {
+		return AddLineItemInput{}, fmt.Errorf("price %s is in %s but the bill is in %s", price.ID, price.Currency, state.Total.Currency)
+	}
+
+	charge, err := pricing.Calculate(price.definition(), s.Quantity)
+	if err != nil {
+		return AddLineItemInput{}, err
+	}
+	if err := validateItemAmount(charge.Total.Amount); err != nil {
+		return AddLineItemInput{}, err
+	}
+
+	input.Amount = charge.Total
+	input.Quantity = s.Quantity
+	// A single line covering every unit has one unit price; tiered charges do not
+	if len(charge.Lines) == 1 && charge.Lines[0].Quantity == s.Quantity {
+		input.UnitAmount = &charge.Lines[0].UnitAmount
+	}
+	input.ProductID = price.ProductID
+	input.PriceID = price.ID
+	if input.Description == "" {
+		input.Description = price.ProductName
+	}
+	return input, nil
+}
+
+// addItems prices a batch of items and their per-item fees, then inserts them all in one
+// transaction. In strict mode an item that cannot be priced rejects the whole batch; otherwise
+// it is dead-lettered and skipped. On error it returns the signals that were not added and
+// not yet dead-lettered.
+func addItems(ctx workflow.Context, state *BillState, signals []AddItemSignal, strict bool) ([]AddItemSignal, error) {
+	var rules []FeeRule
+	if chargesFees(ctx) {
+		var err error
+		if rules, err = feeRules(ctx, state); err != nil {
+			return signals, err
+		}
+	}
+
+	var (
+		prices = make(map[string]Price)
+		seen   = make(map[string]bool, len(signals))
+		items  = make([]AddLineItemInput, 0, len(signals))
+		priced = make([]AddItemSignal, 0, len(signals))
+	)
+	for _, s := range signals {
+		if seen[s.ItemID] {
+			continue // The same item signalled twice is added once
+		}
+		seen[s.ItemID] = true
+
+		input, err := resolveLineItem(ctx, state, s, prices)
+		var fees []AddLineItemInput
+		if err == nil {
+			fees, err = computeFees(rules, PerItem, state.BillID, input.ItemID, input.Amount, input.CreatedAt)
+		}
+		if err != nil && strict {
+			return signals, fmt.Errorf("item %s: %w", s.ItemID, err)
+		}
+		if err != nil {
+			deadLetter(ctx, state, ReasonFailed, err, s)
+			continue
+		}
+		items = append(items, input)
+		items = append(items, fees...)
+		priced = append(priced, s)
+	}
+	failed, err := insertItems(ctx, state, items)
+	if err != nil {
+		unapplied := make([]AddItemSignal, 0, len(failed))
+		for _, s := range priced {
+			for _, item := range failed {
+				if item.ItemID == s.ItemID {
+					unapplied = append(unapplied, s)
+					break
+				}
+			}
+		}
+		return unapplied, err
+	}
+	return nil, nil
+}
+
+// insertItems adds line items to the bill with a single activity and transaction. On error it
+// returns the items that were not added.
+func insertItems(ctx workflow.Context, state *BillState, items []AddLineItemInput) ([]AddLineItemInput, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if workflow.GetVersion(ctx, batchLineItemsVersion, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return insertItemsOneByOne(ctx, state, items)
+	}
+	if err := insertLineItems(ctx, state, AddLineItemsInput{BillID: state.BillID, Items: items}); err != nil {
+		return items, err
+	}
+	return nil, nil
+}
+
+// insertItemsOneByOne adds each item in its own activity and transaction, the way workflows started
+// before batch inserts did. An item that fails does not stop the items after it.
+func insertItemsOneByOne(ctx workflow.Context, state *BillState, items []AddLineItemInput) ([]AddLineItemInput, error) {
+	var (
+		failed  []AddLineItemInput
+		lastErr error
+	)
+	for _, item := range items {
+		if err := workflow.ExecuteActivity(ctx, AddLineItemActivity, item).Get(ctx, nil); err != nil {
+			failed = append(failed, item)
+			lastErr = err
+			continue
+		}
+		// The item is added, so a total that cannot be updated is only logged
+		newTotal, err := state.Total.Add(item.Amount)
+		if err != nil {
+			billLogger(ctx, state).Error("Failed to add item to total", "error", err, "itemID", item.ItemID)
+			continue
+		}
+		state.Total = newTotal
+		recordItemsAdded(ctx, state.Total.Currency, []AddLineItemInput{item})
+	}
+	return failed, lastErr
+}
+
+func insertLineItems(ctx workflow.Context, state *BillState, input AddLineItemsInput) error {
+	// The activity returns the bill total from the same transaction that inserted the items
+	var newTotal money.Money
+	if err := workflow.ExecuteActivity(ctx, AddLineItemsActivity, input).Get(ctx, &newTotal); err != nil {
+		return err
+	}
+	state.Total = newTotal
+	recordItemsAdded(ctx, state.Total.Currency, input.Items)
+	return nil
+}
+
+// closeBill bills any metered usage and then finalizes the bill. If either step fails the bill stays open.
+func closeBill(ctx workflow.Context, state *BillState) error {
+	if err := validateTransition(state.Status, Closed); err != nil {
+		return err
+	}
+
+	closedAt := workflow.Now(ctx) // Use workflow time for determinism
+
+	if state.AccountID != "" {
+		if err := billUsage(ctx, state, closedAt); err != nil {
+			return err
+		}
+	}
+	if err := applyBillFees(ctx, state, closedAt); err != nil {
+		return err
+	}
+
+	if err := workflow.ExecuteActivity(ctx, FinalizeBillActivity, state.BillID, closedAt).Get(ctx, nil); err != nil {
+		return err
+	}
+	state.Status = Closed
+	state.ClosedAt = closedAt
+	recordBillClosed(ctx, state.Total.Currency)
+	return nil
+}
+
+// billUsage claims the account's unbilled usage up to closedAt, prices it per meter and adds
+// one line item per charge line, so tiered usage shows the quantity billed at each rate. The
+// usage is marked billed by the transaction inserting its items; usage claimed by a close that
+// fails before then is billed by the next close.
+func billUsage(ctx workflow.Context, state *BillState, closedAt time.Time) error {
+	var usage []MeteredUsage
+	err := workflow.ExecuteActivity(ctx, CollectUsageActivity, CollectUsageInput{
+		BillID:    state.BillID,
+		AccountID: state.AccountID,
+		Currency:  state.Total.Currency,
+		Until:     closedAt,
+	}).Get(ctx, &usage)
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+
+	var items []AddLineItemInput
+	for _, u := range usage {
+		charge, err := pricing.Calculate(u.Price, u.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to price meter %s: %w", u.Meter, err)
+		}
+
+		for i, line := range charge.Lines {
+			if line.Amount.Amount == 0 {
+				continue
+			}
+			unitAmount := line.UnitAmount
+			items = append(items, AddLineItemInput{
+				ItemID:      usageItemID(state.BillID, u.Meter, i, closedAt),
+				BillID:      state.BillID,
+				Amount:      line.Amount,
+				Description: usageDescription(u.Description, line),
+				CreatedAt:   closedAt,
+				Quantity:    line.Quantity,
+				UnitAmount:  &unitAmount,
+			})
+		}
+	}
+	// Called even when every line is free, so the usage is not claimed again
+	return insertLineItems(ctx, state, AddLineItemsInput{BillID: state.BillID, Items: items, UsageBilledAt: closedAt})
+}
+
+// applyItemFees evaluates the per-item fee rules on an item and replaces the fees charged on it
+func applyItemFees(ctx workflow.Context, state *BillState, itemID string, amount money.Money) error {
+	if !chargesFees(ctx) {
+		return nil
+	}
+	rules, err := feeRules(ctx, state)
+	if err != nil {
+		return err
+	}
+
+	fees, err := computeFees(rules, PerItem, state.BillID, itemID, amount, workflow.Now(ctx))
+	if err != nil {
+		return err
+	}
+	return replaceFeeItems(ctx, state, itemID, fees)
+}
+
+// applyBillFees evaluates the per-bill fee rules on the bill total before per-bill fees. Fees from
+// an earlier close of a reopened bill are removed first, so they are never charged twice.
+func applyBillFees(ctx workflow.Context, state *BillState, closedAt time.Time) error {
+	if !chargesFees(ctx) {
+		return nil
+	}
+	rules, err := feeRules(ctx, state)
+	if err != nil {
+		return err
+	}
+	if err := replaceFeeItems(ctx, state, "", nil); err != nil {
+		return err
+	}
+
+	fees, err := computeFees(rules, PerBill, state.BillID, "", state.Total, closedAt)
+	if err != nil {
+		return err
+	}
+	if len(fees) == 0 {
+		return nil
+	}
+	return replaceFeeItems(ctx, state, "", fees)
+}
+
+// chargesFees reports whether the workflow evaluates fee rules, see feeRulesVersion
+func chargesFees(ctx workflow.Context) bool {
+	return workflow.GetVersion(ctx, feeRulesVersion, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+}
+
+func feeRules(ctx workflow.Context, state *BillState) ([]FeeRule, error) {
+	var rules []FeeRule
+	err := workflow.ExecuteActivity(ctx, ListFeeRulesActivity, ListFeeRulesInput{
+		AccountID: state.AccountID,
+		Currency:  state.Total.Currency,
+	}).Get(ctx, &rules)
+	return rules, err
+}
+
+func replaceFeeItems(ctx workflow.Context, state *BillState, sourceItemID string, fees []AddLineItemInput) error {
+	// The activity returns the bill total from the same transaction that replaced the fees
+	var newTotal money.Money
+	err := workflow.ExecuteActivity(ctx, ReplaceFeeItemsActivity, ReplaceFeeItemsInput{
+		BillID:       state.BillID,
+		SourceItemID: sourceItemID,
+		Fees:         fees,
+	}).Get(ctx, &newTotal)
+	if err != nil {
+		return err
+	}
+	state.Total = newTotal
+	return nil
+}
+
+// changeStatus validates and applies a signalled status transition, updating state only once
+// the database has accepted the change.
+func changeStatus(ctx workflow.Context, state *BillState, to Status, s StatusChangeSignal) error {
+	if err := validateTransition(state.Status, to); err != nil {
+		return err
+	}
+
+	err := workflow.ExecuteActivity(ctx, ChangeBillStatusActivity, StatusChange{
+		BillID: state.BillID,
+		To:     to,
+		Reason: s.Reason,
+		Actor:  s.Actor,
+		At:     workflow.Now(ctx), // Use workflow time for determinism
+	}).Get(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	state.Status = to
+	return nil
 }
 
 // validateAddItemSignal performs comprehensive validation of add item signals
 func validateAddItemSignal(s AddItemSignal) error {
-	// Validate ItemID format (must be valid UUID)
-	if s.ItemID == "" {
+	if err := validateItemID(s.ItemID); err != nil {
+		return err
+	}
+	if s.PriceID != "" {
+		return validateCatalogItem(s)
+	}
+	if s.Quantity != 0 {
+		return errors.New("quantity requires a price ID")
+	}
+	if err := validateItemAmount(s.Amount); err != nil {
+		return err
+	}
+	if err := validateItemDescription(s.Description); err != nil {
+		return err
+	}
+
+	// Additional business validations could be added here:
+	// - Check for duplicate ItemIDs (would require state tracking)
+	// - Validate amount precision (must be valid cents)
+	// - Check business rules (hourly limits, etc.)
+
+	return nil
+}
+
+// maxBatchItems bounds the number of items in one add items signal
+const maxBatchItems = 1000
+
+// validateAddItemsSignal validates every item of a batch and rejects duplicate item IDs
+func validateAddItemsSignal(s AddItemsSignal) error {
+	if len(s.Items) == 0 || len(s.Items) > maxBatchItems {
+		return fmt.Errorf("a batch must contain 1 to %d items", maxBatchItems)
+	}
+
+	seen := make(map[string]bool, len(s.Items))
+	for i, item := range s.Items {
+		if err := validateAddItemSignal(item); err != nil {
+			return fmt.Errorf("items[%d]: %w", i, err)
+		}
+		if seen[item.ItemID] {
+			return fmt.Errorf("items[%d]: duplicate item ID %s", i, item.ItemID)
+		}
+		seen[item.ItemID] = true
+	}
+	return nil
+}
+
+// validateCatalogItem validates an add item signal priced from the catalog; the amount is computed by the workflow
+func validateCatalogItem(s AddItemSignal) error {
+	if _, err := uuid.Parse(s.PriceID); err != nil {
+		return errors.New("price ID must be a valid UUID")
+	}
+	if s.Amount != 0 {
+		return errors.New("amount cannot be set together with a price ID")
+	}
+	if s.Quantity <= 0 || s.Quantity > maxItemQuantity {
+		return errors.New("quantity must be positive and reasonable")
+	}
+	if len(strings.TrimSpace(s.Description)) > 500 {
+		return errors.New("description exceeds maximum length (500 characters)")
+	}
+	return nil
+}
+
+// validateAmendItemSignal validates an amend signal; at least one field must be changed
+func validateAmendItemSignal(s AmendItemSignal) error {
+	if err := validateItemID(s.ItemID); err != nil {
+		return err
+	}
+	if s.Amount == nil && s.Description == nil {
+		return errors.New("amount or description is required")
+	}
+	if s.Amount != nil {
+		if err := validateItemAmount(*s.Amount); err != nil {
+			return err
+		}
+	}
+	if s.Description != nil {
+		if err := validateItemDescription(*s.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateItemID checks that a line item ID is a valid UUID
+func validateItemID(id string) error {
+	if id == "" {
 		return errors.New("item ID is required")
 	}
-	if _, err := uuid.Parse(s.ItemID); err != nil {
+	if _, err := uuid.Parse(id); err != nil {
 		return errors.New("item ID must be a valid UUID")
 	}
+	return nil
+}
 
-	// Validate amount (must be positive and within business limits)
-	if s.Amount <= 0 {
+// validateItemAmount checks that an amount is positive and within business limits
+func validateItemAmount(amount int64) error {
+	if amount <= 0 {
 		return errors.New("amount must be positive")
 	}
-	if s.Amount > 1_000_000_00 { // $1M limit
+	if amount > MaxAmountCents { // $1M limit
 		return errors.New("amount exceeds maximum allowed ($1M)")
 	}
+	return nil
+}
 
-	// Validate description (required and reasonable length)
-	trimmedDesc := strings.TrimSpace(s.Description)
+// validateItemDescription checks that a description is present and of reasonable length
+func validateItemDescription(description string) error {
+	trimmedDesc := strings.TrimSpace(description)
 	if trimmedDesc == "" {
 		return errors.New("description is required")
 	}
 	if len(trimmedDesc) > 500 {
 		return errors.New("description exceeds maximum length (500 characters)")
 	}
-
-	// Additional business validations could be added here:
-	// - Check for duplicate ItemIDs (would require state tracking)
-	// - Validate amount precision (must be valid cents)
-	// - Check business rules (hourly limits, etc.)
-
 	return nil
 }
@@ -0,0 +1,593 @@
+package bill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"fees-api/money"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	testBillID  = "3b9f6a2e-8c1d-4e57-9f0a-6d2c8b4e1a77"
+	testItemID  = "c4a8e2f1-5b3d-4c9e-8a7f-1e2d3c4b5a69"
+	testItemID2 = "7d3e9b1a-6c2f-4e8d-b5a4-3f1e2d0c9b87"
+)
+
+// billWorkflowTest runs BillWorkflow against mocked activities that keep the bill total in memory
+type billWorkflowTest struct {
+	t   *testing.T
+	env *testsuite.TestWorkflowEnvironment
+
+	total      money.Money
+	activities []string // activity types in the order they started, retries included
+	batches    [][]AddLineItemInput
+	dead       []DeadLetterInput
+	billedAt   []time.Time // UsageBilledAt of each inserted batch
+
+	usage []MeteredUsage // returned by CollectUsageActivity
+
+	// Errors returned by the mocked activities, nil for success
+	createErr   error
+	addErr      error
+	finalizeErr error
+	voidErr     error
+}
+
+func newBillWorkflowTest(t *testing.T) *billWorkflowTest {
+	return newBillWorkflowTestIn(t, &testsuite.WorkflowTestSuite{})
+}
+
+// newBillWorkflowTestIn runs the workflow in s, for tests that set up the suite themselves
+func newBillWorkflowTestIn(t *testing.T, s *testsuite.WorkflowTestSuite) *billWorkflowTest {
+	w := &billWorkflowTest{t: t, env: s.NewTestWorkflowEnvironment(), total: money.Money{Currency: money.USD}}
+	env := w.env
+	env.RegisterWorkflow(BillWorkflow)
+
+	env.OnActivity(CreateBillActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, input CreateBillInput) (*Bill, error) {
+			if w.createErr != nil {
+				return nil, w.createErr
+			}
+			return &Bill{ID: input.BillID, AccountID: input.AccountID, Status: Open,
+				Total: money.Money{Currency: input.Currency}, CreatedAt: input.CreatedAt}, nil
+		})
+	env.OnActivity(ListFeeRulesActivity, mock.Anything, mock.Anything).Return([]FeeRule(nil), nil)
+	env.OnActivity(CollectUsageActivity, mock.Anything, mock.Anything).Return(
+		func(context.Context, CollectUsageInput) ([]MeteredUsage, error) { return w.usage, nil })
+	env.OnActivity(AddLineItemsActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, input AddLineItemsInput) (money.Money, error) {
+			if w.addErr != nil {
+				return money.Money{}, w.addErr
+			}
+			if input.BillID != testBillID {
+				t.Errorf("AddLineItemsActivity bill ID = %s, want %s", input.BillID, testBillID)
+			}
+			w.batches = append(w.batches, input.Items)
+			w.billedAt = append(w.billedAt, input.UsageBilledAt)
+			for _, item := range input.Items {
+				next, err := w.total.Add(item.Amount)
+				if err != nil {
+					return money.Money{}, err
+				}
+				w.total = next
+			}
+			return w.total, nil
+		})
+	env.OnActivity(ReplaceFeeItemsActivity, mock.Anything, mock.Anything).Return(
+		func(context.Context, ReplaceFeeItemsInput) (money.Money, error) { return w.total, nil })
+	env.OnActivity(RemoveLineItemActivity, mock.Anything, mock.Anything).Return(
+		func(context.Context, RemoveLineItemInput) (money.Money, error) { return w.total, nil })
+	env.OnActivity(AmendLineItemActivity, mock.Anything, mock.Anything).Return(
+		func(context.Context, AmendLineItemInput) (money.Money, error) { return w.total, nil })
+	env.OnActivity(FinalizeBillActivity, mock.Anything, testBillID, mock.Anything).Return(
+		func(context.Context, string, time.Time) error { return w.finalizeErr })
+	env.OnActivity(ChangeBillStatusActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, change StatusChange) error {
+			if change.To == Void {
+				return w.voidErr
+			}
+			return nil
+		})
+	env.OnActivity(DeadLetterActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, input DeadLetterInput) error {
+			w.dead = append(w.dead, input)
+			return nil
+		})
+
+	env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		w.activities = append(w.activities, info.ActivityType.Name)
+	})
+	return w
+}
+
+// signal delivers signals to the workflow together, after it has been running for delay
+func (w *billWorkflowTest) signal(delay time.Duration, signals ...func()) {
+	w.env.RegisterDelayedCallback(func() {
+		for _, s := range signals {
+			s()
+		}
+	}, delay)
+}
+
+func (w *billWorkflowTest) send(name string, arg interface{}) func() {
+	return func() { w.env.SignalWorkflow(name, arg) }
+}
+
+// buffer delivers a signal without running a workflow task, so it waits for the next signal
+// and both are handled in the same task, as when they reach the server together
+func (w *billWorkflowTest) buffer(name string, arg interface{}) func() {
+	return func() { w.env.SignalWorkflowSkippingWorkflowTask(name, arg) }
+}
+
+func (w *billWorkflowTest) execute() {
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "", HistoryLimits{}, (*BillState)(nil), (*RetryConfig)(nil))
+}
+
+// requireCompleted fails the test unless the workflow ran to completion without error
+func (w *billWorkflowTest) requireCompleted() {
+	w.t.Helper()
+	if !w.env.IsWorkflowCompleted() {
+		w.t.Fatal("workflow did not complete")
+	}
+	if err := w.env.GetWorkflowError(); err != nil {
+		w.t.Fatalf("workflow error = %v", err)
+	}
+}
+
+func (w *billWorkflowTest) assertActivities(want ...string) {
+	w.t.Helper()
+	if !reflect.DeepEqual(w.activities, want) {
+		w.t.Errorf("activities = %v, want %v", w.activities, want)
+	}
+}
+
+func (w *billWorkflowTest) assertTotal(want int64) {
+	w.t.Helper()
+	if w.total != (money.Money{Amount: want, Currency: money.USD}) {
+		w.t.Errorf("bill total = %v, want %d USD", w.total, want)
+	}
+}
+
+// assertDeadLetters checks that exactly the given items were dead-lettered, all for reason
+func (w *billWorkflowTest) assertDeadLetters(reason DeadLetterReason, itemIDs ...string) {
+	w.t.Helper()
+	var got []string
+	for _, d := range w.dead {
+		if d.Reason != reason {
+			w.t.Errorf("dead letter reason = %s, want %s", d.Reason, reason)
+		}
+		if d.Error == "" {
+			w.t.Error("dead letter has no error")
+		}
+		for _, s := range d.Signals {
+			got = append(got, s.ItemID)
+		}
+	}
+	if !reflect.DeepEqual(got, itemIDs) {
+		w.t.Errorf("dead-lettered items = %v, want %v", got, itemIDs)
+	}
+}
+
+func addItem(itemID string, amount int64) AddItemSignal {
+	return AddItemSignal{ItemID: itemID, Amount: amount, Description: "transfer fee"}
+}
+
+func TestBillWorkflowAddThenClose(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	w := newBillWorkflowTest(t)
+	w.env.SetStartTime(start)
+	w.signal(time.Minute, w.send("add-item", addItem(testItemID, 250)))
+	w.signal(2*time.Minute, w.send("close-bill", nil))
+	w.execute()
+
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ListFeeRulesActivity", "AddLineItemsActivity",
+		"ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity")
+	w.assertTotal(250)
+	// A closed bill stays reopenable for reopenWindow before the workflow completes
+	if elapsed := w.env.Now().Sub(start); elapsed < reopenWindow {
+		t.Errorf("workflow completed %v after start, want at least %v", elapsed, reopenWindow)
+	}
+}
+
+func TestBillWorkflowCreatesBillRow(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	w := newBillWorkflowTest(t)
+	w.env.SetStartTime(start)
+
+	var created *Bill
+	w.env.RegisterDelayedCallback(func() {
+		w.env.UpdateWorkflow(createdUpdate, "create", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { t.Errorf("update rejected: %v", err) },
+			OnAccept: func() {},
+			OnComplete: func(result interface{}, err error) {
+				if err != nil {
+					t.Errorf("update error = %v", err)
+				}
+				created, _ = result.(*Bill)
+			},
+		})
+	}, 0)
+	w.signal(time.Minute, w.send("void-bill", StatusChangeSignal{Reason: "duplicate", Actor: "admin"}))
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "acct-1", HistoryLimits{}, (*BillState)(nil), (*RetryConfig)(nil))
+
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ChangeBillStatusActivity")
+	if created == nil || created.ID != testBillID || created.AccountID != "acct-1" || !created.CreatedAt.Equal(start) {
+		t.Errorf("created bill = %+v, want bill %s of acct-1 created at %v", created, testBillID, start)
+	}
+}
+
+func TestBillWorkflowRepairsTotal(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	repaired := money.Money{Amount: 2500, Currency: money.USD}
+	w.env.OnActivity(RepairBillTotalActivity, mock.Anything, testBillID).Return(
+		TotalRepair{Recorded: 2000, LineItems: 2500, Revenue: 2500, Repaired: true, Total: repaired}, nil)
+
+	var result TotalRepair
+	w.env.RegisterDelayedCallback(func() {
+		w.env.UpdateWorkflow(repairTotalUpdate, "repair", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { t.Errorf("update rejected: %v", err) },
+			OnAccept: func() {},
+			OnComplete: func(r interface{}, err error) {
+				if err != nil {
+					t.Errorf("update error = %v", err)
+				}
+				result, _ = r.(TotalRepair)
+			},
+		})
+	}, time.Minute)
+	var state BillState
+	w.env.RegisterDelayedCallback(func() {
+		value, err := w.env.QueryWorkflow("state")
+		if err != nil {
+			t.Fatalf("query state: %v", err)
+		}
+		if err := value.Get(&state); err != nil {
+			t.Fatalf("decode state: %v", err)
+		}
+	}, 2*time.Minute)
+	w.signal(3*time.Minute, w.send("void-bill", StatusChangeSignal{Reason: "duplicate", Actor: "admin"}))
+	w.execute()
+
+	w.requireCompleted()
+	if !result.Repaired || result.Total != repaired {
+		t.Errorf("update result = %+v, want a repair to %v", result, repaired)
+	}
+	// The workflow holds the repaired total, so it agrees with the database again
+	if state.Total != repaired {
+		t.Errorf("workflow total = %v, want %v", state.Total, repaired)
+	}
+}
+
+func TestBillWorkflowCreateFailure(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.createErr = errors.New("database unavailable")
+
+	var updateErr error
+	w.env.RegisterDelayedCallback(func() {
+		w.env.UpdateWorkflow(createdUpdate, "create", &testsuite.TestUpdateCallback{
+			OnReject:   func(err error) { t.Errorf("update rejected: %v", err) },
+			OnAccept:   func() {},
+			OnComplete: func(_ interface{}, err error) { updateErr = err },
+		})
+	}, 0)
+	w.execute()
+
+	// Without a bill row the workflow fails, and the waiting caller sees why
+	if !w.env.IsWorkflowCompleted() || w.env.GetWorkflowError() == nil {
+		t.Fatal("workflow did not fail")
+	}
+	if updateErr == nil {
+		t.Error("update succeeded, want the creation error")
+	}
+	for _, a := range w.activities {
+		if a != "CreateBillActivity" {
+			t.Errorf("activity %s ran after the bill could not be created", a)
+		}
+	}
+}
+
+func TestBillWorkflowRejectsItemsAfterClose(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.signal(time.Minute, w.send("close-bill", nil))
+	w.signal(2*time.Minute, w.send("add-item", addItem(testItemID, 250)))
+	w.execute()
+
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity", "DeadLetterActivity")
+	w.assertTotal(0)
+	w.assertDeadLetters(ReasonNotOpen, testItemID)
+}
+
+func TestBillWorkflowClosesWithPendingItems(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	// Items still waiting in the workflow when the close is handled are billed before it
+	w.signal(time.Minute,
+		w.buffer("add-item", addItem(testItemID, 250)),
+		w.buffer("add-item", addItem(testItemID2, 150)),
+		w.send("close-bill", nil),
+	)
+	w.execute()
+
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ListFeeRulesActivity", "AddLineItemsActivity",
+		"ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity")
+	if len(w.batches) != 1 || len(w.batches[0]) != 2 {
+		t.Errorf("inserted batches = %v, want both items in one batch", w.batches)
+	}
+	w.assertTotal(400)
+}
+
+func TestBillWorkflowIgnoresInvalidSignals(t *testing.T) {
+	noFields := AmendItemSignal{ItemID: testItemID}
+	w := newBillWorkflowTest(t)
+	w.signal(time.Minute,
+		w.buffer("add-item", addItem("not-a-uuid", 250)),
+		w.buffer("add-item", addItem(testItemID, 0)),
+		w.buffer("add-item", addItem(testItemID2, 150)),
+		w.send("add-items", AddItemsSignal{}),
+	)
+	w.signal(time.Minute+time.Second,
+		w.send("remove-item", RemoveItemSignal{ItemID: "not-a-uuid"}),
+		w.send("amend-item", noFields),
+	)
+	w.signal(2*time.Minute, w.send("void-bill", StatusChangeSignal{Reason: "duplicate", Actor: "admin"}))
+	w.execute()
+
+	w.requireCompleted()
+	// Only the valid item of the burst is inserted; the invalid items are dead-lettered
+	w.assertActivities("CreateBillActivity", "DeadLetterActivity", "DeadLetterActivity",
+		"ListFeeRulesActivity", "AddLineItemsActivity", "ChangeBillStatusActivity")
+	if len(w.batches) != 1 || len(w.batches[0]) != 1 || w.batches[0][0].ItemID != testItemID2 {
+		t.Errorf("inserted batches = %v, want only %s", w.batches, testItemID2)
+	}
+	w.assertTotal(150)
+	w.assertDeadLetters(ReasonInvalid, "not-a-uuid", testItemID)
+}
+
+func TestBillWorkflowAddActivityFailure(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.addErr = errors.New("database unavailable")
+	w.signal(time.Minute, w.send("add-item", addItem(testItemID, 250)))
+	w.signal(time.Hour, w.send("close-bill", nil))
+	w.execute()
+
+	// The item is dead-lettered once its retries are exhausted; the bill can still be closed
+	w.requireCompleted()
+	attempts := 0
+	for _, a := range w.activities {
+		if a == "AddLineItemsActivity" {
+			attempts++
+		}
+	}
+	if attempts != 5 {
+		t.Errorf("AddLineItemsActivity attempts = %d, want 5", attempts)
+	}
+	if last := w.activities[len(w.activities)-1]; last != "FinalizeBillActivity" {
+		t.Errorf("last activity = %s, want FinalizeBillActivity", last)
+	}
+	w.assertTotal(0)
+	w.assertDeadLetters(ReasonFailed, testItemID)
+}
+
+func TestBillWorkflowItemIDOnAnotherBill(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.addErr = lineItemConflict(fmt.Errorf("item %s: %w", testItemID, ErrLineItemConflict))
+	w.signal(time.Minute, w.send("add-item", addItem(testItemID, 250)))
+	w.signal(time.Hour, w.send("close-bill", nil))
+	w.execute()
+
+	// The item is dead-lettered without retries rather than reported as added
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ListFeeRulesActivity", "AddLineItemsActivity", "DeadLetterActivity",
+		"ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity")
+	w.assertTotal(0)
+	w.assertDeadLetters(ReasonFailed, testItemID)
+}
+
+func TestBillWorkflowAddsItemsOneByOneBeforeBatching(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.env.OnGetVersion(batchLineItemsVersion, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	w.env.OnActivity(AddLineItemActivity, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, input AddLineItemInput) error {
+			if input.ItemID == testItemID {
+				return temporal.NewNonRetryableApplicationError("duplicate item", "DuplicateItem", nil)
+			}
+			next, err := w.total.Add(input.Amount)
+			w.total = next
+			return err
+		})
+	w.signal(time.Minute, w.buffer("add-item", addItem(testItemID, 250)), w.send("add-item", addItem(testItemID2, 100)))
+	w.signal(time.Hour, w.send("close-bill", nil))
+	w.execute()
+
+	// Workflows started before batch inserts add each item on its own; a failed item does not stop the next
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ListFeeRulesActivity", "AddLineItemActivity", "AddLineItemActivity",
+		"DeadLetterActivity", "ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity")
+	w.assertTotal(100)
+	w.assertDeadLetters(ReasonFailed, testItemID)
+	if len(w.batches) != 0 {
+		t.Errorf("batches = %v, want none", w.batches)
+	}
+}
+
+func TestBillWorkflowFinalizeFailureKeepsBillOpen(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.finalizeErr = errors.New("database unavailable")
+	w.signal(time.Minute, w.send("close-bill", nil))
+	// Voiding is only allowed because the failed close left the bill open
+	w.signal(time.Hour, w.send("void-bill", StatusChangeSignal{Reason: "abandoned", Actor: "admin"}))
+	w.execute()
+
+	w.requireCompleted()
+	if last := w.activities[len(w.activities)-1]; last != "ChangeBillStatusActivity" {
+		t.Errorf("last activity = %s, want ChangeBillStatusActivity", last)
+	}
+}
+
+func TestBillWorkflowRejectsVoidOfInvoicedBill(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.voidErr = temporal.NewNonRetryableApplicationError("bill holds invoice INV-2026-000001", billInvoicedError, ErrBillInvoiced)
+	w.signal(time.Minute, w.send("close-bill", nil))
+	w.signal(time.Hour, w.send("reopen-bill", StatusChangeSignal{Reason: "missing item", Actor: "admin"}))
+	w.signal(2*time.Hour, w.send("void-bill", StatusChangeSignal{Reason: "duplicate", Actor: "admin"}))
+	w.signal(3*time.Hour, w.send("close-bill", nil))
+	w.execute()
+
+	// The refused void is not retried and the reopened bill can still be closed again
+	w.requireCompleted()
+	w.assertActivities("CreateBillActivity", "ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity",
+		"ChangeBillStatusActivity", "ChangeBillStatusActivity",
+		"ListFeeRulesActivity", "ReplaceFeeItemsActivity", "FinalizeBillActivity")
+}
+
+func TestBillWorkflowMarksUsageBilled(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	w := newBillWorkflowTest(t)
+	w.env.SetStartTime(start)
+	w.usage = []MeteredUsage{
+		{Meter: "api_calls", Description: "API calls", Quantity: 100,
+			Price: (&Meter{Key: "api_calls", Model: "per_unit", UnitPrice: money.Money{Amount: 2, Currency: money.USD}}).definition()},
+	}
+	w.signal(time.Minute, w.send("add-item", addItem(testItemID, 250)))
+	w.signal(2*time.Minute, w.send("close-bill", nil))
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "acct-1", HistoryLimits{}, (*BillState)(nil), (*RetryConfig)(nil))
+
+	w.requireCompleted()
+	// Only the usage batch marks the claimed usage billed, at the close time
+	want := []time.Time{{}, start.Add(2 * time.Minute)}
+	if !reflect.DeepEqual(w.billedAt, want) {
+		t.Errorf("usage billed at = %v, want %v", w.billedAt, want)
+	}
+	w.assertTotal(450)
+}
+
+func TestBillWorkflowMarksFreeUsageBilled(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	w.usage = []MeteredUsage{
+		{Meter: "api_calls", Description: "API calls", Quantity: 100,
+			Price: (&Meter{Key: "api_calls", Model: "per_unit", UnitPrice: money.Money{Currency: money.USD}}).definition()},
+	}
+	w.signal(time.Minute, w.send("close-bill", nil))
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "acct-1", HistoryLimits{}, (*BillState)(nil), (*RetryConfig)(nil))
+
+	w.requireCompleted()
+	// Free usage has no line items but must not be claimed again by a later close
+	if len(w.batches) != 1 || len(w.batches[0]) != 0 || w.billedAt[0].IsZero() {
+		t.Errorf("inserted batches = %v billing usage at %v, want one empty batch billing the usage", w.batches, w.billedAt)
+	}
+}
+
+// continuedState asserts that the workflow continued as new and returns the state it carried over
+func continuedState(t *testing.T, env *testsuite.TestWorkflowEnvironment, wantLimits HistoryLimits) BillState {
+	t.Helper()
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+
+	var can *workflow.ContinueAsNewError
+	if err := env.GetWorkflowError(); !errors.As(err, &can) {
+		t.Fatalf("workflow error = %v, want continue as new", err)
+	}
+	if can.WorkflowType.Name != "BillWorkflow" {
+		t.Errorf("continued as %s, want BillWorkflow", can.WorkflowType.Name)
+	}
+
+	var (
+		billID    string
+		currency  money.Currency
+		accountID string
+		limits    HistoryLimits
+		state     *BillState
+		retry     *RetryConfig
+	)
+	if err := converter.GetDefaultDataConverter().FromPayloads(can.Input, &billID, &currency, &accountID, &limits, &state, &retry); err != nil {
+		t.Fatalf("failed to decode continue as new input: %v", err)
+	}
+	if billID != testBillID || currency != money.USD || limits != wantLimits {
+		t.Errorf("continued with (%s, %s, %+v), want (%s, %s, %+v)", billID, currency, limits, testBillID, money.USD, wantLimits)
+	}
+	if retry == nil || *retry != defaultActivityRetry {
+		t.Errorf("continued with retry policy %+v, want %+v", retry, defaultActivityRetry)
+	}
+	if state == nil {
+		t.Fatal("continued without carried state")
+	}
+	return *state
+}
+
+func TestBillWorkflowContinuesAsNewWithBufferedSignals(t *testing.T) {
+	w := newBillWorkflowTest(t)
+	limits := HistoryLimits{MaxEvents: 10}
+	w.env.SetCurrentHistoryLength(25)
+
+	// Both signals arrive together; the close is still buffered when the limit is noticed
+	w.signal(time.Minute, w.buffer("add-item", addItem(testItemID, 250)), w.send("close-bill", nil))
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "acct-1", limits, (*BillState)(nil), &defaultActivityRetry)
+
+	state := continuedState(t, w.env, limits)
+	if state.BillID != testBillID || state.AccountID != "acct-1" {
+		t.Errorf("carried bill %s of account %s, want %s of acct-1", state.BillID, state.AccountID, testBillID)
+	}
+	if state.Total != (money.Money{Amount: 250, Currency: money.USD}) {
+		t.Errorf("carried total = %v, want 250 USD", state.Total)
+	}
+	if state.Status != Closed || state.ClosedAt.IsZero() {
+		t.Errorf("carried status %s closed at %v, want the buffered close applied", state.Status, state.ClosedAt)
+	}
+}
+
+func TestBillWorkflowResumesCarriedState(t *testing.T) {
+	carried := BillState{
+		BillID:    testBillID,
+		AccountID: "acct-1",
+		Total:     money.Money{Amount: 500, Currency: money.USD},
+		Status:    Open,
+	}
+	w := newBillWorkflowTest(t)
+	w.total = carried.Total
+	limits := HistoryLimits{MaxEvents: 10}
+	w.env.SetCurrentHistoryLength(25)
+
+	w.signal(time.Minute, w.send("add-item", addItem(testItemID, 250)))
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "acct-1", limits, &carried, &defaultActivityRetry)
+
+	want := carried
+	want.Total = money.Money{Amount: 750, Currency: money.USD}
+	if got := continuedState(t, w.env, limits); got != want {
+		t.Errorf("carried state = %+v, want %+v", got, want)
+	}
+}
+
+func TestBillWorkflowCarriedReopenWindow(t *testing.T) {
+	closedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	carried := BillState{
+		BillID:   testBillID,
+		Total:    money.Money{Amount: 500, Currency: money.USD},
+		Status:   Closed,
+		ClosedAt: closedAt,
+	}
+	w := newBillWorkflowTest(t)
+	w.env.SetStartTime(closedAt.Add(reopenWindow - 24*time.Hour))
+
+	w.env.ExecuteWorkflow(BillWorkflow, testBillID, money.USD, "", HistoryLimits{}, &carried, (*RetryConfig)(nil))
+
+	w.requireCompleted()
+	// The continued run only waits out what is left of the window
+	if got, want := w.env.Now(), closedAt.Add(reopenWindow); got.Sub(want).Abs() > time.Second {
+		t.Errorf("workflow completed at %v, want %v", got, want)
+	}
+}
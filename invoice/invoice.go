@@ -0,0 +1,175 @@
+// Package invoice renders closed bills into customer-facing HTML and PDF documents.
+// Rendering is deterministic: the same Document always produces byte-identical output.
+package invoice
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"fees-api/money"
+
+	"github.com/go-pdf/fpdf"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+var htmlTemplate = template.Must(template.New("invoice.html.tmpl").Funcs(template.FuncMap{
+	"lines": func(s string) []string { return strings.Split(s, "\n") },
+}).ParseFS(templates, "templates/invoice.html.tmpl"))
+
+// Issuer holds the branding printed at the top of every invoice
+type Issuer struct {
+	Name    string
+	Address string // may contain newlines
+	Logo    []byte // PNG image, optional
+}
+
+// Line is a single charge on the invoice
+type Line struct {
+	Description string
+	Amount      money.Money
+}
+
+// Tax is the tax charged on the invoice subtotal
+type Tax struct {
+	BasisPoints int64 // 2000 is 20%, 0 when the rate is not known
+	Amount      money.Money
+}
+
+// Label names the tax line, with its rate when known, e.g. Tax (20%)
+func (t Tax) Label() string {
+	if t.BasisPoints == 0 {
+		return "Tax"
+	}
+	return "Tax (" + t.Rate() + ")"
+}
+
+// Rate formats the tax rate as a percentage, e.g. 20% or 8.25%
+func (t Tax) Rate() string {
+	rate := fmt.Sprintf("%d.%02d", t.BasisPoints/100, t.BasisPoints%100)
+	return strings.TrimSuffix(strings.TrimRight(rate, "0"), ".") + "%"
+}
+
+// Document is everything needed to render one invoice
+type Document struct {
+	Issuer   Issuer
+	Number   string // invoice number, e.g. INV-2026-000123
+	BillID   string
+	IssuedAt time.Time
+	Lines    []Line
+	Subtotal money.Money // sum of the lines
+	Tax      *Tax        // nil when no tax is charged
+	Total    money.Money // amount due, tax included
+}
+
+// title identifies the invoice by number, falling back to the bill ID
+func (d Document) title() string {
+	if d.Number != "" {
+		return d.Number
+	}
+	return d.BillID
+}
+
+// RenderHTML renders the document as a standalone HTML page
+func RenderHTML(doc Document) ([]byte, error) {
+	data := struct {
+		Document
+		LogoURI template.URL
+	}{Document: doc}
+	if len(doc.Issuer.Logo) > 0 {
+		data.LogoURI = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(doc.Issuer.Logo))
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render invoice %s as HTML: %w", doc.BillID, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPDF renders the document as an A4 PDF using the built-in core fonts
+func RenderPDF(doc Document) ([]byte, error) {
+	const (
+		descWidth   = 140.0
+		amountWidth = 40.0
+		lineHeight  = 6.0
+	)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// Fixed metadata keeps the output byte-identical across renders
+	pdf.SetCreationDate(doc.IssuedAt)
+	pdf.SetModificationDate(doc.IssuedAt)
+	pdf.SetCatalogSort(true)
+	pdf.SetProducer("fees-api", false)
+	pdf.SetTitle("Invoice "+doc.title(), false)
+	pdf.AddPage()
+
+	// Core fonts are cp1252; translate UTF-8 input so common accented characters survive
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	if len(doc.Issuer.Logo) > 0 {
+		pdf.RegisterImageOptionsReader("logo", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(doc.Issuer.Logo))
+		pdf.ImageOptions("logo", 150, 10, 0, 15, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, tr(doc.Issuer.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range strings.Split(doc.Issuer.Address, "\n") {
+		pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(8)
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "INVOICE", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	if doc.Number != "" {
+		pdf.CellFormat(0, 5, "Invoice number: "+tr(doc.Number), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 5, "Bill: "+doc.BillID, "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Date: "+doc.IssuedAt.UTC().Format("2006-01-02"), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(descWidth, lineHeight+1, "Description", "B", 0, "L", false, 0, "")
+	pdf.CellFormat(amountWidth, lineHeight+1, "Amount", "B", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range doc.Lines {
+		wrapped := pdf.SplitText(tr(line.Description), descWidth)
+		if len(wrapped) == 0 {
+			wrapped = []string{""}
+		}
+		for i, text := range wrapped {
+			amount := ""
+			if i == 0 {
+				amount = tr(line.Amount.Format())
+			}
+			pdf.CellFormat(descWidth, lineHeight, text, "", 0, "L", false, 0, "")
+			pdf.CellFormat(amountWidth, lineHeight, amount, "", 1, "R", false, 0, "")
+		}
+	}
+
+	if doc.Tax != nil {
+		pdf.CellFormat(descWidth, lineHeight, "Subtotal", "T", 0, "L", false, 0, "")
+		pdf.CellFormat(amountWidth, lineHeight, tr(doc.Subtotal.Format()), "T", 1, "R", false, 0, "")
+		pdf.CellFormat(descWidth, lineHeight, doc.Tax.Label(), "", 0, "L", false, 0, "")
+		pdf.CellFormat(amountWidth, lineHeight, tr(doc.Tax.Amount.Format()), "", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(descWidth, lineHeight+2, "Total", "T", 0, "L", false, 0, "")
+	pdf.CellFormat(amountWidth, lineHeight+2, tr(doc.Total.Format()), "T", 1, "R", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render invoice %s as PDF: %w", doc.BillID, err)
+	}
+	return buf.Bytes(), nil
+}
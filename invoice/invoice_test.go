@@ -0,0 +1,173 @@
+package invoice
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"fees-api/money"
+)
+
+func testDocument(t *testing.T) Document {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, img); err != nil {
+		t.Fatalf("failed to encode logo: %v", err)
+	}
+
+	return Document{
+		Issuer: Issuer{
+			Name:    "Fees Inc.",
+			Address: "1 Main Street\nTbilisi",
+			Logo:    logo.Bytes(),
+		},
+		Number:   "INV-2026-000123",
+		BillID:   "8c8a2a5e-2a3c-4b0c-9a55-3f2b7d5e1c11",
+		IssuedAt: time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC),
+		Lines: []Line{
+			{Description: "Consulting <services>", Amount: money.Money{Amount: 123456, Currency: money.USD}},
+			{Description: strings.Repeat("long description ", 20), Amount: money.Money{Amount: 99, Currency: money.USD}},
+		},
+		Total: money.Money{Amount: 123555, Currency: money.USD},
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	doc := testDocument(t)
+
+	out, err := RenderHTML(doc)
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	html := string(out)
+
+	for _, want := range []string{
+		"Fees Inc.",
+		"<div>Tbilisi</div>",
+		"Consulting &lt;services&gt;",
+		"$1,234.56",
+		"$1,235.55",
+		"2026-03-14",
+		"Invoice number: INV-2026-000123",
+		"<title>Invoice INV-2026-000123</title>",
+		"data:image/png;base64,",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("RenderHTML() output missing %q", want)
+		}
+	}
+}
+
+func TestRenderHTML_NoLogo(t *testing.T) {
+	doc := testDocument(t)
+	doc.Issuer.Logo = nil
+
+	out, err := RenderHTML(doc)
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	if strings.Contains(string(out), "<img") {
+		t.Error("RenderHTML() rendered a logo without one configured")
+	}
+}
+
+func TestRenderHTML_Tax(t *testing.T) {
+	doc := testDocument(t)
+	doc.Subtotal = doc.Total
+	doc.Tax = &Tax{BasisPoints: 825, Amount: money.Money{Amount: 10193, Currency: money.USD}}
+	doc.Total = money.Money{Amount: 133748, Currency: money.USD}
+
+	out, err := RenderHTML(doc)
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	html := string(out)
+
+	for _, want := range []string{
+		"<td>Subtotal</td><td class=\"amount\">$1,235.55</td>",
+		"<td>Tax (8.25%)</td><td class=\"amount\">$101.93</td>",
+		"<td>Total</td><td class=\"amount\">$1,337.48</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("RenderHTML() output missing %q", want)
+		}
+	}
+}
+
+func TestRenderHTML_NoTax(t *testing.T) {
+	out, err := RenderHTML(testDocument(t))
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	if strings.Contains(string(out), "Subtotal") || strings.Contains(string(out), "Tax") {
+		t.Error("RenderHTML() rendered a tax line without tax")
+	}
+}
+
+func TestTaxRate(t *testing.T) {
+	tests := []struct {
+		basisPoints int64
+		want        string
+	}{
+		{2000, "20%"},
+		{825, "8.25%"},
+		{50, "0.5%"},
+		{7, "0.07%"},
+	}
+	for _, tt := range tests {
+		if got := (Tax{BasisPoints: tt.basisPoints}).Rate(); got != tt.want {
+			t.Errorf("Tax{BasisPoints: %d}.Rate() = %q, want %q", tt.basisPoints, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	doc := testDocument(t)
+
+	out, err := RenderPDF(doc)
+	if err != nil {
+		t.Fatalf("RenderPDF() error = %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-")) {
+		t.Errorf("RenderPDF() output does not start with a PDF header")
+	}
+}
+
+func TestRender_Deterministic(t *testing.T) {
+	renderers := map[string]func(Document) ([]byte, error){
+		"html": RenderHTML,
+		"pdf":  RenderPDF,
+	}
+
+	for name, render := range renderers {
+		t.Run(name, func(t *testing.T) {
+			first, err := render(testDocument(t))
+			if err != nil {
+				t.Fatalf("render error = %v", err)
+			}
+			second, err := render(testDocument(t))
+			if err != nil {
+				t.Fatalf("render error = %v", err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Error("rendering the same document twice produced different bytes")
+			}
+		})
+	}
+}
+
+func TestTaxLabel(t *testing.T) {
+	if got := (Tax{BasisPoints: 825}).Label(); got != "Tax (8.25%)" {
+		t.Errorf("Label() = %q, want %q", got, "Tax (8.25%)")
+	}
+	if got := (Tax{}).Label(); got != "Tax" {
+		t.Errorf("Label() without a rate = %q, want %q", got, "Tax")
+	}
+}
@@ -0,0 +1,174 @@
+// Package ledger describes bill balances as double-entry journal entries. Every entry moves value
+// between accounts and its postings sum to zero, so a bill's balances are the sums of its postings.
+// Like pricing, it is pure and has no storage of its own.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"fees-api/money"
+)
+
+type Account string
+
+const (
+	// Receivable is what the customer owes on the bill
+	Receivable Account = "accounts_receivable"
+	// Revenue is what the bill's line items earned
+	Revenue Account = "revenue"
+	// TaxPayable is the tax charged on the bill and owed to the tax authority
+	TaxPayable Account = "tax_payable"
+	// Cash is what the customer paid, net of refunds
+	Cash Account = "cash"
+)
+
+// IsValid reports whether a is one of the ledger's accounts
+func (a Account) IsValid() bool {
+	switch a {
+	case Receivable, Revenue, TaxPayable, Cash:
+		return true
+	}
+	return false
+}
+
+// Kind names the event that posted an entry
+type Kind string
+
+const (
+	LineItem Kind = "line_item" // a line item was added, changed or removed
+	Close    Kind = "close"     // tax charged when the bill closed
+	Reopen   Kind = "reopen"    // tax reversed when the bill reopened
+	Void     Kind = "void"      // charges reversed when the bill was voided
+	Payment  Kind = "payment"
+	Refund   Kind = "refund"
+)
+
+// Posting is one side of an entry. Debits are positive and credits negative.
+type Posting struct {
+	Account Account `json:"account"`
+	Amount  int64   `json:"amount"`
+}
+
+// Entry is a balanced journal entry on one bill. Reference ties it to what caused it, e.g. the
+// line item ID or the payment reference.
+type Entry struct {
+	ID        string         `json:"id"`
+	BillID    string         `json:"bill_id"`
+	Kind      Kind           `json:"kind"`
+	Currency  money.Currency `json:"currency"`
+	Reference string         `json:"reference,omitempty"`
+	Memo      string         `json:"memo,omitempty"`
+	PostedAt  time.Time      `json:"posted_at"`
+	Postings  []Posting      `json:"postings"`
+}
+
+var ErrUnbalanced = errors.New("ledger entry is unbalanced")
+
+// Validate checks that the entry has at least two non-zero postings on known accounts and that
+// they sum to zero
+func (e Entry) Validate() error {
+	if !e.Currency.IsValid() {
+		return fmt.Errorf("invalid currency %q", e.Currency)
+	}
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("entry needs at least two postings, has %d", len(e.Postings))
+	}
+	var sum int64
+	for _, p := range e.Postings {
+		if !p.Account.IsValid() {
+			return fmt.Errorf("unknown account %q", p.Account)
+		}
+		if p.Amount == 0 {
+			return fmt.Errorf("zero posting to %s", p.Account)
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: postings sum to %d", ErrUnbalanced, sum)
+	}
+	return nil
+}
+
+// transfer builds an entry that debits debit and credits credit by amount. A negative amount
+// moves value the other way, so corrections post the same kind of entry as the original.
+// It returns nil when there is nothing to post.
+func transfer(kind Kind, billID, reference string, amount money.Money, debit, credit Account, at time.Time) *Entry {
+	if amount.Amount == 0 {
+		return nil
+	}
+	return &Entry{
+		BillID:    billID,
+		Kind:      kind,
+		Currency:  amount.Currency,
+		Reference: reference,
+		PostedAt:  at,
+		Postings: []Posting{
+			{Account: debit, Amount: amount.Amount},
+			{Account: credit, Amount: -amount.Amount},
+		},
+	}
+}
+
+// Charge records revenue earned by a line item: debit Receivable, credit Revenue.
+// Pass the negative amount to reverse a removed item, or the difference for a changed one.
+func Charge(billID, itemID string, amount money.Money, at time.Time) *Entry {
+	return transfer(LineItem, billID, itemID, amount, Receivable, Revenue, at)
+}
+
+// Tax records tax charged on the bill at close: debit Receivable, credit TaxPayable
+func Tax(billID string, amount money.Money, at time.Time) *Entry {
+	return transfer(Close, billID, "", amount, Receivable, TaxPayable, at)
+}
+
+// TaxReversal reverses the tax outstanding on a bill that is reopened
+func TaxReversal(billID string, amount money.Money, at time.Time) *Entry {
+	return transfer(Reopen, billID, "", amount, TaxPayable, Receivable, at)
+}
+
+// VoidCharges reverses the revenue recognised on a voided bill
+func VoidCharges(billID string, amount money.Money, at time.Time) *Entry {
+	return transfer(Void, billID, "", amount, Revenue, Receivable, at)
+}
+
+// Pay records a payment received: debit Cash, credit Receivable
+func Pay(billID, reference string, amount money.Money, at time.Time) *Entry {
+	return transfer(Payment, billID, reference, amount, Cash, Receivable, at)
+}
+
+// RefundPayment records money returned to the customer: debit Receivable, credit Cash
+func RefundPayment(billID, reference string, amount money.Money, at time.Time) *Entry {
+	return transfer(Refund, billID, reference, amount, Receivable, Cash, at)
+}
+
+// Balances are the summed postings of one bill per account, debits positive
+type Balances map[Account]int64
+
+// Add sums the postings of entries into b
+func (b Balances) Add(entries ...*Entry) {
+	for _, e := range entries {
+		for _, p := range e.Postings {
+			b[p.Account] += p.Amount
+		}
+	}
+}
+
+// Statement is a bill's balance as the customer sees it. Due always equals Charged + Tax - Paid.
+type Statement struct {
+	Charged money.Money `json:"charged"` // revenue from line items
+	Tax     money.Money `json:"tax"`
+	Paid    money.Money `json:"paid"` // payments net of refunds
+	Due     money.Money `json:"due"`
+}
+
+// Statement derives the customer's view of the balances. Credit accounts carry negative
+// balances, so their signs are flipped.
+func (b Balances) Statement(currency money.Currency) Statement {
+	return Statement{
+		Charged: money.Money{Amount: -b[Revenue], Currency: currency},
+		Tax:     money.Money{Amount: -b[TaxPayable], Currency: currency},
+		Paid:    money.Money{Amount: b[Cash], Currency: currency},
+		Due:     money.Money{Amount: b[Receivable], Currency: currency},
+	}
+}
@@ -0,0 +1,103 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fees-api/money"
+)
+
+var postedAt = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func usd(amount int64) money.Money {
+	return money.Money{Amount: amount, Currency: money.USD}
+}
+
+func TestEntryValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []Posting
+		wantErr  bool
+	}{
+		{"balanced", []Posting{{Receivable, 500}, {Revenue, -500}}, false},
+		{"split", []Posting{{Receivable, 590}, {Revenue, -500}, {TaxPayable, -90}}, false},
+		{"unbalanced", []Posting{{Receivable, 500}, {Revenue, -400}}, true},
+		{"single posting", []Posting{{Receivable, 0}}, true},
+		{"zero posting", []Posting{{Receivable, 500}, {Revenue, -500}, {Cash, 0}}, true},
+		{"unknown account", []Posting{{Receivable, 500}, {"suspense", -500}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Entry{BillID: "bill", Kind: LineItem, Currency: money.USD, PostedAt: postedAt, Postings: tt.postings}
+			if err := e.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	e := Entry{Currency: money.USD, Postings: []Posting{{Receivable, 1}, {Revenue, -2}}}
+	if err := e.Validate(); !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("Validate() error = %v, want ErrUnbalanced", err)
+	}
+}
+
+func TestConstructorsBalance(t *testing.T) {
+	entries := map[string]*Entry{
+		"charge":          Charge("bill", "item", usd(500), postedAt),
+		"charge reversal": Charge("bill", "item", usd(-500), postedAt),
+		"tax":             Tax("bill", usd(90), postedAt),
+		"tax reversal":    TaxReversal("bill", usd(90), postedAt),
+		"void":            VoidCharges("bill", usd(500), postedAt),
+		"payment":         Pay("bill", "pay-1", usd(300), postedAt),
+		"refund":          RefundPayment("bill", "ref-1", usd(100), postedAt),
+	}
+	for name, e := range entries {
+		if err := e.Validate(); err != nil {
+			t.Errorf("%s: Validate() error = %v", name, err)
+		}
+	}
+
+	if e := Charge("bill", "item", usd(0), postedAt); e != nil {
+		t.Errorf("Charge(0) = %+v, want nil", e)
+	}
+}
+
+func TestNegativeAmountSwapsSides(t *testing.T) {
+	e := Charge("bill", "item", usd(-250), postedAt)
+	want := []Posting{{Receivable, -250}, {Revenue, 250}}
+	for i, p := range e.Postings {
+		if p != want[i] {
+			t.Errorf("posting %d = %+v, want %+v", i, p, want[i])
+		}
+	}
+}
+
+func TestStatement(t *testing.T) {
+	b := Balances{}
+	b.Add(
+		Charge("bill", "item-1", usd(1000), postedAt),
+		Charge("bill", "item-2", usd(500), postedAt),
+		Charge("bill", "item-2", usd(-200), postedAt),
+		Tax("bill", usd(130), postedAt),
+		Pay("bill", "pay-1", usd(1000), postedAt),
+		RefundPayment("bill", "ref-1", usd(100), postedAt),
+	)
+
+	s := b.Statement(money.USD)
+	want := Statement{Charged: usd(1300), Tax: usd(130), Paid: usd(900), Due: usd(530)}
+	if s != want {
+		t.Errorf("Statement() = %+v, want %+v", s, want)
+	}
+	if s.Due.Amount != s.Charged.Amount+s.Tax.Amount-s.Paid.Amount {
+		t.Errorf("Due %d != Charged + Tax - Paid", s.Due.Amount)
+	}
+
+	var sum int64
+	for _, balance := range b {
+		sum += balance
+	}
+	if sum != 0 {
+		t.Errorf("balances sum to %d, want 0", sum)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"strconv"
+	"strings"
 )
 
 type Currency string
@@ -46,6 +49,47 @@ func (m Money) Add(other Money) (Money, error) {
 	}, nil
 }
 
+// Sub subtracts other from m; both must share the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, errors.New("subtraction would overflow")
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Mul multiplies m by a non-negative quantity, e.g. a unit price by the number of units
+func (m Money) Mul(quantity int64) (Money, error) {
+	if quantity < 0 {
+		return Money{}, errors.New("quantity cannot be negative")
+	}
+	if quantity != 0 && (m.Amount > math.MaxInt64/quantity || m.Amount < math.MinInt64/quantity) {
+		return Money{}, errors.New("multiplication would overflow")
+	}
+	return Money{
+		Amount:   m.Amount * quantity,
+		Currency: m.Currency,
+	}, nil
+}
+
+// Percent returns basisPoints hundredths of a percent of m (290 is 2.9%), rounded half away from zero
+func (m Money) Percent(basisPoints int64) (Money, error) {
+	if basisPoints < 0 {
+		return Money{}, errors.New("percentage cannot be negative")
+	}
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(basisPoints))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(10000), new(big.Int))
+	if remainder.CmpAbs(big.NewInt(5000)) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(product.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return Money{}, errors.New("percentage would overflow")
+	}
+	return Money{
+		Amount:   quotient.Int64(),
+		Currency: m.Currency,
+	}, nil
+}
+
 // Current String() method is basic - consider formatting
 func (m Money) String() string {
 	// For USD: "12.34 USD" instead of "1234 USD"
@@ -57,6 +101,42 @@ func (m Money) String() string {
 	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
 }
 
+// Decimal renders the amount in major units with two decimal places, e.g. "1234.56"
+func (m Money) Decimal() string {
+	sign, major, minor := m.parts()
+	return fmt.Sprintf("%s%d.%02d", sign, major, minor)
+}
+
+// Format renders the amount for documents with thousands separators,
+// e.g. "$1,234.56" for USD or "1,234.56 GEL" for GEL
+func (m Money) Format() string {
+	sign, major, minor := m.parts()
+
+	digits := strconv.FormatUint(major, 10)
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(d)
+	}
+
+	if m.Currency == USD {
+		return fmt.Sprintf("%s$%s.%02d", sign, grouped.String(), minor)
+	}
+	return fmt.Sprintf("%s%s.%02d %s", sign, grouped.String(), minor, m.Currency)
+}
+
+// parts splits the amount into sign, major and minor units (both supported currencies use 2 decimals)
+func (m Money) parts() (sign string, major, minor uint64) {
+	abs := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		abs = uint64(-(m.Amount + 1)) + 1 // avoids overflow for math.MinInt64
+	}
+	return sign, abs / 100, abs % 100
+}
+
 // Add validation for supported currencies
 func (c Currency) IsValid() bool {
 	return c == USD || c == GEL
@@ -1,6 +1,7 @@
 package money
 
 import (
+	"math"
 	"testing"
 )
 
@@ -59,6 +60,97 @@ func TestMoney_Add(t *testing.T) {
 	}
 }
 
+func TestMoney_Sub(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       Money
+		other   Money
+		want    int64
+		wantErr bool
+	}{
+		{"same currency", Money{Amount: 150, Currency: USD}, Money{Amount: 50, Currency: USD}, 100, false},
+		{"to zero", Money{Amount: 50, Currency: USD}, Money{Amount: 50, Currency: USD}, 0, false},
+		{"below zero", Money{Amount: 50, Currency: GEL}, Money{Amount: 80, Currency: GEL}, -30, false},
+		{"different currency", Money{Amount: 100, Currency: USD}, Money{Amount: 10, Currency: GEL}, 0, true},
+		{"min int64", Money{Amount: 0, Currency: USD}, Money{Amount: math.MinInt64, Currency: USD}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Sub(tt.other)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Money.Sub() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.Amount != tt.want {
+				t.Errorf("Money.Sub() = %v, want %v", got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoney_Mul(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        Money
+		quantity int64
+		want     int64
+		wantErr  bool
+	}{
+		{"unit price times quantity", Money{Amount: 250, Currency: USD}, 4, 1000, false},
+		{"zero quantity", Money{Amount: 250, Currency: GEL}, 0, 0, false},
+		{"negative quantity", Money{Amount: 250, Currency: USD}, -1, 0, true},
+		{"overflow", Money{Amount: math.MaxInt64 / 2, Currency: USD}, 3, 0, true},
+		{"max without overflow", Money{Amount: math.MaxInt64 / 2, Currency: USD}, 2, math.MaxInt64 - 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Mul(tt.quantity)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Money.Mul() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (got.Amount != tt.want || got.Currency != tt.m.Currency) {
+				t.Errorf("Money.Mul() = %v, want %v %s", got, tt.want, tt.m.Currency)
+			}
+		})
+	}
+}
+
+func TestMoney_Percent(t *testing.T) {
+	tests := []struct {
+		name        string
+		m           Money
+		basisPoints int64
+		want        int64
+		wantErr     bool
+	}{
+		{"card fee", Money{Amount: 10000, Currency: USD}, 290, 290, false},
+		{"rounds half up", Money{Amount: 50, Currency: USD}, 1000, 5, false},
+		{"rounds down below half", Money{Amount: 1050, Currency: GEL}, 50, 5, false},
+		{"rounds up from half", Money{Amount: 1100, Currency: GEL}, 50, 6, false},
+		{"rounds half away from zero", Money{Amount: -1100, Currency: USD}, 50, -6, false},
+		{"zero percent", Money{Amount: 1234, Currency: USD}, 0, 0, false},
+		{"large amount without overflow", Money{Amount: math.MaxInt64, Currency: USD}, 10000, math.MaxInt64, false},
+		{"overflow", Money{Amount: math.MaxInt64, Currency: USD}, 20000, 0, true},
+		{"negative percentage", Money{Amount: 100, Currency: USD}, -1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Percent(tt.basisPoints)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Money.Percent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (got.Amount != tt.want || got.Currency != tt.m.Currency) {
+				t.Errorf("Money.Percent() = %v, want %v %s", got, tt.want, tt.m.Currency)
+			}
+		})
+	}
+}
+
 func TestMoney_String(t *testing.T) {
 	tests := []struct {
 		name string
@@ -79,6 +171,51 @@ func TestMoney_String(t *testing.T) {
 	}
 }
 
+func TestMoney_Decimal(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Money
+		want string
+	}{
+		{"zero", Money{Amount: 0, Currency: USD}, "0.00"},
+		{"cents only", Money{Amount: 5, Currency: USD}, "0.05"},
+		{"large", Money{Amount: 123456789, Currency: GEL}, "1234567.89"},
+		{"negative", Money{Amount: -1050, Currency: USD}, "-10.50"},
+		{"min int64", Money{Amount: math.MinInt64, Currency: USD}, "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Decimal(); got != tt.want {
+				t.Errorf("Money.Decimal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoney_Format(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Money
+		want string
+	}{
+		{"USD small", Money{Amount: 123, Currency: USD}, "$1.23"},
+		{"USD thousands", Money{Amount: 123456, Currency: USD}, "$1,234.56"},
+		{"USD millions", Money{Amount: 100000000, Currency: USD}, "$1,000,000.00"},
+		{"USD negative", Money{Amount: -250000, Currency: USD}, "-$2,500.00"},
+		{"GEL", Money{Amount: 2000, Currency: GEL}, "20.00 GEL"},
+		{"GEL thousands", Money{Amount: 9876543, Currency: GEL}, "98,765.43 GEL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Format(); got != tt.want {
+				t.Errorf("Money.Format() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCurrency_IsValid(t *testing.T) {
 	tests := []struct {
 		name string
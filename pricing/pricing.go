@@ -0,0 +1,224 @@
+// Package pricing turns a quantity into an itemized charge under flat, per-unit,
+// tiered-volume, graduated and package pricing models.
+// All calculations are pure and deterministic, so they are safe to run in workflow code.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+
+	"fees-api/money"
+)
+
+type Model string
+
+const (
+	// Flat charges UnitAmount once, whatever the quantity
+	Flat Model = "flat"
+	// PerUnit charges UnitAmount for every unit
+	PerUnit Model = "per_unit"
+	// Volume charges every unit at the rate of the tier the total quantity falls into
+	Volume Model = "volume"
+	// Graduated charges the units in each tier at that tier's rate
+	Graduated Model = "graduated"
+	// Package charges UnitAmount per started package of PackageSize units
+	Package Model = "package"
+)
+
+// Tier covers quantities up to and including UpTo; UpTo 0 means no upper bound and is only valid on the last tier.
+// FlatAmount is charged once whenever the tier is reached.
+type Tier struct {
+	UpTo       int64 `json:"up_to"`
+	UnitAmount int64 `json:"unit_amount"`
+	FlatAmount int64 `json:"flat_amount,omitempty"`
+}
+
+// Price describes how to charge for a quantity in one currency
+type Price struct {
+	Model       Model          `json:"model"`
+	Currency    money.Currency `json:"currency"`
+	UnitAmount  int64          `json:"unit_amount,omitempty"`
+	PackageSize int64          `json:"package_size,omitempty"`
+	Tiers       []Tier         `json:"tiers,omitempty"`
+}
+
+// Line is one itemized part of a charge. Amount always equals UnitAmount × Quantity.
+type Line struct {
+	Description string
+	Quantity    int64
+	UnitAmount  money.Money
+	Amount      money.Money
+}
+
+// Breakdown is the itemized result of a calculation; Total is the sum of all line amounts
+type Breakdown struct {
+	Lines []Line
+	Total money.Money
+}
+
+// Validate checks that the price is internally consistent for its model
+func (p Price) Validate() error {
+	if !p.Currency.IsValid() {
+		return fmt.Errorf("invalid currency: %s", p.Currency)
+	}
+
+	switch p.Model {
+	case Flat, PerUnit:
+		if p.UnitAmount < 0 {
+			return errors.New("unit_amount cannot be negative")
+		}
+		if len(p.Tiers) > 0 || p.PackageSize != 0 {
+			return fmt.Errorf("%s prices only take unit_amount", p.Model)
+		}
+	case Package:
+		if p.UnitAmount < 0 {
+			return errors.New("unit_amount cannot be negative")
+		}
+		if p.PackageSize <= 0 {
+			return errors.New("package prices require a positive package_size")
+		}
+		if len(p.Tiers) > 0 {
+			return errors.New("package prices do not take tiers")
+		}
+	case Volume, Graduated:
+		if p.UnitAmount != 0 || p.PackageSize != 0 {
+			return fmt.Errorf("%s prices only take tiers", p.Model)
+		}
+		if len(p.Tiers) == 0 {
+			return fmt.Errorf("%s prices require tiers", p.Model)
+		}
+		for i, tier := range p.Tiers {
+			if tier.UnitAmount < 0 || tier.FlatAmount < 0 {
+				return fmt.Errorf("tiers[%d]: amounts cannot be negative", i)
+			}
+			last := i == len(p.Tiers)-1
+			if last && tier.UpTo != 0 {
+				return errors.New("the last tier must have up_to 0 (no upper bound)")
+			}
+			if !last && (tier.UpTo <= 0 || (i > 0 && tier.UpTo <= p.Tiers[i-1].UpTo)) {
+				return fmt.Errorf("tiers[%d]: up_to must be positive and increasing", i)
+			}
+		}
+	default:
+		return fmt.Errorf("model must be one of %s, %s, %s, %s or %s", Flat, PerUnit, Volume, Graduated, Package)
+	}
+	return nil
+}
+
+// Calculate prices quantity units and returns the itemized breakdown.
+// A zero quantity produces no lines, except for flat prices which always charge their fee.
+func Calculate(p Price, quantity int64) (Breakdown, error) {
+	if err := p.Validate(); err != nil {
+		return Breakdown{}, err
+	}
+	if quantity < 0 {
+		return Breakdown{}, errors.New("quantity cannot be negative")
+	}
+
+	c := calculator{currency: p.Currency}
+	switch p.Model {
+	case Flat:
+		c.add("flat fee", 1, p.UnitAmount)
+	case PerUnit:
+		if quantity > 0 {
+			c.add("", quantity, p.UnitAmount)
+		}
+	case Package:
+		if quantity > 0 {
+			// Round up to whole packages without overflowing near MaxInt64
+			packages := quantity / p.PackageSize
+			if quantity%p.PackageSize != 0 {
+				packages++
+			}
+			c.add(fmt.Sprintf("packages of %d", p.PackageSize), packages, p.UnitAmount)
+		}
+	case Volume:
+		if quantity > 0 {
+			i := tierFor(p.Tiers, quantity)
+			tier := p.Tiers[i]
+			c.add(tierLabel(p.Tiers, i), quantity, tier.UnitAmount)
+			if tier.FlatAmount > 0 {
+				c.add(tierLabel(p.Tiers, i)+" flat fee", 1, tier.FlatAmount)
+			}
+		}
+	case Graduated:
+		var lower int64 // units already charged by earlier tiers
+		for i, tier := range p.Tiers {
+			if quantity <= lower {
+				break
+			}
+			inTier := quantity - lower
+			if tier.UpTo != 0 && tier.UpTo-lower < inTier {
+				inTier = tier.UpTo - lower
+			}
+			c.add(tierLabel(p.Tiers, i), inTier, tier.UnitAmount)
+			if tier.FlatAmount > 0 {
+				c.add(tierLabel(p.Tiers, i)+" flat fee", 1, tier.FlatAmount)
+			}
+			lower += inTier
+		}
+	}
+
+	if c.err != nil {
+		return Breakdown{}, c.err
+	}
+	return c.breakdown()
+}
+
+// calculator accumulates lines and remembers the first arithmetic error
+type calculator struct {
+	currency money.Currency
+	lines    []Line
+	err      error
+}
+
+func (c *calculator) add(description string, quantity, unitAmount int64) {
+	if c.err != nil {
+		return
+	}
+	unit := money.Money{Amount: unitAmount, Currency: c.currency}
+	amount, err := unit.Mul(quantity)
+	if err != nil {
+		c.err = fmt.Errorf("failed to price %d units at %s: %w", quantity, unit, err)
+		return
+	}
+	c.lines = append(c.lines, Line{
+		Description: description,
+		Quantity:    quantity,
+		UnitAmount:  unit,
+		Amount:      amount,
+	})
+}
+
+func (c *calculator) breakdown() (Breakdown, error) {
+	total := money.Money{Currency: c.currency}
+	for _, line := range c.lines {
+		var err error
+		if total, err = total.Add(line.Amount); err != nil {
+			return Breakdown{}, fmt.Errorf("failed to total charge: %w", err)
+		}
+	}
+	return Breakdown{Lines: c.lines, Total: total}, nil
+}
+
+// tierFor returns the index of the tier that contains quantity
+func tierFor(tiers []Tier, quantity int64) int {
+	for i, tier := range tiers {
+		if tier.UpTo == 0 || quantity <= tier.UpTo {
+			return i
+		}
+	}
+	return len(tiers) - 1
+}
+
+// tierLabel names a tier by its unit range, e.g. "units 1-1000" or "units 1001+"
+func tierLabel(tiers []Tier, i int) string {
+	var from int64 = 1
+	if i > 0 {
+		from = tiers[i-1].UpTo + 1
+	}
+	if tiers[i].UpTo == 0 {
+		return fmt.Sprintf("units %d+", from)
+	}
+	return fmt.Sprintf("units %d-%d", from, tiers[i].UpTo)
+}
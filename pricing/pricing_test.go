@@ -0,0 +1,279 @@
+package pricing
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"fees-api/money"
+)
+
+// transactionFees is the fee schedule from the docs: the first 1,000 transactions cost 10,
+// the next 9,000 cost 8 and everything after that costs 5
+var transactionFees = []Tier{
+	{UpTo: 1000, UnitAmount: 10},
+	{UpTo: 10000, UnitAmount: 8},
+	{UpTo: 0, UnitAmount: 5},
+}
+
+func TestPriceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   Price
+		wantErr bool
+	}{
+		{"flat", Price{Model: Flat, Currency: money.USD, UnitAmount: 5000}, false},
+		{"per unit", Price{Model: PerUnit, Currency: money.USD, UnitAmount: 250}, false},
+		{"free per unit", Price{Model: PerUnit, Currency: money.GEL}, false},
+		{"package", Price{Model: Package, Currency: money.USD, UnitAmount: 500, PackageSize: 100}, false},
+		{"volume", Price{Model: Volume, Currency: money.USD, Tiers: transactionFees}, false},
+		{"graduated", Price{Model: Graduated, Currency: money.USD, Tiers: transactionFees}, false},
+		{"graduated with flat fees", Price{Model: Graduated, Currency: money.USD, Tiers: []Tier{{UpTo: 10, FlatAmount: 1000}, {UpTo: 0, UnitAmount: 50}}}, false},
+		{"invalid currency", Price{Model: PerUnit, Currency: "EUR", UnitAmount: 1}, true},
+		{"unknown model", Price{Model: "stairstep", Currency: money.USD}, true},
+		{"negative unit amount", Price{Model: PerUnit, Currency: money.USD, UnitAmount: -1}, true},
+		{"per unit with tiers", Price{Model: PerUnit, Currency: money.USD, Tiers: []Tier{{UpTo: 0, UnitAmount: 1}}}, true},
+		{"flat with package size", Price{Model: Flat, Currency: money.USD, UnitAmount: 1, PackageSize: 10}, true},
+		{"package without size", Price{Model: Package, Currency: money.USD, UnitAmount: 500}, true},
+		{"tiered with unit amount", Price{Model: Graduated, Currency: money.USD, UnitAmount: 1, Tiers: transactionFees}, true},
+		{"tiered without tiers", Price{Model: Volume, Currency: money.USD}, true},
+		{"last tier bounded", Price{Model: Graduated, Currency: money.USD, Tiers: []Tier{{UpTo: 100, UnitAmount: 1}}}, true},
+		{"tiers not increasing", Price{Model: Volume, Currency: money.USD, Tiers: []Tier{{UpTo: 100, UnitAmount: 2}, {UpTo: 50, UnitAmount: 1}, {UpTo: 0, UnitAmount: 1}}}, true},
+		{"negative tier flat amount", Price{Model: Graduated, Currency: money.USD, Tiers: []Tier{{UpTo: 0, FlatAmount: -5}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.price.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Price.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	usd := func(amount int64) money.Money { return money.Money{Amount: amount, Currency: money.USD} }
+
+	tests := []struct {
+		name      string
+		price     Price
+		quantity  int64
+		wantLines []Line
+		wantTotal money.Money
+	}{
+		{
+			name:      "flat ignores quantity",
+			price:     Price{Model: Flat, Currency: money.USD, UnitAmount: 5000},
+			quantity:  42,
+			wantLines: []Line{{Description: "flat fee", Quantity: 1, UnitAmount: usd(5000), Amount: usd(5000)}},
+			wantTotal: usd(5000),
+		},
+		{
+			name:      "per unit",
+			price:     Price{Model: PerUnit, Currency: money.USD, UnitAmount: 250},
+			quantity:  3,
+			wantLines: []Line{{Quantity: 3, UnitAmount: usd(250), Amount: usd(750)}},
+			wantTotal: usd(750),
+		},
+		{
+			name:      "package rounds up",
+			price:     Price{Model: Package, Currency: money.USD, UnitAmount: 500, PackageSize: 100},
+			quantity:  201,
+			wantLines: []Line{{Description: "packages of 100", Quantity: 3, UnitAmount: usd(500), Amount: usd(1500)}},
+			wantTotal: usd(1500),
+		},
+		{
+			name:      "volume prices every unit at one tier",
+			price:     Price{Model: Volume, Currency: money.USD, Tiers: transactionFees},
+			quantity:  1500,
+			wantLines: []Line{{Description: "units 1001-10000", Quantity: 1500, UnitAmount: usd(8), Amount: usd(12000)}},
+			wantTotal: usd(12000),
+		},
+		{
+			name:      "volume tier boundary",
+			price:     Price{Model: Volume, Currency: money.USD, Tiers: transactionFees},
+			quantity:  1000,
+			wantLines: []Line{{Description: "units 1-1000", Quantity: 1000, UnitAmount: usd(10), Amount: usd(10000)}},
+			wantTotal: usd(10000),
+		},
+		{
+			name:     "graduated splits across tiers",
+			price:    Price{Model: Graduated, Currency: money.USD, Tiers: transactionFees},
+			quantity: 12000,
+			wantLines: []Line{
+				{Description: "units 1-1000", Quantity: 1000, UnitAmount: usd(10), Amount: usd(10000)},
+				{Description: "units 1001-10000", Quantity: 9000, UnitAmount: usd(8), Amount: usd(72000)},
+				{Description: "units 10001+", Quantity: 2000, UnitAmount: usd(5), Amount: usd(10000)},
+			},
+			wantTotal: usd(92000),
+		},
+		{
+			name: "graduated tier flat fees",
+			price: Price{Model: Graduated, Currency: money.USD, Tiers: []Tier{
+				{UpTo: 10, FlatAmount: 1000},
+				{UpTo: 0, UnitAmount: 50, FlatAmount: 200},
+			}},
+			quantity: 12,
+			wantLines: []Line{
+				{Description: "units 1-10", Quantity: 10, UnitAmount: usd(0), Amount: usd(0)},
+				{Description: "units 1-10 flat fee", Quantity: 1, UnitAmount: usd(1000), Amount: usd(1000)},
+				{Description: "units 11+", Quantity: 2, UnitAmount: usd(50), Amount: usd(100)},
+				{Description: "units 11+ flat fee", Quantity: 1, UnitAmount: usd(200), Amount: usd(200)},
+			},
+			wantTotal: usd(1300),
+		},
+		{
+			name:      "zero quantity is free",
+			price:     Price{Model: Graduated, Currency: money.USD, Tiers: transactionFees},
+			quantity:  0,
+			wantTotal: usd(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Calculate(tt.price, tt.quantity)
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Lines, tt.wantLines) {
+				t.Errorf("Calculate() lines = %+v, want %+v", got.Lines, tt.wantLines)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("Calculate() total = %v, want %v", got.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestCalculateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    Price
+		quantity int64
+	}{
+		{"invalid price", Price{Model: Volume, Currency: money.USD}, 1},
+		{"negative quantity", Price{Model: PerUnit, Currency: money.USD, UnitAmount: 1}, -1},
+		{"overflow", Price{Model: PerUnit, Currency: money.USD, UnitAmount: 1 << 40}, 1 << 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Calculate(tt.price, tt.quantity); err == nil {
+				t.Error("Calculate() expected an error")
+			}
+		})
+	}
+}
+
+// pricedQuantity is a random valid price together with a quantity to charge for
+type pricedQuantity struct {
+	Price    Price
+	Quantity int64
+}
+
+func (pricedQuantity) Generate(r *rand.Rand, _ int) reflect.Value {
+	models := []Model{Flat, PerUnit, Volume, Graduated, Package}
+	p := Price{Model: models[r.Intn(len(models))], Currency: money.USD}
+
+	switch p.Model {
+	case Flat, PerUnit:
+		p.UnitAmount = r.Int63n(100_000)
+	case Package:
+		p.UnitAmount = r.Int63n(100_000)
+		p.PackageSize = 1 + r.Int63n(1000)
+	case Volume, Graduated:
+		var upTo int64
+		for i, n := 0, 1+r.Intn(5); i < n; i++ {
+			tier := Tier{UnitAmount: r.Int63n(1000)}
+			if r.Intn(3) == 0 {
+				tier.FlatAmount = r.Int63n(100_000)
+			}
+			if i < n-1 {
+				upTo += 1 + r.Int63n(10_000)
+				tier.UpTo = upTo
+			}
+			p.Tiers = append(p.Tiers, tier)
+		}
+	}
+
+	return reflect.ValueOf(pricedQuantity{Price: p, Quantity: r.Int63n(100_000)})
+}
+
+func TestCalculateLinesSumToTotal(t *testing.T) {
+	property := func(pq pricedQuantity) bool {
+		b, err := Calculate(pq.Price, pq.Quantity)
+		if err != nil {
+			t.Logf("Calculate(%+v, %d) error = %v", pq.Price, pq.Quantity, err)
+			return false
+		}
+
+		var sum int64
+		for _, line := range b.Lines {
+			if line.Amount.Currency != pq.Price.Currency || line.UnitAmount.Currency != pq.Price.Currency {
+				return false
+			}
+			if line.Amount.Amount != line.UnitAmount.Amount*line.Quantity {
+				return false
+			}
+			sum += line.Amount.Amount
+		}
+		return sum == b.Total.Amount && b.Total.Currency == pq.Price.Currency
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCalculateGraduatedChargesEveryUnitOnce(t *testing.T) {
+	property := func(pq pricedQuantity) bool {
+		if pq.Price.Model != Graduated {
+			pq.Price = Price{Model: Graduated, Currency: money.USD, Tiers: transactionFees}
+		}
+		b, err := Calculate(pq.Price, pq.Quantity)
+		if err != nil {
+			return false
+		}
+
+		var units int64
+		for _, line := range b.Lines {
+			if !isFlatFee(line) {
+				units += line.Quantity
+			}
+		}
+		return units == pq.Quantity
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCalculateVolumeNotAboveGraduated(t *testing.T) {
+	// With rates that fall tier by tier, volume pricing applies the cheapest reached rate
+	// to every unit and so never charges more than graduated pricing
+	property := func(pq pricedQuantity) bool {
+		tiers := []Tier{{UpTo: 1000, UnitAmount: 10}, {UpTo: 5000, UnitAmount: 7}, {UpTo: 0, UnitAmount: 3}}
+		graduated, err := Calculate(Price{Model: Graduated, Currency: money.USD, Tiers: tiers}, pq.Quantity)
+		if err != nil {
+			return false
+		}
+		volume, err := Calculate(Price{Model: Volume, Currency: money.USD, Tiers: tiers}, pq.Quantity)
+		if err != nil {
+			return false
+		}
+		return volume.Total.Amount <= graduated.Total.Amount
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func isFlatFee(line Line) bool {
+	return strings.HasSuffix(line.Description, "flat fee")
+}